@@ -0,0 +1,250 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"golang.org/x/sync/errgroup"
+)
+
+// Item processing result of an input file
+type Item struct {
+	Input  string
+	Output string
+	Size   int64
+	Err    error
+}
+
+// Report processing report
+type Report struct {
+	Processed int
+	Failed    int
+	// Bytes total size of outputs
+	Bytes int64
+}
+
+// Batch processes input files with imagor params through Imagor.ServeBlob,
+// writing outputs into OutputDir with extensions of the output format
+type Batch struct {
+	App       *imagor.Imagor
+	Params    imagorpath.Params
+	OutputDir string
+	// BaseDir inputs keep their directories relative to BaseDir in OutputDir.
+	// Inputs are written by file names if empty
+	BaseDir     string
+	Concurrency int
+	// Progress is called with the result of each input, one at a time
+	Progress func(item Item)
+}
+
+// New creates Batch processing files with params into outputDir
+func New(app *imagor.Imagor, params imagorpath.Params, outputDir string, options ...Option) *Batch {
+	b := &Batch{
+		App:         app,
+		Params:      params,
+		OutputDir:   outputDir,
+		Concurrency: runtime.NumCPU(),
+	}
+	for _, option := range options {
+		option(b)
+	}
+	return b
+}
+
+// ParseParams parses imagor params string without image e.g. "fit-in/200x200/filters:format(webp)"
+func ParseParams(params string) imagorpath.Params {
+	params = strings.Trim(strings.TrimSpace(params), "/")
+	if params == "" {
+		return imagorpath.Params{}
+	}
+	// placeholder image, so that the last params segment is not parsed as image
+	p := imagorpath.Parse(params + "/_")
+	p.Image = ""
+	return p
+}
+
+// Glob expands patterns into files, with directories walked recursively.
+// Patterns without glob characters are kept as is, so that missing files are reported on Run
+func Glob(patterns ...string) (files []string, err error) {
+	var seen = map[string]bool{}
+	var add = func(file string) {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			if strings.ContainsAny(pattern, `*?[\`) {
+				return nil, fmt.Errorf("%s: no matches", pattern)
+			}
+			matches = []string{pattern}
+		}
+		for _, match := range matches {
+			if stat, err := os.Stat(match); err != nil || !stat.IsDir() {
+				add(match)
+				continue
+			}
+			var walked []string
+			if err := filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if path != match && strings.HasPrefix(d.Name(), ".") {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if !d.IsDir() {
+					walked = append(walked, path)
+				}
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+			sort.Strings(walked)
+			for _, path := range walked {
+				add(path)
+			}
+		}
+	}
+	return
+}
+
+// outputStem returns output path of input without extension
+func (b *Batch) outputStem(input string) (string, error) {
+	name := filepath.Base(input)
+	if b.BaseDir != "" {
+		rel, err := filepath.Rel(b.BaseDir, input)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("%s: not within base dir %s", input, b.BaseDir)
+		}
+		name = rel
+	}
+	return filepath.Join(b.OutputDir, strings.TrimSuffix(name, filepath.Ext(name))), nil
+}
+
+// Run processes all inputs concurrently. Inputs failed are reported without stopping the batch,
+// error is returned if inputs map to the same output, or ctx is done
+func (b *Batch) Run(ctx context.Context, inputs []string) (report Report, err error) {
+	var stems = make([]string, len(inputs))
+	var seen = map[string]string{}
+	for i, input := range inputs {
+		if stems[i], err = b.outputStem(input); err != nil {
+			return
+		}
+		if prev, ok := seen[stems[i]]; ok {
+			err = fmt.Errorf("%s and %s: same output %s", prev, input, stems[i])
+			return
+		}
+		seen[stems[i]] = input
+	}
+	var mu sync.Mutex
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(b.Concurrency, 1))
+	for i, input := range inputs {
+		if gctx.Err() != nil {
+			break
+		}
+		g.Go(func() error {
+			item := b.process(gctx, input, stems[i])
+			mu.Lock()
+			defer mu.Unlock()
+			if item.Err != nil {
+				report.Failed++
+			} else {
+				report.Processed++
+				report.Bytes += item.Size
+			}
+			if b.Progress != nil {
+				b.Progress(item)
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return report, ctx.Err()
+}
+
+func (b *Batch) process(ctx context.Context, input, stem string) (item Item) {
+	item.Input = input
+	blob, err := b.App.ServeBlob(ctx, imagor.NewBlobFromFile(input), b.Params)
+	if err == nil && blob != nil {
+		err = blob.Err()
+	}
+	if err == nil && blob == nil {
+		err = imagor.ErrNotFound
+	}
+	if err != nil {
+		item.Err = err
+		return
+	}
+	ext := blob.Extension()
+	if ext == "" {
+		ext = filepath.Ext(input)
+	}
+	item.Output = stem + ext
+	if sameFile(input, item.Output) {
+		item.Err = fmt.Errorf("%s: output overwrites input", item.Output)
+		return
+	}
+	item.Size, item.Err = write(item.Output, blob)
+	return
+}
+
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// write writes blob into file atomically
+func write(file string, blob *imagor.Blob) (n int64, err error) {
+	dir := filepath.Dir(file)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	reader, _, err := blob.NewReader()
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	tmp, err := os.CreateTemp(dir, ".imagor-*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	if n, err = io.Copy(tmp, reader); err != nil {
+		_ = tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return
+	}
+	err = os.Rename(tmp.Name(), file)
+	return
+}
@@ -0,0 +1,142 @@
+package batch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+// png header padded for type sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n0123456789abcdefghijklmn")
+
+type processor struct{}
+
+func (processor) Startup(context.Context) error  { return nil }
+func (processor) Shutdown(context.Context) error { return nil }
+
+// Process converts to png if format(png), or returns the blob as is
+func (processor) Process(
+	_ context.Context, blob *imagor.Blob, p imagorpath.Params, _ imagor.LoadFunc,
+) (*imagor.Blob, error) {
+	buf, err := blob.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if imagorpath.HasFilter(p, "format") {
+		return imagor.NewBlobFromBytes(append(append([]byte{}, pngHeader...), buf...)), nil
+	}
+	return imagor.NewBlobFromBytes(buf), nil
+}
+
+func writeFiles(t *testing.T, dir string, files ...string) {
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(file), 0644))
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	p := ParseParams("/fit-in/200x100/filters:format(png):quality(80)/")
+	assert.True(t, p.FitIn)
+	assert.Equal(t, 200, p.Width)
+	assert.Equal(t, 100, p.Height)
+	assert.Equal(t, "", p.Image)
+	assert.Equal(t, imagorpath.Filters{
+		{Name: "format", Args: "png"},
+		{Name: "quality", Args: "80"},
+	}, p.Filters)
+	assert.Equal(t, imagorpath.Params{}, ParseParams(" "))
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a.jpg", "b.png", "sub/c.jpg", "sub/deep/d.jpg", "sub/.hidden/e.jpg", "sub/.f.jpg")
+
+	files, err := Glob(filepath.Join(dir, "*.jpg"), filepath.Join(dir, "sub"), filepath.Join(dir, "a.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a.jpg"),
+		filepath.Join(dir, "sub/c.jpg"),
+		filepath.Join(dir, "sub/deep/d.jpg"),
+	}, files)
+
+	files, err = Glob(filepath.Join(dir, "missing.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "missing.jpg")}, files)
+
+	_, err = Glob(filepath.Join(dir, "*.gif"))
+	assert.Error(t, err)
+	_, err = Glob("[")
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	out := t.TempDir()
+	writeFiles(t, dir, "a.jpg", "sub/b.gif", "sub/c")
+	app := imagor.New(imagor.WithProcessors(processor{}))
+
+	var items []Item
+	b := New(app, ParseParams("filters:format(png)"), out, WithBaseDir(dir), WithConcurrency(2),
+		WithProgress(func(item Item) {
+			items = append(items, item)
+		}))
+	inputs, err := Glob(dir, filepath.Join(dir, "missing.jpg"))
+	require.NoError(t, err)
+	report, err := b.Run(ctx, inputs)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Processed: 3, Failed: 1, Bytes: 32*3 + 5 + 9 + 5}, report)
+	sort.Slice(items, func(i, j int) bool { return items[i].Input < items[j].Input })
+	assert.Equal(t, Item{
+		Input: filepath.Join(dir, "a.jpg"), Output: filepath.Join(out, "a.png"), Size: 37,
+	}, items[0])
+	assert.ErrorIs(t, items[1].Err, imagor.ErrNotFound)
+	assert.Equal(t, filepath.Join(out, "sub/b.png"), items[2].Output)
+	assert.Equal(t, filepath.Join(out, "sub/c.png"), items[3].Output)
+	buf, err := os.ReadFile(filepath.Join(out, "sub/b.png"))
+	require.NoError(t, err)
+	assert.Equal(t, append(append([]byte{}, pngHeader...), "sub/b.gif"...), buf)
+
+	// file names only without base dir, keeping extension of unknown format
+	out = t.TempDir()
+	report, err = New(app, imagorpath.Params{}, out).Run(ctx, []string{filepath.Join(dir, "sub/c")})
+	require.NoError(t, err)
+	assert.Equal(t, Report{Processed: 1, Bytes: 5}, report)
+	buf, err = os.ReadFile(filepath.Join(out, "c"))
+	require.NoError(t, err)
+	assert.Equal(t, "sub/c", string(buf))
+	entries, err := os.ReadDir(out)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// output overwriting input
+	report, err = New(app, imagorpath.Params{}, filepath.Join(dir, "sub")).Run(ctx, []string{filepath.Join(dir, "sub/c")})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Failed)
+
+	// same output
+	_, err = New(app, imagorpath.Params{}, out).Run(ctx, []string{
+		filepath.Join(dir, "a.jpg"), filepath.Join(dir, "sub/a.png"),
+	})
+	assert.Error(t, err)
+	// outside base dir
+	_, err = New(app, imagorpath.Params{}, out, WithBaseDir(filepath.Join(dir, "sub"))).Run(ctx, []string{
+		filepath.Join(dir, "a.jpg"),
+	})
+	assert.Error(t, err)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = b.Run(cancelled, inputs)
+	assert.ErrorIs(t, err, context.Canceled)
+}
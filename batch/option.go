@@ -0,0 +1,27 @@
+package batch
+
+// Option Batch option
+type Option func(b *Batch)
+
+// WithBaseDir with base dir option, keeping directories of inputs relative to base dir in output dir
+func WithBaseDir(baseDir string) Option {
+	return func(b *Batch) {
+		b.BaseDir = baseDir
+	}
+}
+
+// WithConcurrency with number of inputs processed concurrently option
+func WithConcurrency(concurrency int) Option {
+	return func(b *Batch) {
+		if concurrency > 0 {
+			b.Concurrency = concurrency
+		}
+	}
+}
+
+// WithProgress with progress callback option, called with the result of each input
+func WithProgress(progress func(item Item)) Option {
+	return func(b *Batch) {
+		b.Progress = progress
+	}
+}
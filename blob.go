@@ -548,6 +548,11 @@ func (b *Blob) BlobType() BlobType {
 	return b.blobType
 }
 
+// Extension returns file extension of BlobType e.g. ".jpg", or empty if unknown
+func (b *Blob) Extension() string {
+	return getExtension(b.BlobType())
+}
+
 // Sniff returns first 512 bytes of blob data for type sniffing
 func (b *Blob) Sniff() []byte {
 	b.init()
@@ -160,6 +160,7 @@ func TestBlobTypes(t *testing.T) {
 			assert.Equal(t, filepath, b.FilePath())
 			assert.Equal(t, tt.bytesType, b.BlobType())
 			assert.Equal(t, tt.extension, getExtension(b.BlobType()))
+			assert.Equal(t, tt.extension, b.Extension())
 			assert.False(t, b.IsEmpty())
 			assert.NotEmpty(t, b.Sniff())
 			assert.NotEmpty(t, b.Size())
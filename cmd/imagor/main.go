@@ -1,22 +1,75 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
 
 	"github.com/cshum/imagor/config"
 	"github.com/cshum/imagor/config/awsconfig"
+	"github.com/cshum/imagor/config/azureconfig"
 	"github.com/cshum/imagor/config/gcloudconfig"
+	"github.com/cshum/imagor/config/redisconfig"
+	"github.com/cshum/imagor/config/sftpconfig"
 	"github.com/cshum/imagor/config/vipsconfig"
 )
 
+var funcs = []config.Option{
+	vipsconfig.WithVips,
+	redisconfig.WithRedis, // Redis Result Storage in front of slower result storages
+	awsconfig.WithAWS,
+	gcloudconfig.WithGCloud,
+	azureconfig.WithAzure,
+	sftpconfig.WithSFTP,
+}
+
 func main() {
-	var server = config.CreateServer(
-		os.Args[1:],
-		vipsconfig.WithVips,
-		awsconfig.WithAWS,
-		gcloudconfig.WithGCloud,
-	)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			os.Exit(run(func(ctx context.Context) error {
+				return config.Migrate(ctx, os.Args[2:], os.Stdout, funcs...)
+			}))
+		case "gc":
+			os.Exit(run(func(ctx context.Context) error {
+				return config.GC(ctx, os.Args[2:], os.Stdout, funcs...)
+			}))
+		case "warm":
+			os.Exit(run(func(ctx context.Context) error {
+				return config.Warm(ctx, os.Args[2:], os.Stdin, os.Stdout, funcs...)
+			}))
+		case "reshard":
+			os.Exit(run(func(ctx context.Context) error {
+				return config.Reshard(ctx, os.Args[2:], os.Stdout, funcs...)
+			}))
+		case "process":
+			os.Exit(run(func(ctx context.Context) error {
+				return config.Process(ctx, os.Args[2:], os.Stdout, funcs...)
+			}))
+		case "config":
+			os.Exit(run(func(ctx context.Context) error {
+				if len(os.Args) < 3 || os.Args[2] != "check" {
+					return errors.New("usage: imagor config check [flags]")
+				}
+				return config.Check(os.Args[3:], os.Stdout, funcs...)
+			}))
+		}
+	}
+	var server = config.CreateServer(os.Args[1:], funcs...)
 	if server != nil {
 		server.Run()
 	}
 }
+
+// run runs subcommand until interrupted, returning exit code
+func run(fn func(ctx context.Context) error) int {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	if err := fn(ctx); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
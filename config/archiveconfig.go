@@ -0,0 +1,44 @@
+package config
+
+import (
+	"flag"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/loader/archiveloader"
+	"go.uber.org/zap"
+)
+
+// withArchiveLoader with Archive Loader config option.
+// It reads archives through the storages and loaders configured before it
+func withArchiveLoader(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+	var (
+		archiveLoaderEnable = fs.Bool("archive-loader-enable", false,
+			"Enable Archive Loader serving images inside zip and tar archives e.g. bundles/set42.zip!/img/001.jpg")
+		archiveLoaderSeparator = fs.String("archive-loader-separator", "!/",
+			"Archive Loader separator between archive key and entry name")
+		archiveLoaderCacheSize = fs.Int64("archive-loader-cache-size", 32<<20,
+			"Archive Loader archive directory cache size in bytes. Set 0 to disable cache")
+		archiveLoaderCacheTTL = fs.Duration("archive-loader-cache-ttl", time.Hour,
+			"Archive Loader archive directory cache TTL")
+	)
+	_, _ = cb()
+	return func(app *imagor.Imagor) {
+		if !*archiveLoaderEnable {
+			return
+		}
+		var loaders []imagor.Loader
+		for _, storage := range app.Storages {
+			loaders = append(loaders, storage)
+		}
+		loaders = append(loaders, app.Loaders...)
+		// Archive Loader goes first, so that archive keys are not passed to other loaders
+		app.Loaders = append([]imagor.Loader{
+			archiveloader.New(loaders,
+				archiveloader.WithSeparator(*archiveLoaderSeparator),
+				archiveloader.WithCacheSize(*archiveLoaderCacheSize),
+				archiveloader.WithCacheTTL(*archiveLoaderCacheTTL),
+			),
+		}, app.Loaders...)
+	}
+}
@@ -3,6 +3,7 @@ package awsconfig
 import (
 	"context"
 	"flag"
+	"fmt"
 	"net"
 	"net/http"
 	"time"
@@ -74,6 +75,8 @@ func WithAWS(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			"Base directory for S3 Loader")
 		s3LoaderPathPrefix = fs.String("s3-loader-path-prefix", "",
 			"Base path prefix for S3 Loader")
+		s3LoaderSSECustomerKey = fs.String("s3-loader-sse-customer-key", "",
+			"S3 Loader base64 encoded 256-bit customer-provided key for SSE-C, required for reading objects")
 		s3LoaderBucketRouterConfig = fs.String("s3-loader-bucket-router-config", "",
 			"YAML config file for S3 Loader bucket routing based on path prefix")
 
@@ -89,6 +92,10 @@ func WithAWS(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			"S3 object tagging query string for S3 Storage writes, e.g. key=value&ttl=generated")
 		s3StorageExpiration = fs.Duration("s3-storage-expiration", 0,
 			"S3 Storage expiration duration e.g. 24h. Default no expiration")
+		s3StorageSSECustomerKey = fs.String("s3-storage-sse-customer-key", "",
+			"S3 Storage base64 encoded 256-bit customer-provided key for SSE-C, required for both reading and writing objects")
+		s3StorageBucketRouterConfig = fs.String("s3-storage-bucket-router-config", "",
+			"YAML config file for S3 Storage bucket routing based on path prefix")
 
 		s3ResultStorageBucket = fs.String("s3-result-storage-bucket", "",
 			"S3 Bucket for S3 Result Storage. Enable S3 Result Storage only if this value present")
@@ -102,8 +109,28 @@ func WithAWS(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			"S3 object tagging query string for S3 Result Storage writes, e.g. key=value&ttl=generated")
 		s3ResultStorageExpiration = fs.Duration("s3-result-storage-expiration", 0,
 			"S3 Result Storage expiration duration e.g. 24h. Default no expiration")
+		s3ResultStoragePublicURL = fs.String("s3-result-storage-public-url", "",
+			"S3 Result Storage public base URL for result redirect e.g. CDN in front of the bucket. Default presigned URL")
+		s3ResultStorageSSECustomerKey = fs.String("s3-result-storage-sse-customer-key", "",
+			"S3 Result Storage base64 encoded 256-bit customer-provided key for SSE-C, required for both reading and writing objects")
+		s3ResultStorageBucketRouterConfig = fs.String("s3-result-storage-bucket-router-config", "",
+			"YAML config file for S3 Result Storage bucket routing based on source image path prefix")
 		s3StorageClass = fs.String("s3-storage-class", "STANDARD",
 			"S3 File Storage Class. Available values: REDUCED_REDUNDANCY, STANDARD_IA, ONEZONE_IA, INTELLIGENT_TIERING, GLACIER, DEEP_ARCHIVE. Default: STANDARD.")
+		s3ServerSideEncryption = fs.String("s3-server-side-encryption", "",
+			"S3 server-side encryption of stored objects. Available values: AES256, aws:kms, aws:kms:dsse. Default bucket encryption")
+		s3SSEKMSKeyID = fs.String("s3-sse-kms-key-id", "",
+			"S3 KMS key ID for aws:kms server-side encryption. Default AWS managed key")
+		s3SSEKMSEncryptionContext = fs.String("s3-sse-kms-encryption-context", "",
+			"S3 KMS encryption context for aws:kms server-side encryption, as JSON object of string values")
+		s3Versioning = fs.Bool("s3-versioning", false,
+			"Enable S3 Loader object version selector in image key e.g. image.jpg?versionId=abc. Storage keeps the selector as part of the key")
+		s3MultipartThreshold = fs.Int64("s3-multipart-threshold", 64<<20,
+			"S3 Storage and Result Storage multipart upload threshold in bytes, for blobs larger than threshold or of unknown size. Set 0 to disable multipart upload")
+		s3MultipartPartSize = fs.Int64("s3-multipart-part-size", 16<<20,
+			"S3 Storage and Result Storage multipart upload part size in bytes, minimum 5MiB")
+		s3MultipartConcurrency = fs.Int("s3-multipart-concurrency", 4,
+			"S3 Storage and Result Storage number of multipart upload parts uploaded concurrently")
 
 		s3HTTPMaxIdleConnsPerHost = fs.Int("s3-http-max-idle-conns-per-host", 100,
 			"S3 HTTP client max idle connections per host (Go default is 2, increase for high-throughput workloads)")
@@ -111,9 +138,26 @@ func WithAWS(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 		_, _ = cb()
 	)
 	return func(app *imagor.Imagor) {
-		if *s3StorageBucket == "" && *s3LoaderBucket == "" && *s3ResultStorageBucket == "" && *s3LoaderBucketRouterConfig == "" {
+		if *s3StorageBucket == "" && *s3LoaderBucket == "" && *s3ResultStorageBucket == "" &&
+			*s3LoaderBucketRouterConfig == "" && *s3StorageBucketRouterConfig == "" &&
+			*s3ResultStorageBucketRouterConfig == "" {
 			return
 		}
+		if err := s3storage.ValidateServerSideEncryption(*s3ServerSideEncryption); err != nil {
+			panic(fmt.Errorf("s3-server-side-encryption: %w", err))
+		}
+		if err := s3storage.ValidateSSEKMSEncryptionContext(*s3SSEKMSEncryptionContext); err != nil {
+			panic(fmt.Errorf("s3-sse-kms-encryption-context: %w", err))
+		}
+		if err := s3storage.ValidateSSECustomerKey(*s3LoaderSSECustomerKey); err != nil {
+			panic(fmt.Errorf("s3-loader-sse-customer-key: %w", err))
+		}
+		if err := s3storage.ValidateSSECustomerKey(*s3StorageSSECustomerKey); err != nil {
+			panic(fmt.Errorf("s3-storage-sse-customer-key: %w", err))
+		}
+		if err := s3storage.ValidateSSECustomerKey(*s3ResultStorageSSECustomerKey); err != nil {
+			panic(fmt.Errorf("s3-result-storage-sse-customer-key: %w", err))
+		}
 
 		ctx := context.Background()
 
@@ -171,14 +215,14 @@ func WithAWS(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 		}
 
 		// Create S3 Storage instances
-		if *s3StorageBucket != "" {
+		if *s3StorageBucket != "" || *s3StorageBucketRouterConfig != "" {
 			// Determine endpoint: service-specific takes priority over global
 			endpoint := *s3StorageEndpoint
 			if endpoint == "" {
 				endpoint = *s3Endpoint
 			}
 
-			storage := s3storage.New(storageCfg, *s3StorageBucket,
+			opts := []s3storage.Option{
 				s3storage.WithPathPrefix(*s3StoragePathPrefix),
 				s3storage.WithBaseDir(*s3StorageBaseDir),
 				s3storage.WithACL(*s3StorageACL),
@@ -186,37 +230,43 @@ func WithAWS(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 				s3storage.WithSafeChars(*s3SafeChars),
 				s3storage.WithExpiration(*s3StorageExpiration),
 				s3storage.WithStorageClass(*s3StorageClass),
+				s3storage.WithMultipartThreshold(*s3MultipartThreshold),
+				s3storage.WithMultipartPartSize(*s3MultipartPartSize),
+				s3storage.WithMultipartConcurrency(*s3MultipartConcurrency),
 				s3storage.WithEndpoint(endpoint),
 				s3storage.WithForcePathStyle(*s3ForcePathStyle),
-			)
-
-			app.Storages = append(app.Storages, storage)
+				s3storage.WithServerSideEncryption(*s3ServerSideEncryption),
+				s3storage.WithSSEKMSKeyID(*s3SSEKMSKeyID),
+				s3storage.WithSSEKMSEncryptionContext(*s3SSEKMSEncryptionContext),
+				s3storage.WithSSECustomerKey(*s3StorageSSECustomerKey),
+			}
+			if *s3StorageBucketRouterConfig != "" {
+				app.Storages = append(app.Storages,
+					newS3Router(storageCfg, *s3StorageBucketRouterConfig, opts))
+			} else {
+				app.Storages = append(app.Storages,
+					s3storage.New(storageCfg, *s3StorageBucket, opts...))
+			}
 		}
 
 		if *s3LoaderBucketRouterConfig != "" {
-			router, err := LoadBucketRouterFromYAML(*s3LoaderBucketRouterConfig)
-			if err != nil {
-				panic(err)
-			}
-
 			endpoint := *s3LoaderEndpoint
 			if endpoint == "" {
 				endpoint = *s3Endpoint
 			}
 
-			storageFactory := func(cfg aws.Config, bucket string, extraOpts ...s3storage.Option) *s3storage.S3Storage {
-				opts := []s3storage.Option{
-					s3storage.WithPathPrefix(*s3LoaderPathPrefix),
-					s3storage.WithBaseDir(*s3LoaderBaseDir),
-					s3storage.WithSafeChars(*s3SafeChars),
-					s3storage.WithEndpoint(endpoint),
-					s3storage.WithForcePathStyle(*s3ForcePathStyle),
-				}
-				opts = append(opts, extraOpts...)
-				return s3storage.New(cfg, bucket, opts...)
-			}
-
-			loader := s3routerloader.New(loaderCfg, router, storageFactory)
+			loader := newS3Router(loaderCfg, *s3LoaderBucketRouterConfig, []s3storage.Option{
+				s3storage.WithPathPrefix(*s3LoaderPathPrefix),
+				s3storage.WithBaseDir(*s3LoaderBaseDir),
+				s3storage.WithSafeChars(*s3SafeChars),
+				s3storage.WithEndpoint(endpoint),
+				s3storage.WithForcePathStyle(*s3ForcePathStyle),
+				s3storage.WithServerSideEncryption(*s3ServerSideEncryption),
+				s3storage.WithSSEKMSKeyID(*s3SSEKMSKeyID),
+				s3storage.WithSSEKMSEncryptionContext(*s3SSEKMSEncryptionContext),
+				s3storage.WithSSECustomerKey(*s3LoaderSSECustomerKey),
+				s3storage.WithVersioning(*s3Versioning),
+			})
 			app.Loaders = append(app.Loaders, loader)
 		} else if *s3LoaderBucket != "" {
 			endpoint := *s3LoaderEndpoint
@@ -230,18 +280,23 @@ func WithAWS(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 				s3storage.WithSafeChars(*s3SafeChars),
 				s3storage.WithEndpoint(endpoint),
 				s3storage.WithForcePathStyle(*s3ForcePathStyle),
+				s3storage.WithServerSideEncryption(*s3ServerSideEncryption),
+				s3storage.WithSSEKMSKeyID(*s3SSEKMSKeyID),
+				s3storage.WithSSEKMSEncryptionContext(*s3SSEKMSEncryptionContext),
+				s3storage.WithSSECustomerKey(*s3LoaderSSECustomerKey),
+				s3storage.WithVersioning(*s3Versioning),
 			)
 			app.Loaders = append(app.Loaders, loader)
 		}
 
-		if *s3ResultStorageBucket != "" {
+		if *s3ResultStorageBucket != "" || *s3ResultStorageBucketRouterConfig != "" {
 			// Determine endpoint: service-specific takes priority over global
 			endpoint := *s3ResultStorageEndpoint
 			if endpoint == "" {
 				endpoint = *s3Endpoint
 			}
 
-			resultStorage := s3storage.New(resultStorageCfg, *s3ResultStorageBucket,
+			opts := []s3storage.Option{
 				s3storage.WithPathPrefix(*s3ResultStoragePathPrefix),
 				s3storage.WithBaseDir(*s3ResultStorageBaseDir),
 				s3storage.WithACL(*s3ResultStorageACL),
@@ -249,13 +304,54 @@ func WithAWS(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 				s3storage.WithSafeChars(*s3SafeChars),
 				s3storage.WithExpiration(*s3ResultStorageExpiration),
 				s3storage.WithStorageClass(*s3StorageClass),
+				s3storage.WithMultipartThreshold(*s3MultipartThreshold),
+				s3storage.WithMultipartPartSize(*s3MultipartPartSize),
+				s3storage.WithMultipartConcurrency(*s3MultipartConcurrency),
 				s3storage.WithEndpoint(endpoint),
 				s3storage.WithForcePathStyle(*s3ForcePathStyle),
-			)
+				s3storage.WithServerSideEncryption(*s3ServerSideEncryption),
+				s3storage.WithSSEKMSKeyID(*s3SSEKMSKeyID),
+				s3storage.WithSSEKMSEncryptionContext(*s3SSEKMSEncryptionContext),
+				s3storage.WithSSECustomerKey(*s3ResultStorageSSECustomerKey),
+			}
+			if *s3ResultStorageBucketRouterConfig != "" {
+				app.ResultStorages = append(app.ResultStorages,
+					newS3Router(resultStorageCfg, *s3ResultStorageBucketRouterConfig, opts))
+			} else {
+				app.ResultStorages = append(app.ResultStorages,
+					s3storage.New(resultStorageCfg, *s3ResultStorageBucket,
+						append(opts, s3storage.WithPublicURL(*s3ResultStoragePublicURL))...))
+			}
+		}
+	}
+}
 
-			app.ResultStorages = append(app.ResultStorages, resultStorage)
+// newS3Router creates S3 router from YAML bucket router config,
+// with per-bucket options applied over opts.
+// SSE-C customer keys of buckets are applied only if customerKeys, i.e. for storages but not loaders
+func newS3Router(cfg aws.Config, routerConfig string, opts []s3storage.Option) *s3routerloader.S3RouterLoader {
+	router, err := LoadBucketRouterFromYAML(routerConfig)
+	if err != nil {
+		panic(err)
+	}
+	keys := make(map[string]string)
+	for _, bucketCfg := range router.AllConfigs() {
+		if err := s3storage.ValidateSSECustomerKey(bucketCfg.SSECustomerKey); err != nil {
+			panic(fmt.Errorf("%s: bucket %s: %w", routerConfig, bucketCfg.Name, err))
+		}
+		if bucketCfg.SSECustomerKey != "" {
+			keys[bucketCfg.Name] = bucketCfg.SSECustomerKey
 		}
 	}
+	return s3routerloader.New(cfg, router, func(
+		cfg aws.Config, bucket string, extraOpts ...s3storage.Option,
+	) *s3storage.S3Storage {
+		bucketOpts := append(append([]s3storage.Option{}, opts...), extraOpts...)
+		if key, ok := keys[bucket]; ok {
+			bucketOpts = append(bucketOpts, s3storage.WithSSECustomerKey(key))
+		}
+		return s3storage.New(cfg, bucket, bucketOpts...)
+	})
 }
 
 func createHTTPClient(maxIdleConnsPerHost int) *http.Client {
@@ -1,12 +1,21 @@
 package awsconfig
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/cshum/imagor"
 	"github.com/cshum/imagor/config"
+	"github.com/cshum/imagor/loader/s3routerloader"
 	"github.com/cshum/imagor/storage/s3storage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestS3Empty(t *testing.T) {
@@ -54,6 +63,13 @@ func TestS3Storage(t *testing.T) {
 		"-s3-result-storage-bucket", "b",
 		"-s3-result-storage-base-dir", "bar",
 		"-s3-result-storage-path-prefix", "bcda",
+		"-s3-result-storage-public-url", "https://cdn.example.com/",
+
+		"-s3-server-side-encryption", "aws:kms",
+		"-s3-sse-kms-key-id", "my-key",
+		"-s3-versioning",
+		"-s3-multipart-threshold", "0",
+		"-s3-multipart-part-size", "8388608",
 	}, WithAWS)
 	app := srv.App.(*imagor.Imagor)
 	assert.Equal(t, 1, len(app.Loaders))
@@ -68,6 +84,18 @@ func TestS3Storage(t *testing.T) {
 	assert.Equal(t, "/bar/", resultStorage.BaseDir)
 	assert.Equal(t, "/bcda/", resultStorage.PathPrefix)
 	assert.Equal(t, "!", resultStorage.SafeChars)
+	assert.Equal(t, "https://cdn.example.com", resultStorage.PublicURL)
+	assert.Empty(t, storage.PublicURL)
+	assert.Equal(t, "aws:kms", storage.ServerSideEncryption)
+	assert.Equal(t, "my-key", storage.SSEKMSKeyID)
+	assert.Equal(t, "aws:kms", resultStorage.ServerSideEncryption)
+	assert.Equal(t, "my-key", resultStorage.SSEKMSKeyID)
+	assert.Empty(t, resultStorage.SSECustomerKey)
+	assert.False(t, storage.Versioning)
+	assert.False(t, resultStorage.Versioning)
+	assert.Equal(t, int64(0), storage.MultipartThreshold)
+	assert.Equal(t, int64(8388608), resultStorage.MultipartPartSize)
+	assert.Equal(t, 4, resultStorage.MultipartConcurrency)
 }
 
 func TestS3SessionOverride(t *testing.T) {
@@ -162,3 +190,148 @@ func TestS3StorageClass(t *testing.T) {
 	storage = app.Storages[0].(*s3storage.S3Storage)
 	assert.Equal(t, "REDUCED_REDUNDANCY", storage.StorageClass)
 }
+
+func TestS3StorageBucketRouter(t *testing.T) {
+	routerConfig := createTempYAML(t, `
+routing_pattern: "^(?P<bucket>[a-z]+)/"
+default_bucket:
+  name: default-bucket
+rules:
+  - match: sg
+    bucket:
+      name: sg-bucket
+      region: ap-southeast-1
+`)
+	srv := config.CreateServer([]string{
+		"-aws-region", "asdf",
+		"-aws-access-key-id", "asdf",
+		"-aws-secret-access-key", "asdf",
+		"-s3-storage-bucket-router-config", routerConfig,
+		"-s3-result-storage-bucket-router-config", routerConfig,
+	}, WithAWS)
+	app := srv.App.(*imagor.Imagor)
+	assert.Equal(t, 1, len(app.Loaders))
+	assert.IsType(t, &s3routerloader.S3RouterLoader{}, app.Storages[0])
+	assert.IsType(t, &s3routerloader.S3RouterLoader{}, app.ResultStorages[0])
+}
+
+func TestS3NamedLoaders(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+loaders:
+  - name: eu
+    type: s3
+    options:
+      aws-region: eu-west-1
+      aws-access-key-id: asdf
+      aws-secret-access-key: asdf
+      s3-loader-bucket: images-eu
+  - name: us
+    type: s3
+    options:
+      aws-region: us-east-1
+      aws-access-key-id: asdf
+      aws-secret-access-key: asdf
+      s3-loader-bucket: images-us
+`), 0644))
+	srv := config.CreateServer([]string{"-config", file}, WithAWS)
+	app := srv.App.(*imagor.Imagor)
+	assert.Len(t, app.Loaders, 2)
+	assert.Equal(t, "images-eu", app.Loaders[0].(*s3storage.S3Storage).Bucket)
+	assert.Equal(t, "images-us", app.Loaders[1].(*s3storage.S3Storage).Bucket)
+}
+
+func TestS3SSECustomerKey(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	routerConfig := createTempYAML(t, `
+routing_pattern: "^(?P<bucket>[a-z]+)/"
+default_bucket:
+  name: default-bucket
+rules:
+  - match: sg
+    bucket:
+      name: sg-bucket
+      sse_customer_key: `+key+`
+`)
+	srv := config.CreateServer([]string{
+		"-aws-region", "asdf",
+		"-aws-access-key-id", "asdf",
+		"-aws-secret-access-key", "asdf",
+		"-s3-loader-bucket-router-config", routerConfig,
+		"-s3-storage-bucket-router-config", routerConfig,
+		"-s3-result-storage-bucket", "b",
+		"-s3-result-storage-sse-customer-key", key,
+	}, WithAWS)
+	app := srv.App.(*imagor.Imagor)
+	ctx := context.Background()
+
+	// presign is not supported with SSE-C
+	loader := app.Loaders[0].(*s3routerloader.S3RouterLoader)
+	_, err := loader.Presign(ctx, "sg/a.jpg", time.Minute)
+	assert.Error(t, err, "customer key applied to loader bucket")
+	_, err = loader.Presign(ctx, "foo/a.jpg", time.Minute)
+	assert.NoError(t, err, "customer key not applied to other loader buckets")
+	storage := app.Storages[0].(*s3routerloader.S3RouterLoader)
+	_, err = storage.Presign(ctx, "sg/a.jpg", time.Minute)
+	assert.Error(t, err, "customer key applied to bucket")
+	_, err = storage.Presign(ctx, "foo/a.jpg", time.Minute)
+	assert.NoError(t, err, "customer key not applied to other buckets")
+	assert.Equal(t, key, app.ResultStorages[0].(*s3storage.S3Storage).SSECustomerKey)
+
+	srv = config.CreateServer([]string{
+		"-aws-region", "asdf",
+		"-s3-loader-bucket", "a",
+		"-s3-loader-sse-customer-key", key,
+	}, WithAWS)
+	app = srv.App.(*imagor.Imagor)
+	assert.Equal(t, key, app.Loaders[0].(*s3storage.S3Storage).SSECustomerKey)
+
+	invalidConfig := createTempYAML(t, `
+routing_pattern: "^(?P<bucket>[a-z]+)/"
+default_bucket:
+  name: default-bucket
+  sse_customer_key: Zm9v
+`)
+	assert.PanicsWithError(t, invalidConfig+": bucket default-bucket: s3storage: customer key is not base64 encoded 256-bit key", func() {
+		newS3Router(aws.Config{}, invalidConfig, nil)
+	})
+}
+
+func TestS3InvalidEncryption(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{
+			name: "server-side encryption",
+			args: []string{"-s3-server-side-encryption", "aws:foo"},
+			err:  `s3-server-side-encryption: s3storage: unknown server-side encryption "aws:foo"`,
+		},
+		{
+			name: "encryption context",
+			args: []string{"-s3-sse-kms-encryption-context", "tenant=foo"},
+			err:  "s3-sse-kms-encryption-context: s3storage: encryption context is not JSON object of string values: invalid character 'e' in literal true (expecting 'r')",
+		},
+		{
+			name: "loader customer key",
+			args: []string{"-s3-loader-sse-customer-key", "Zm9v"},
+			err:  "s3-loader-sse-customer-key: s3storage: customer key is not base64 encoded 256-bit key",
+		},
+		{
+			name: "customer key",
+			args: []string{"-s3-result-storage-sse-customer-key", "Zm9v"},
+			err:  "s3-result-storage-sse-customer-key: s3storage: customer key is not base64 encoded 256-bit key",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := config.Check(append([]string{
+				"-aws-region", "asdf",
+				"-s3-storage-bucket", "a",
+			}, tt.args...), &buf, WithAWS)
+			assert.EqualError(t, err, "invalid config: "+tt.err)
+			assert.Equal(t, "error: "+tt.err+"\n", buf.String())
+		})
+	}
+}
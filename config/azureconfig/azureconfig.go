@@ -0,0 +1,120 @@
+package azureconfig
+
+import (
+	"flag"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/storage/azurestorage"
+	"go.uber.org/zap"
+)
+
+// WithAzure with Azure Blob Storage Loader, Storage, Result Storage config option
+func WithAzure(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+	var (
+		azureStorageConnectionString = fs.String("azure-storage-connection-string", "",
+			"Azure Storage connection string. Takes precedence over account name and key e.g. the Azurite emulator connection string")
+		azureStorageAccountName = fs.String("azure-storage-account-name", "",
+			"Azure Storage account name")
+		azureStorageAccountKey = fs.String("azure-storage-account-key", "",
+			"Azure Storage account key. Anonymous access is used if not set")
+		azureStorageEndpoint = fs.String("azure-storage-endpoint", "",
+			"Azure Blob service endpoint. Default https://<account-name>.blob.core.windows.net/")
+		azureSafeChars = fs.String("azure-safe-chars", "",
+			"Azure safe characters to be excluded from image key escape. Set -- for no-op")
+
+		azureLoaderContainer = fs.String("azure-loader-container", "",
+			"Container name for Azure Loader. Enable Azure Loader only if this value present")
+		azureLoaderBaseDir = fs.String("azure-loader-base-dir", "",
+			"Base directory for Azure Loader")
+		azureLoaderPathPrefix = fs.String("azure-loader-path-prefix", "",
+			"Base path prefix for Azure Loader")
+
+		azureStorageContainer = fs.String("azure-storage-container", "",
+			"Container name for Azure Storage. Enable Azure Storage only if this value present")
+		azureStorageBaseDir = fs.String("azure-storage-base-dir", "",
+			"Base directory for Azure Storage")
+		azureStoragePathPrefix = fs.String("azure-storage-path-prefix", "",
+			"Base path prefix for Azure Storage")
+		azureStorageAccessTier = fs.String("azure-storage-access-tier", "",
+			"Azure Storage blob access tier. Available values: Hot, Cool, Cold, Archive. Default account tier")
+		azureStorageExpiration = fs.Duration("azure-storage-expiration", 0,
+			"Azure Storage expiration duration e.g. 24h. Default no expiration")
+
+		azureResultStorageContainer = fs.String("azure-result-storage-container", "",
+			"Container name for Azure Result Storage. Enable Azure Result Storage only if this value present")
+		azureResultStorageBaseDir = fs.String("azure-result-storage-base-dir", "",
+			"Base directory for Azure Result Storage")
+		azureResultStoragePathPrefix = fs.String("azure-result-storage-path-prefix", "",
+			"Base path prefix for Azure Result Storage")
+		azureResultStorageAccessTier = fs.String("azure-result-storage-access-tier", "",
+			"Azure Result Storage blob access tier. Available values: Hot, Cool, Cold, Archive. Default account tier")
+		azureResultStorageExpiration = fs.Duration("azure-result-storage-expiration", 0,
+			"Azure Result Storage expiration duration e.g. 24h. Default no expiration")
+
+		_, _ = cb()
+	)
+	return func(app *imagor.Imagor) {
+		if *azureStorageContainer == "" && *azureLoaderContainer == "" && *azureResultStorageContainer == "" {
+			return
+		}
+		client, err := newClient(
+			*azureStorageConnectionString, *azureStorageAccountName,
+			*azureStorageAccountKey, *azureStorageEndpoint)
+		if err != nil {
+			panic(err)
+		}
+		if *azureStorageContainer != "" {
+			// activate Azure Storage only if container config presents
+			app.Storages = append(app.Storages,
+				azurestorage.New(client, *azureStorageContainer,
+					azurestorage.WithPathPrefix(*azureStoragePathPrefix),
+					azurestorage.WithBaseDir(*azureStorageBaseDir),
+					azurestorage.WithAccessTier(*azureStorageAccessTier),
+					azurestorage.WithSafeChars(*azureSafeChars),
+					azurestorage.WithExpiration(*azureStorageExpiration),
+				),
+			)
+		}
+		if *azureLoaderContainer != "" {
+			// activate Azure Loader only if container config presents
+			app.Loaders = append(app.Loaders,
+				azurestorage.New(client, *azureLoaderContainer,
+					azurestorage.WithPathPrefix(*azureLoaderPathPrefix),
+					azurestorage.WithBaseDir(*azureLoaderBaseDir),
+					azurestorage.WithSafeChars(*azureSafeChars),
+				),
+			)
+		}
+		if *azureResultStorageContainer != "" {
+			// activate Azure Result Storage only if container config presents
+			app.ResultStorages = append(app.ResultStorages,
+				azurestorage.New(client, *azureResultStorageContainer,
+					azurestorage.WithPathPrefix(*azureResultStoragePathPrefix),
+					azurestorage.WithBaseDir(*azureResultStorageBaseDir),
+					azurestorage.WithAccessTier(*azureResultStorageAccessTier),
+					azurestorage.WithSafeChars(*azureSafeChars),
+					azurestorage.WithExpiration(*azureResultStorageExpiration),
+				),
+			)
+		}
+	}
+}
+
+func newClient(connectionString, accountName, accountKey, endpoint string) (*azblob.Client, error) {
+	if connectionString != "" {
+		return azblob.NewClientFromConnectionString(connectionString, nil)
+	}
+	if endpoint == "" {
+		endpoint = "https://" + accountName + ".blob.core.windows.net/"
+	}
+	if accountName != "" && accountKey != "" {
+		cred, err := azblob.NewSharedKeyCredential(accountName, accountKey)
+		if err != nil {
+			return nil, err
+		}
+		return azblob.NewClientWithSharedKeyCredential(endpoint, cred, nil)
+	}
+	// anonymous access for public containers, or SAS token embedded in endpoint
+	return azblob.NewClientWithNoCredential(endpoint, nil)
+}
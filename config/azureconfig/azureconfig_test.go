@@ -0,0 +1,79 @@
+package azureconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/config"
+	"github.com/cshum/imagor/storage/azurestorage"
+	"github.com/stretchr/testify/assert"
+)
+
+const azuriteConnectionString = "DefaultEndpointsProtocol=http;" +
+	"AccountName=devstoreaccount1;" +
+	"AccountKey=Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw==;" +
+	"BlobEndpoint=http://127.0.0.1:10000/devstoreaccount1;"
+
+func TestAzureEmpty(t *testing.T) {
+	srv := config.CreateServer([]string{}, WithAzure)
+	app := srv.App.(*imagor.Imagor)
+	assert.Equal(t, 1, len(app.Loaders))
+	assert.Empty(t, app.Storages)
+	assert.Empty(t, app.ResultStorages)
+}
+
+func TestAzureLoader(t *testing.T) {
+	srv := config.CreateServer([]string{
+		"-azure-storage-connection-string", azuriteConnectionString,
+		"-azure-safe-chars", "!",
+
+		"-azure-loader-container", "a",
+		"-azure-loader-base-dir", "foo",
+		"-azure-loader-path-prefix", "abcd",
+	}, WithAzure)
+	app := srv.App.(*imagor.Imagor)
+	loader := app.Loaders[0].(*azurestorage.AzureStorage)
+	assert.Equal(t, "a", loader.Container)
+	assert.Equal(t, "/foo/", loader.BaseDir)
+	assert.Equal(t, "/abcd/", loader.PathPrefix)
+	assert.Equal(t, "!", loader.SafeChars)
+	assert.Equal(t, "http://127.0.0.1:10000/devstoreaccount1/", loader.Client.URL())
+}
+
+func TestAzureStorage(t *testing.T) {
+	srv := config.CreateServer([]string{
+		"-azure-storage-account-name", "myaccount",
+		"-azure-storage-account-key", "bXlrZXk=",
+		"-azure-safe-chars", "!",
+
+		"-azure-storage-container", "a",
+		"-azure-storage-base-dir", "foo",
+		"-azure-storage-path-prefix", "abcd",
+		"-azure-storage-access-tier", "cool",
+		"-azure-storage-expiration", "24h",
+
+		"-azure-result-storage-container", "b",
+		"-azure-result-storage-base-dir", "bar",
+		"-azure-result-storage-path-prefix", "bcda",
+		"-azure-result-storage-expiration", "1h",
+	}, WithAzure)
+	app := srv.App.(*imagor.Imagor)
+	assert.Equal(t, 1, len(app.Loaders))
+	storage := app.Storages[0].(*azurestorage.AzureStorage)
+	assert.Equal(t, "a", storage.Container)
+	assert.Equal(t, "/foo/", storage.BaseDir)
+	assert.Equal(t, "/abcd/", storage.PathPrefix)
+	assert.Equal(t, "!", storage.SafeChars)
+	assert.Equal(t, "Cool", storage.AccessTier)
+	assert.Equal(t, time.Hour*24, storage.Expiration)
+	assert.Equal(t, "https://myaccount.blob.core.windows.net/", storage.Client.URL())
+
+	resultStorage := app.ResultStorages[0].(*azurestorage.AzureStorage)
+	assert.Equal(t, "b", resultStorage.Container)
+	assert.Equal(t, "/bar/", resultStorage.BaseDir)
+	assert.Equal(t, "/bcda/", resultStorage.PathPrefix)
+	assert.Equal(t, "!", resultStorage.SafeChars)
+	assert.Empty(t, resultStorage.AccessTier)
+	assert.Equal(t, time.Hour, resultStorage.Expiration)
+}
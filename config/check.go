@@ -0,0 +1,266 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/gc"
+	"github.com/cshum/imagor/loader/archiveloader"
+	"github.com/cshum/imagor/storage/encryptedstorage"
+	"github.com/cshum/imagor/storage/mirrorstorage"
+	"github.com/cshum/imagor/storage/tieredstorage"
+	"go.uber.org/zap"
+)
+
+// checkIssue invalid or conflicting setting found by config check
+type checkIssue struct {
+	Invalid bool
+	Flag    string
+	Message string
+}
+
+func (i checkIssue) String() string {
+	level := "warning"
+	if i.Invalid {
+		level = "error"
+	}
+	return fmt.Sprintf("%s: %s: %s", level, i.Flag, i.Message)
+}
+
+// checkEnums accepted values of flags that silently fall back to default otherwise
+var checkEnums = []struct {
+	Flag   string
+	Values []string
+}{
+	{"imagor-signer-type", []string{"sha1", "sha256", "sha512"}},
+	{"imagor-storage-path-style", []string{"original", "digest"}},
+	{"imagor-result-storage-path-style", []string{"original", "digest", "suffix", "size"}},
+	{"imagor-result-redirect", []string{"0", "302", "307"}},
+	{"vips-cache-format", []string{"pixel", "png", "webp"}},
+}
+
+// secretFlagSuffixes suffixes of flag names holding secrets, redacted on config check
+var secretFlagSuffixes = []string{
+	"secret", "secret-access-key", "access-key-id", "session-token", "token",
+	"password", "passphrase", "private-key", "account-key", "customer-key",
+	"connection-string", "encryption-key", "encryption-keys", "dsn",
+}
+
+// Check runs the config check command, parsing config flags through NewImagor,
+// writing invalid or conflicting settings and the effective setup to w.
+// Returns error if config is invalid
+func Check(args []string, w io.Writer, funcs ...Option) (err error) {
+	var (
+		fs     = flag.NewFlagSet("imagor config check", flag.ExitOnError)
+		logger = zap.NewNop()
+		app    *imagor.Imagor
+		rl     *reloader
+
+		debug = fs.Bool("debug", false, "Debug mode")
+		_     = fs.String("config", ".env", "Retrieve configuration from the given file")
+	)
+	func() {
+		defer func() {
+			// config options panic on invalid values
+			if r := recover(); r != nil {
+				if e, ok := r.(error); ok {
+					err = e
+				} else {
+					err = fmt.Errorf("%v", r)
+				}
+			}
+		}()
+		app, rl = newImagor(fs, func() (*zap.Logger, bool) {
+			if err := parseFlags(fs, args); err != nil {
+				panic(err)
+			}
+			return logger, *debug
+		}, funcs...)
+	}()
+	if err != nil {
+		_, _ = fmt.Fprintf(w, "error: %s\n", err)
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	var invalid int
+	for _, issue := range checkFlags(fs) {
+		if issue.Invalid {
+			invalid++
+		}
+		_, _ = fmt.Fprintln(w, issue)
+	}
+	writeSetup(w, fs, app, rl.names)
+	if invalid > 0 {
+		return fmt.Errorf("%d invalid settings", invalid)
+	}
+	return nil
+}
+
+// checkFlags returns invalid or conflicting settings of flags parsed in fs
+func checkFlags(fs *flag.FlagSet) (issues []checkIssue) {
+	var (
+		get = func(name string) string {
+			if f := fs.Lookup(name); f != nil {
+				return f.Value.String()
+			}
+			return ""
+		}
+		duration = func(name string) time.Duration {
+			d, _ := time.ParseDuration(get(name))
+			return d
+		}
+		number = func(name string) int64 {
+			n, _ := strconv.ParseInt(get(name), 10, 64)
+			return n
+		}
+		warn = func(name, format string, args ...any) {
+			issues = append(issues, checkIssue{Flag: name, Message: fmt.Sprintf(format, args...)})
+		}
+	)
+	for _, enum := range checkEnums {
+		if fs.Lookup(enum.Flag) == nil {
+			continue
+		}
+		value := strings.ToLower(get(enum.Flag))
+		var valid bool
+		for _, v := range enum.Values {
+			valid = valid || v == value
+		}
+		if !valid {
+			issues = append(issues, checkIssue{Invalid: true, Flag: enum.Flag, Message: fmt.Sprintf(
+				"unknown value %q, expected %s", get(enum.Flag), strings.Join(enum.Values, ", "))})
+		}
+	}
+
+	unsafe, _ := strconv.ParseBool(get("imagor-unsafe"))
+	if unsafe && get("imagor-secret") != "" {
+		warn("imagor-unsafe", "unsigned URLs are accepted, imagor-secret is not enforced")
+	} else if !unsafe && get("imagor-secret") == "" {
+		warn("imagor-secret", "empty, URL signatures can be generated by anyone")
+	}
+	if timeout := duration("imagor-request-timeout"); timeout > 0 {
+		for _, name := range []string{"imagor-load-timeout", "imagor-save-timeout", "imagor-process-timeout"} {
+			if d := duration(name); d >= timeout {
+				warn(name, "%s not smaller than imagor-request-timeout %s", d, timeout)
+			}
+		}
+	}
+	if number("imagor-process-queue-size") > 0 && number("imagor-process-concurrency") <= 0 {
+		warn("imagor-process-queue-size", "ignored without imagor-process-concurrency")
+	}
+	if disabled, _ := strconv.ParseBool(get("http-loader-disable")); disabled {
+		fs.Visit(func(f *flag.Flag) {
+			if strings.HasPrefix(f.Name, "http-loader-") && f.Name != "http-loader-disable" {
+				warn(f.Name, "ignored by http-loader-disable")
+			}
+		})
+	}
+	for _, component := range []string{"loader", "storage", "result-storage"} {
+		bucket := "s3-" + component + "-bucket"
+		if get(bucket) != "" && get("aws-region") == "" && get("aws-"+component+"-region") == "" {
+			warn(bucket, "set without aws-region or aws-%s-region, region resolved by AWS shared config if any", component)
+		}
+	}
+	return
+}
+
+// writeSetup writes the effective loaders, storages, result storages and processors in order,
+// and flags set with secrets redacted
+func writeSetup(w io.Writer, fs *flag.FlagSet, app *imagor.Imagor, names backendNames) {
+	var sections = []struct {
+		Name     string
+		Backends []any
+	}{
+		{"loaders", toAny(app.Loaders)},
+		{"storages", toAny(app.Storages)},
+		{"result storages", toAny(app.ResultStorages)},
+		{"processors", toAny(app.Processors)},
+	}
+	for _, section := range sections {
+		if len(section.Backends) == 0 {
+			_, _ = fmt.Fprintf(w, "%s: none\n", section.Name)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s:\n", section.Name)
+		for i, backend := range section.Backends {
+			writeBackend(w, names, "  ", i, backend)
+		}
+	}
+	if app.ResultCache != nil {
+		_, _ = fmt.Fprintf(w, "result cache: %T\n", app.ResultCache)
+	}
+	if app.ResultAdmission != nil {
+		_, _ = fmt.Fprintf(w, "result admission: %T\n", app.ResultAdmission)
+	}
+	_, _ = fmt.Fprintln(w, "settings:")
+	fs.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		if s, ok := f.Value.(*backendSection); ok {
+			value = fmt.Sprintf("%d entries", len(s.Entries))
+		} else {
+			value = redact(f.Name, value)
+		}
+		_, _ = fmt.Fprintf(w, "  %s: %s\n", f.Name, value)
+	})
+}
+
+// writeBackend writes backend with its wrapped backends indented
+func writeBackend(w io.Writer, names backendNames, indent string, i int, backend any) {
+	label := fmt.Sprintf("%T", backend)
+	if name, ok := names.name(backend); ok {
+		label = name + " " + label
+	}
+	_, _ = fmt.Fprintf(w, "%s%d. %s\n", indent, i+1, label)
+	var wrapped []any
+	switch b := backend.(type) {
+	case *tieredstorage.TieredStorage:
+		for _, tier := range b.Tiers {
+			wrapped = append(wrapped, tier.Storage)
+		}
+	case *mirrorstorage.MirrorStorage:
+		wrapped = toAny(b.Storages)
+	case *encryptedstorage.EncryptedStorage:
+		wrapped = []any{b.Storage}
+	case *gc.Collector:
+		wrapped = []any{b.Storage}
+	case *archiveloader.ArchiveLoader:
+		wrapped = toAny(b.Loaders)
+	}
+	for j, backend := range wrapped {
+		writeBackend(w, names, indent+"   ", j, backend)
+	}
+}
+
+// redact redacts value of secret flag, or passwords of URLs in value
+func redact(name, value string) string {
+	if value == "" {
+		return value
+	}
+	for _, suffix := range secretFlagSuffixes {
+		if name == suffix || strings.HasSuffix(name, "-"+suffix) {
+			return "[redacted]"
+		}
+	}
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	values := strings.Split(value, ",")
+	for i, v := range values {
+		if u, err := url.Parse(strings.TrimSpace(v)); err == nil && u.User != nil {
+			values[i] = u.Redacted()
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+func toAny[T any](items []T) (values []any) {
+	for _, item := range items {
+		values = append(values, item)
+	}
+	return
+}
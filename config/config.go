@@ -4,8 +4,10 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"runtime"
 	"strings"
@@ -16,6 +18,7 @@ import (
 	"github.com/cshum/imagor/imagorpath"
 	"github.com/cshum/imagor/metrics/prometheusmetrics"
 	"github.com/cshum/imagor/server"
+	"github.com/cshum/imagor/warm"
 	"github.com/getsentry/sentry-go"
 	"github.com/peterbourgon/ff/v3"
 	"go.elastic.co/ecszap"
@@ -23,19 +26,40 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-var baseConfig = []Option{
+var backendConfig = []Option{
 	withFileSystem,
+	withWebDAV,
 	withUploadLoader,
 	withHTTPLoader, // HTTP loader should be last as a fallback
 }
 
+// baseConfig returns config options wrapping the backends,
+// with names of backends declared in YAML config sections
+func baseConfig(names backendNames) []Option {
+	return []Option{
+		withMirrorStorage,              // Mirror Storage wraps all storages above as replicas
+		withStorageEncryption,          // Storage Encryption wraps all storages above, encrypting once for all replicas
+		withArchiveLoader,              // Archive Loader wraps all storages and loaders above
+		withResultStorageGC,            // Result Storage GC wraps each result storage above
+		withTieredResultStorage(names), // Tiered Result Storage wraps all result storages above
+		withResultCache,
+		withResultAdmission,
+	}
+}
+
 // NewImagor create imagor from config flags
 func NewImagor(
 	fs *flag.FlagSet, cb func() (*zap.Logger, bool), funcs ...Option,
 ) *imagor.Imagor {
+	app, _ := newImagor(fs, cb, funcs...)
+	return app
+}
+
+// newImagor create imagor from config flags, with reloader of its hot reloadable settings
+func newImagor(
+	fs *flag.FlagSet, cb func() (*zap.Logger, bool), funcs ...Option,
+) (*imagor.Imagor, *reloader) {
 	var (
-		imagorSecret = fs.String("imagor-secret", "",
-			"Secret key for signing imagor URL")
 		imagorUnsafe = fs.Bool("imagor-unsafe", false,
 			"Unsafe imagor that does not require URL signature. Prone to URL tampering")
 		imagorAutoWebP = fs.Bool("imagor-auto-webp", false,
@@ -56,10 +80,6 @@ func NewImagor(
 			"URL to redirect for imagor / base path e.g. https://www.google.com")
 		imagorBaseParams = fs.String("imagor-base-params", "",
 			"imagor endpoint base params that applies to all resulting images e.g. filters:watermark(example.jpg)")
-		imagorProcessConcurrency = fs.Int64("imagor-process-concurrency",
-			-1, "Maximum number of image process to be executed simultaneously. Requests that exceed this limit are put in the queue. Set -1 for no limit")
-		imagorProcessQueueSize = fs.Int64("imagor-process-queue-size",
-			0, "Maximum number of image process that can be put in the queue. Requests that exceed this limit are rejected with HTTP status 429")
 		imagorCacheHeaderTTL = fs.Duration("imagor-cache-header-ttl",
 			time.Hour*24*7, "imagor HTTP Cache-Control header TTL for successful image response")
 		imagorCacheHeaderSWR = fs.Duration("imagor-cache-header-swr",
@@ -68,27 +88,26 @@ func NewImagor(
 			false, "imagor HTTP Cache-Control header no-cache for successful image response")
 		imagorModifiedTimeCheck = fs.Bool("imagor-modified-time-check", false,
 			"Check modified time of result image against the source image. This eliminates stale result but require more lookups")
+		imagorResultRedirect = fs.Int("imagor-result-redirect", 0,
+			"imagor redirect status code on result storage hit, to the public or presigned URL of the result: 302, 307. Default 0 streams the result")
+		imagorResultRedirectExpires = fs.Duration("imagor-result-redirect-expires", time.Hour,
+			"imagor result redirect presigned URL expiry")
 		imagorDisableErrorBody       = fs.Bool("imagor-disable-error-body", false, "imagor disable response body on error")
 		imagorDisableParamsEndpoint  = fs.Bool("imagor-disable-params-endpoint", false, "imagor disable /params endpoint")
 		imagorResponseRawOnError     = fs.Bool("imagor-response-raw-on-error", false, "imagor response with a raw unprocessed and unchecked source image on error")
-		imagorSignerType             = fs.String("imagor-signer-type", "sha1", "imagor URL signature hasher type: sha1, sha256, sha512")
-		imagorSignerTruncate         = fs.Int("imagor-signer-truncate", 0, "imagor URL signature truncate at length")
 		imagorStoragePathStyle       = fs.String("imagor-storage-path-style", "original", "imagor storage path style: original, digest")
 		imagorResultStoragePathStyle = fs.String("imagor-result-storage-path-style", "original", "imagor result storage path style: original, digest, suffix")
 
-		options, logger, isDebug = applyOptions(fs, cb, append(funcs, baseConfig...)...)
+		rl                       = &reloader{names: backendNames{}}
+		backends                 = append(append([]Option{}, funcs...), backendConfig...)
+		options, logger, isDebug = applyOptions(fs, cb, append(append(
+			rl.options(withImagorSettings, backends...), withBackendSections(backends, rl.names), rl.installed()),
+			baseConfig(rl.names)...)...)
 
-		alg          = sha1.New
 		hasher       imagorpath.StorageHasher
 		resultHasher imagorpath.ResultStorageHasher
 	)
 
-	if strings.ToLower(*imagorSignerType) == "sha256" {
-		alg = sha256.New
-	} else if strings.ToLower(*imagorSignerType) == "sha512" {
-		alg = sha512.New
-	}
-
 	if strings.ToLower(*imagorStoragePathStyle) == "digest" {
 		hasher = imagorpath.DigestStorageHasher
 	}
@@ -101,19 +120,15 @@ func NewImagor(
 		resultHasher = imagorpath.SizeSuffixResultStorageHasher
 	}
 
+	rl.logger, rl.isDebug = logger, isDebug
 	return imagor.New(append(
 		options,
-		imagor.WithSigner(imagorpath.NewHMACSigner(
-			alg, *imagorSignerTruncate, *imagorSecret,
-		)),
 		imagor.WithBasePathRedirect(*imagorBasePathRedirect),
 		imagor.WithBaseParams(*imagorBaseParams),
 		imagor.WithRequestTimeout(*imagorRequestTimeout),
 		imagor.WithLoadTimeout(*imagorLoadTimeout),
 		imagor.WithSaveTimeout(*imagorSaveTimeout),
 		imagor.WithProcessTimeout(*imagorProcessTimeout),
-		imagor.WithProcessConcurrency(*imagorProcessConcurrency),
-		imagor.WithProcessQueueSize(*imagorProcessQueueSize),
 		imagor.WithCacheHeaderTTL(*imagorCacheHeaderTTL),
 		imagor.WithCacheHeaderSWR(*imagorCacheHeaderSWR),
 		imagor.WithCacheHeaderNoCache(*imagorCacheHeaderNoCache),
@@ -121,6 +136,8 @@ func NewImagor(
 		imagor.WithAutoAVIF(*imagorAutoAVIF),
 		imagor.WithAutoJPEG(*imagorAutoJPEG),
 		imagor.WithModifiedTimeCheck(*imagorModifiedTimeCheck),
+		imagor.WithResultRedirect(*imagorResultRedirect),
+		imagor.WithResultRedirectExpires(*imagorResultRedirectExpires),
 		imagor.WithDisableErrorBody(*imagorDisableErrorBody),
 		imagor.WithDisableParamsEndpoint(*imagorDisableParamsEndpoint),
 		imagor.WithResponseRawOnError(*imagorResponseRawOnError),
@@ -129,7 +146,41 @@ func NewImagor(
 		imagor.WithUnsafe(*imagorUnsafe),
 		imagor.WithLogger(logger),
 		imagor.WithDebug(isDebug),
-	)...)
+	)...), rl
+}
+
+// withImagorSettings with hot reloadable imagor settings: signer, presets, process concurrency and queue size
+func withImagorSettings(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+	var (
+		imagorSecret = fs.String("imagor-secret", "",
+			"Secret key for signing imagor URL")
+		imagorSignerType     = fs.String("imagor-signer-type", "sha1", "imagor URL signature hasher type: sha1, sha256, sha512")
+		imagorSignerTruncate = fs.Int("imagor-signer-truncate", 0, "imagor URL signature truncate at length")
+		imagorPresets        = fs.String("imagor-presets", "",
+			"imagor named presets of params separated by semicolon, used by warm and process commands e.g. thumb=fit-in/200x200/filters:format(webp);hero=1200x0")
+		imagorProcessConcurrency = fs.Int64("imagor-process-concurrency",
+			-1, "Maximum number of image process to be executed simultaneously. Requests that exceed this limit are put in the queue. Set -1 for no limit")
+		imagorProcessQueueSize = fs.Int64("imagor-process-queue-size",
+			0, "Maximum number of image process that can be put in the queue. Requests that exceed this limit are rejected with HTTP status 429")
+
+		alg = sha1.New
+	)
+	_, _ = cb()
+	return func(app *imagor.Imagor) {
+		if strings.ToLower(*imagorSignerType) == "sha256" {
+			alg = sha256.New
+		} else if strings.ToLower(*imagorSignerType) == "sha512" {
+			alg = sha512.New
+		}
+		imagor.WithOptions(
+			imagor.WithSigner(imagorpath.NewHMACSigner(
+				alg, *imagorSignerTruncate, *imagorSecret,
+			)),
+			imagor.WithPresets(parsePresets(*imagorPresets)),
+			imagor.WithProcessConcurrency(*imagorProcessConcurrency),
+			imagor.WithProcessQueueSize(*imagorProcessQueueSize),
+		)(app)
+	}
 }
 
 // CreateServer create server from config flags. Returns nil on version or help command
@@ -139,6 +190,7 @@ func CreateServer(args []string, funcs ...Option) (srv *server.Server) {
 		logger *zap.Logger
 		err    error
 		app    *imagor.Imagor
+		rl     *reloader
 
 		debug        = fs.Bool("debug", false, "Debug mode")
 		logECS       = fs.Bool("log-ecs", false, "Enable Elastic Common Schema log format")
@@ -151,6 +203,9 @@ func CreateServer(args []string, funcs ...Option) (srv *server.Server) {
 
 		_ = fs.String("config", ".env", "Retrieve configuration from the given file")
 
+		configReloadInterval = fs.Duration("config-reload-interval", 0,
+			"Interval of checking the config file for changes of hot reloadable settings. Config is also reloaded on SIGHUP. Disabled if 0")
+
 		serverAddress = fs.String("server-address", "",
 			"Server address")
 		serverPathPrefix = fs.String("server-path-prefix", "",
@@ -167,16 +222,17 @@ func CreateServer(args []string, funcs ...Option) (srv *server.Server) {
 		prometheusBind      = fs.String("prometheus-bind", "", "Specify address and port to enable Prometheus metrics, e.g. :5000, prom:7000")
 		prometheusPath      = fs.String("prometheus-path", "/", "Prometheus metrics path")
 		prometheusNamespace = fs.String("prometheus-namespace", prometheusmetrics.DefaultNamespace, "Prometheus metrics namespace")
+
+		warmEndpoint = fs.String("warm-endpoint", "",
+			"Path of cache warm-up HTTP endpoint e.g. /_warm, accepting POST requests with warm-endpoint-token. Disabled if empty")
+		warmEndpointToken = fs.String("warm-endpoint-token", "",
+			"Bearer token required by cache warm-up HTTP endpoint")
+
+		newWarmer = warmFlags(fs)
 	)
 
-	app = NewImagor(fs, func() (*zap.Logger, bool) {
-		if err = ff.Parse(fs, args,
-			ff.WithEnvVars(),
-			ff.WithConfigFileFlag("config"),
-			ff.WithIgnoreUndefined(true),
-			ff.WithAllowMissingConfigFile(true),
-			ff.WithConfigFileParser(ff.EnvParser),
-		); err != nil {
+	app, rl = newImagor(fs, func() (*zap.Logger, bool) {
+		if err = parseFlags(fs, args); err != nil {
 			panic(err)
 		}
 
@@ -222,6 +278,10 @@ func CreateServer(args []string, funcs ...Option) (srv *server.Server) {
 		runtime.GOMAXPROCS(*goMaxProcess)
 	}
 
+	if err = rl.init(fs, args); err != nil {
+		panic(err)
+	}
+
 	var pm *prometheusmetrics.PrometheusMetrics
 	if *prometheusBind != "" {
 		pm = prometheusmetrics.New(
@@ -232,6 +292,23 @@ func CreateServer(args []string, funcs ...Option) (srv *server.Server) {
 		)
 	}
 
+	var warmHandler func(http.Handler) http.Handler
+	if *warmEndpoint != "" {
+		if *warmEndpointToken == "" {
+			panic(errors.New("warm-endpoint-token required by warm-endpoint"))
+		}
+		warmer := newWarmer(app, warm.WithToken(*warmEndpointToken))
+		warmHandler = func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == *warmEndpoint {
+					warmer.ServeHTTP(w, r)
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
 	return server.New(app,
 		server.WithAddr(*bind),
 		server.WithPort(*port),
@@ -243,7 +320,36 @@ func CreateServer(args []string, funcs ...Option) (srv *server.Server) {
 		server.WithLogger(logger),
 		server.WithDebug(*debug),
 		server.WithMetrics(pm),
+		server.WithMiddleware(warmHandler),
 		server.WithSentry(*sentryDsn),
+		server.WithReload(rl.Reload, *configReloadInterval),
+	)
+}
+
+// parsePresets parses semicolon separated name=params presets
+func parsePresets(str string) map[string]string {
+	presets := map[string]string{}
+	for _, preset := range strings.Split(str, ";") {
+		if preset = strings.TrimSpace(preset); preset == "" {
+			continue
+		}
+		name, params, ok := strings.Cut(preset, "=")
+		if name = strings.TrimSpace(name); !ok || name == "" {
+			panic(fmt.Errorf("imagor-presets: invalid preset %q", preset))
+		}
+		presets[name] = strings.TrimSpace(params)
+	}
+	return presets
+}
+
+// parseFlags parses args, env vars and config file of the config flag
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	return ff.Parse(fs, args,
+		ff.WithEnvVars(),
+		ff.WithConfigFileFlag("config"),
+		ff.WithIgnoreUndefined(true),
+		ff.WithAllowMissingConfigFile(true),
+		ff.WithConfigFileParser(configFileParser),
 	)
 }
 
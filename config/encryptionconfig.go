@@ -0,0 +1,82 @@
+package config
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/storage/encryptedstorage"
+	"go.uber.org/zap"
+)
+
+// withStorageEncryption with client-side Storage Encryption config option.
+// It wraps all storages configured before it
+func withStorageEncryption(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+	var (
+		storageEncryptionKeys = fs.String("storage-encryption-keys", "",
+			"Storage client-side encryption key ring of base64 encoded AES-256 keys by numeric key ID, comma separated e.g. 1:key1,2:key2. Enables AES-GCM encryption of storages when set")
+		storageEncryptionKeyID = fs.Uint("storage-encryption-key-id", 0,
+			"Storage client-side encryption key ID for encrypting new blobs. Default highest key ID")
+		resultStorageEncryption = fs.Bool("result-storage-encryption", false,
+			"Encrypt result storages with the storage client-side encryption key ring")
+	)
+	_, _ = cb()
+	return func(app *imagor.Imagor) {
+		if *storageEncryptionKeys == "" {
+			return
+		}
+		keys, keyID, err := parseEncryptionKeys(*storageEncryptionKeys)
+		if err != nil {
+			panic(err)
+		}
+		if *storageEncryptionKeyID > 0 {
+			keyID = uint32(*storageEncryptionKeyID)
+		}
+		if _, ok := keys[keyID]; !ok {
+			panic(fmt.Errorf("storage-encryption-key-id: key %d not found", keyID))
+		}
+		encrypt := func(storages []imagor.Storage) []imagor.Storage {
+			encrypted := make([]imagor.Storage, len(storages))
+			for i, storage := range storages {
+				s, err := encryptedstorage.New(storage, keys, keyID)
+				if err != nil {
+					panic(err)
+				}
+				encrypted[i] = s
+			}
+			return encrypted
+		}
+		app.Storages = encrypt(app.Storages)
+		if *resultStorageEncryption {
+			app.ResultStorages = encrypt(app.ResultStorages)
+		}
+	}
+}
+
+// parseEncryptionKeys parses key ring of id:base64key pairs,
+// returning the highest key ID
+func parseEncryptionKeys(s string) (keys map[uint32][]byte, maxID uint32, err error) {
+	keys = map[uint32][]byte{}
+	for _, pair := range strings.Split(s, ",") {
+		id, key, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, 0, fmt.Errorf("storage-encryption-keys: invalid key %q", pair)
+		}
+		keyID, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return nil, 0, fmt.Errorf("storage-encryption-keys: invalid key ID %q", id)
+		}
+		buf, err := base64.StdEncoding.DecodeString(key)
+		if err != nil || len(buf) != 32 {
+			return nil, 0, fmt.Errorf("storage-encryption-keys: key %d is not base64 encoded 256-bit key", keyID)
+		}
+		keys[uint32(keyID)] = buf
+		if uint32(keyID) > maxID {
+			maxID = uint32(keyID)
+		}
+	}
+	return
+}
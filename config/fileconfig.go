@@ -28,6 +28,10 @@ func withFileSystem(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Opti
 			"File Storage write permission")
 		fileStorageExpiration = fs.Duration("file-storage-expiration", 0,
 			"File Storage expiration duration e.g. 24h. Default no expiration")
+		fileStorageShardDepth = fs.Int("file-storage-shard-depth", 0,
+			"File Storage number of hashed subdirectory levels for sharded directory layout. Default no sharding")
+		fileStorageShardWidth = fs.Int("file-storage-shard-width", 2,
+			"File Storage number of hex characters of each hashed subdirectory")
 
 		fileResultStorageBaseDir = fs.String("file-result-storage-base-dir", "",
 			"Base directory for File Result Storage. Enable File Result Storage only if this value present")
@@ -39,6 +43,16 @@ func withFileSystem(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Opti
 			"File Storage write permission")
 		fileResultStorageExpiration = fs.Duration("file-result-storage-expiration", 0,
 			"File Result Storage expiration duration e.g. 24h. Default no expiration")
+		fileResultStorageShardDepth = fs.Int("file-result-storage-shard-depth", 0,
+			"File Result Storage number of hashed subdirectory levels for sharded directory layout. Default no sharding")
+		fileResultStorageShardWidth = fs.Int("file-result-storage-shard-width", 2,
+			"File Result Storage number of hex characters of each hashed subdirectory")
+		fileResultStorageMaxSize = fs.Int64("file-result-storage-max-size", 0,
+			"File Result Storage maximum total size in bytes. Least recently accessed results are evicted when exceeded. Default no limit")
+		fileResultStorageMaxFiles = fs.Int("file-result-storage-max-files", 0,
+			"File Result Storage maximum number of files. Least recently accessed results are evicted when exceeded. Default no limit")
+		fileResultStorageJanitorInterval = fs.Duration("file-result-storage-janitor-interval", 0,
+			"File Result Storage background janitor interval, deleting expired files and enforcing max size and max files. Default 1m if max size or max files is set")
 
 		_, _ = cb()
 	)
@@ -53,6 +67,7 @@ func withFileSystem(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Opti
 					filestorage.WithWritePermission(*fileStorageWritePermission),
 					filestorage.WithSafeChars(*fileSafeChars),
 					filestorage.WithExpiration(*fileStorageExpiration),
+					filestorage.WithShard(*fileStorageShardDepth, *fileStorageShardWidth),
 				),
 			)
 		}
@@ -76,6 +91,10 @@ func withFileSystem(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Opti
 					filestorage.WithWritePermission(*fileResultStorageWritePermission),
 					filestorage.WithSafeChars(*fileSafeChars),
 					filestorage.WithExpiration(*fileResultStorageExpiration),
+					filestorage.WithShard(*fileResultStorageShardDepth, *fileResultStorageShardWidth),
+					filestorage.WithMaxSize(*fileResultStorageMaxSize),
+					filestorage.WithMaxFiles(*fileResultStorageMaxFiles),
+					filestorage.WithJanitorInterval(*fileResultStorageJanitorInterval),
 				),
 			)
 		}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/cshum/imagor/gc"
+	"github.com/cshum/imagor/storage/tieredstorage"
+	"go.uber.org/zap"
+)
+
+// GC runs the result storage garbage collection command from config flags,
+// deleting expired results of the configured result storages once and writing the report to w.
+// Background garbage collection is not started, and result-storage-gc-max-idle is rejected
+// as access is not tracked by the command
+func GC(ctx context.Context, args []string, w io.Writer, funcs ...Option) error {
+	var (
+		fs     = flag.NewFlagSet("imagor gc", flag.ExitOnError)
+		logger = zap.NewNop()
+		err    error
+
+		debug = fs.Bool("debug", false, "Debug mode")
+		_     = fs.String("config", ".env", "Retrieve configuration from the given file")
+
+		gcStorage = fs.String("gc-storage", "",
+			"Result storage to garbage collect. Available values: file, s3, gcloud. Default all")
+		gcQuiet = fs.Bool("gc-quiet", false,
+			"Do not print each deleted result")
+	)
+	app, rl := newImagor(fs, func() (*zap.Logger, bool) {
+		if err = parseFlags(fs, args); err != nil {
+			panic(err)
+		}
+		if *debug {
+			logger = zap.Must(zap.NewDevelopment())
+		}
+		return logger, *debug
+	}, funcs...)
+
+	var collectors []*gc.Collector
+	for _, storage := range app.ResultStorages {
+		if s, ok := storage.(*tieredstorage.TieredStorage); ok {
+			for _, tier := range s.Tiers {
+				if c, ok := tier.Storage.(*gc.Collector); ok {
+					collectors = append(collectors, c)
+				}
+			}
+		} else if c, ok := storage.(*gc.Collector); ok {
+			collectors = append(collectors, c)
+		}
+	}
+	if len(collectors) == 0 {
+		return errors.New("result-storage-gc-max-age required with a listable result storage")
+	}
+	for _, c := range collectors {
+		if c.MaxIdle > 0 {
+			// access is tracked in memory of the imagor server, unknown to the command
+			return errors.New("result-storage-gc-max-idle: not supported by imagor gc, access is only tracked by the imagor server")
+		}
+	}
+	var ran, failed int
+	for _, c := range collectors {
+		name := rl.names.storageName(c)
+		if *gcStorage != "" && name != *gcStorage {
+			continue
+		}
+		ran++
+		c.Progress = func(item gc.Item) {
+			if item.Status == gc.StatusFailed {
+				_, _ = fmt.Fprintf(w, "failed %s: %s\n", item.Key, item.Err)
+			} else if !*gcQuiet {
+				_, _ = fmt.Fprintln(w, item.Key)
+			}
+		}
+		report, err := c.Run(ctx)
+		deleted := "deleted"
+		if c.DryRun {
+			deleted = "to be deleted"
+		}
+		_, _ = fmt.Fprintf(w, "%s: %d listed, %d %s (%d bytes), %d failed\n",
+			name, report.Listed, report.Deleted, deleted, report.Bytes, report.Failed)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		failed += report.Failed
+	}
+	if ran == 0 {
+		return fmt.Errorf("gc-storage: result storage %s not configured", *gcStorage)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d results failed to delete", failed)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"flag"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/gc"
+	"go.uber.org/zap"
+)
+
+// withResultStorageGC with Result Storage garbage collection config option.
+// It wraps each listable result storage configured before it
+func withResultStorageGC(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+	var (
+		resultStorageGCMaxAge = fs.Duration("result-storage-gc-max-age", 0,
+			"Result Storage garbage collection deletes results modified longer than the duration ago. Set 0 to disable")
+		resultStorageGCMaxIdle = fs.Duration("result-storage-gc-max-idle", 0,
+			"Result Storage garbage collection deletes results not accessed within the duration, with access tracked in memory since start. Single imagor instance only, not supported by imagor gc command. Set 0 to disable")
+		resultStorageGCInterval = fs.Duration("result-storage-gc-interval", 0,
+			"Result Storage garbage collection interval of running in background. Set 0 to run with imagor gc command only")
+		resultStorageGCBatchSize = fs.Int("result-storage-gc-batch-size", 1000,
+			"Result Storage garbage collection number of results deleted in each batch")
+		resultStorageGCBatchInterval = fs.Duration("result-storage-gc-batch-interval", 0,
+			"Result Storage garbage collection pause between batches")
+		resultStorageGCConcurrency = fs.Int("result-storage-gc-concurrency", 8,
+			"Result Storage garbage collection number of results deleted concurrently")
+		resultStorageGCDryRun = fs.Bool("result-storage-gc-dry-run", false,
+			"Result Storage garbage collection reports results to be deleted without deleting them")
+
+		logger, _ = cb()
+	)
+	return func(app *imagor.Imagor) {
+		if *resultStorageGCMaxAge <= 0 && *resultStorageGCMaxIdle <= 0 {
+			return
+		}
+		for i, storage := range app.ResultStorages {
+			if _, ok := storage.(imagor.Lister); !ok {
+				continue
+			}
+			app.ResultStorages[i] = gc.New(storage,
+				gc.WithMaxAge(*resultStorageGCMaxAge),
+				gc.WithMaxIdle(*resultStorageGCMaxIdle),
+				gc.WithInterval(*resultStorageGCInterval),
+				gc.WithBatchSize(*resultStorageGCBatchSize),
+				gc.WithBatchInterval(*resultStorageGCBatchInterval),
+				gc.WithConcurrency(*resultStorageGCConcurrency),
+				gc.WithDryRun(*resultStorageGCDryRun),
+				gc.WithLogger(logger),
+			)
+		}
+	}
+}
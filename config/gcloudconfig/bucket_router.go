@@ -0,0 +1,11 @@
+package gcloudconfig
+
+import (
+	"github.com/cshum/imagor/loader/bucketrouter"
+	"github.com/cshum/imagor/loader/gcloudrouterloader"
+)
+
+// LoadBucketRouterFromYAML loads Google Cloud bucket router from YAML config file
+func LoadBucketRouterFromYAML(path string) (*gcloudrouterloader.PatternRouter, error) {
+	return bucketrouter.LoadYAML[gcloudrouterloader.BucketConfig](path)
+}
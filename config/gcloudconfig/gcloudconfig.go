@@ -3,11 +3,15 @@ package gcloudconfig
 import (
 	"context"
 	"flag"
+	"fmt"
+	"sync"
 
 	"cloud.google.com/go/storage"
 	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/loader/gcloudrouterloader"
 	"github.com/cshum/imagor/storage/gcloudstorage"
 	"go.uber.org/zap"
+	"google.golang.org/api/option"
 )
 
 // WithGCloud with Google Cloud Loader, Storage, Result Storage config option
@@ -22,6 +26,10 @@ func WithGCloud(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			"Base directory for Google Cloud Loader")
 		gcloudLoaderPathPrefix = fs.String("gcloud-loader-path-prefix", "",
 			"Base path prefix for Google Cloud Loader")
+		gcloudLoaderEncryptionKey = fs.String("gcloud-loader-encryption-key", "",
+			"Google Cloud Loader base64 encoded 256-bit customer-supplied encryption key (CSEK), required for reading objects")
+		gcloudLoaderBucketRouterConfig = fs.String("gcloud-loader-bucket-router-config", "",
+			"YAML config file for Google Cloud Loader bucket routing based on path prefix")
 
 		gcloudStorageBucket = fs.String("gcloud-storage-bucket", "",
 			"Bucket name for Google Cloud Storage. Enable Google Cloud Storage only if this value present")
@@ -33,6 +41,8 @@ func WithGCloud(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			"Upload ACL for Google Cloud Storage")
 		gcloudStorageExpiration = fs.Duration("gcloud-storage-expiration", 0,
 			"Google Cloud Storage expiration duration e.g. 24h. Default no expiration")
+		gcloudStorageEncryptionKey = fs.String("gcloud-storage-encryption-key", "",
+			"Google Cloud Storage base64 encoded 256-bit customer-supplied encryption key (CSEK), required for both reading and writing objects")
 
 		gcloudResultStorageBucket = fs.String("gcloud-result-storage-bucket", "",
 			"Bucket name for Google Cloud Result Storage. Enable Google Cloud Result Storage only if this value present")
@@ -44,11 +54,29 @@ func WithGCloud(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			"Upload ACL for Google Cloud Result Storage")
 		gcloudResultStorageExpiration = fs.Duration("gcloud-result-storage-expiration", 0,
 			"Google Cloud Result Storage expiration duration e.g. 24h. Default no expiration")
+		gcloudResultStorageEncryptionKey = fs.String("gcloud-result-storage-encryption-key", "",
+			"Google Cloud Result Storage base64 encoded 256-bit customer-supplied encryption key (CSEK), required for both reading and writing objects")
+		gcloudResultStoragePublicURL = fs.String("gcloud-result-storage-public-url", "",
+			"Google Cloud Result Storage public base URL for result redirect e.g. CDN in front of the bucket. Default signed URL")
+		gcloudKMSKeyName = fs.String("gcloud-kms-key-name", "",
+			"Google Cloud KMS key name for customer-managed encryption (CMEK) of stored objects. Default bucket encryption")
+		gcloudVersioning = fs.Bool("gcloud-versioning", false,
+			"Enable Google Cloud Loader object generation selector in image key e.g. image.jpg?versionId=1700000000000000. Storage keeps the selector as part of the key")
 
 		_, _ = cb()
 	)
 	return func(app *imagor.Imagor) {
-		if *gcloudStorageBucket != "" || *gcloudLoaderBucket != "" || *gcloudResultStorageBucket != "" {
+		if *gcloudStorageBucket != "" || *gcloudLoaderBucket != "" || *gcloudResultStorageBucket != "" ||
+			*gcloudLoaderBucketRouterConfig != "" {
+			if err := gcloudstorage.ValidateEncryptionKey(*gcloudLoaderEncryptionKey); err != nil {
+				panic(fmt.Errorf("gcloud-loader-encryption-key: %w", err))
+			}
+			if err := gcloudstorage.ValidateEncryptionKey(*gcloudStorageEncryptionKey); err != nil {
+				panic(fmt.Errorf("gcloud-storage-encryption-key: %w", err))
+			}
+			if err := gcloudstorage.ValidateEncryptionKey(*gcloudResultStorageEncryptionKey); err != nil {
+				panic(fmt.Errorf("gcloud-result-storage-encryption-key: %w", err))
+			}
 			// Activate the session, will panic if credentials are missing
 			// Google cloud uses credentials from GOOGLE_APPLICATION_CREDENTIALS env file
 			gcloudClient, err := storage.NewClient(context.Background())
@@ -63,18 +91,54 @@ func WithGCloud(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 						gcloudstorage.WithBaseDir(*gcloudStorageBaseDir),
 						gcloudstorage.WithACL(*gcloudStorageACL),
 						gcloudstorage.WithSafeChars(*gcloudSafeChars),
+						gcloudstorage.WithKMSKeyName(*gcloudKMSKeyName),
+						gcloudstorage.WithEncryptionKey(*gcloudStorageEncryptionKey),
 						gcloudstorage.WithExpiration(*gcloudStorageExpiration),
 					),
 				)
 			}
 
-			if *gcloudLoaderBucket != "" {
+			if *gcloudLoaderBucketRouterConfig != "" {
+				router, err := LoadBucketRouterFromYAML(*gcloudLoaderBucketRouterConfig)
+				if err != nil {
+					panic(err)
+				}
+				clients := map[string]*storage.Client{"": gcloudClient}
+				var mu sync.Mutex
+				app.Loaders = append(app.Loaders,
+					gcloudrouterloader.New(router, func(cfg *gcloudrouterloader.BucketConfig) *gcloudstorage.GCloudStorage {
+						// clients are shared across buckets of the same credentials
+						mu.Lock()
+						client, ok := clients[cfg.CredentialsFile]
+						if !ok {
+							var err error
+							if client, err = storage.NewClient(context.Background(),
+								option.WithCredentialsFile(cfg.CredentialsFile)); err != nil {
+								panic(err)
+							}
+							clients[cfg.CredentialsFile] = client
+						}
+						mu.Unlock()
+						return gcloudstorage.New(client, cfg.Name,
+							gcloudstorage.WithPathPrefix(*gcloudLoaderPathPrefix),
+							gcloudstorage.WithBaseDir(*gcloudLoaderBaseDir),
+							gcloudstorage.WithSafeChars(*gcloudSafeChars),
+							gcloudstorage.WithKMSKeyName(*gcloudKMSKeyName),
+							gcloudstorage.WithEncryptionKey(*gcloudLoaderEncryptionKey),
+							gcloudstorage.WithVersioning(*gcloudVersioning),
+						)
+					}),
+				)
+			} else if *gcloudLoaderBucket != "" {
 				// activate Google Cloud Loader only if bucket config presents
 				app.Loaders = append(app.Loaders,
 					gcloudstorage.New(gcloudClient, *gcloudLoaderBucket,
 						gcloudstorage.WithPathPrefix(*gcloudLoaderPathPrefix),
 						gcloudstorage.WithBaseDir(*gcloudLoaderBaseDir),
 						gcloudstorage.WithSafeChars(*gcloudSafeChars),
+						gcloudstorage.WithKMSKeyName(*gcloudKMSKeyName),
+						gcloudstorage.WithEncryptionKey(*gcloudLoaderEncryptionKey),
+						gcloudstorage.WithVersioning(*gcloudVersioning),
 					),
 				)
 			}
@@ -86,7 +150,10 @@ func WithGCloud(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 						gcloudstorage.WithBaseDir(*gcloudResultStorageBaseDir),
 						gcloudstorage.WithACL(*gcloudResultStorageACL),
 						gcloudstorage.WithSafeChars(*gcloudSafeChars),
+						gcloudstorage.WithKMSKeyName(*gcloudKMSKeyName),
+						gcloudstorage.WithEncryptionKey(*gcloudResultStorageEncryptionKey),
 						gcloudstorage.WithExpiration(*gcloudResultStorageExpiration),
+						gcloudstorage.WithPublicURL(*gcloudResultStoragePublicURL),
 					),
 				)
 			}
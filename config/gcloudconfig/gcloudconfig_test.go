@@ -1,14 +1,18 @@
 package gcloudconfig
 
 import (
+	"bytes"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/cshum/imagor"
 	"github.com/cshum/imagor/config"
+	"github.com/cshum/imagor/loader/gcloudrouterloader"
 	"github.com/cshum/imagor/storage/gcloudstorage"
 	"github.com/fsouza/fake-gcs-server/fakestorage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func fakeGCSServer() *fakestorage.Server {
@@ -34,6 +38,7 @@ func TestGCSLoader(t *testing.T) {
 		"-gcloud-loader-bucket", "a",
 		"-gcloud-loader-base-dir", "foo",
 		"-gcloud-loader-path-prefix", "abcd",
+		"-gcloud-loader-encryption-key", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=",
 	}, WithGCloud)
 	app := srv.App.(*imagor.Imagor)
 	loader := app.Loaders[0].(*gcloudstorage.GCloudStorage)
@@ -41,6 +46,7 @@ func TestGCSLoader(t *testing.T) {
 	assert.Equal(t, "foo", loader.BaseDir)
 	assert.Equal(t, "/abcd/", loader.PathPrefix)
 	assert.Equal(t, "!", loader.SafeChars)
+	assert.Equal(t, []byte("0123456789abcdef0123456789abcdef"), loader.EncryptionKey)
 }
 
 func TestGCSStorage(t *testing.T) {
@@ -57,6 +63,10 @@ func TestGCSStorage(t *testing.T) {
 		"-gcloud-result-storage-bucket", "b",
 		"-gcloud-result-storage-base-dir", "bar",
 		"-gcloud-result-storage-path-prefix", "bcda",
+		"-gcloud-result-storage-public-url", "https://cdn.example.com",
+
+		"-gcloud-kms-key-name", "projects/p/locations/global/keyRings/r/cryptoKeys/k",
+		"-gcloud-versioning",
 	}, WithGCloud)
 	app := srv.App.(*imagor.Imagor)
 	assert.Equal(t, 1, len(app.Loaders))
@@ -71,4 +81,58 @@ func TestGCSStorage(t *testing.T) {
 	assert.Equal(t, "bar", resultStorage.BaseDir)
 	assert.Equal(t, "/bcda/", resultStorage.PathPrefix)
 	assert.Equal(t, "!", resultStorage.SafeChars)
+	assert.Equal(t, "https://cdn.example.com", resultStorage.PublicURL)
+	assert.Equal(t, "projects/p/locations/global/keyRings/r/cryptoKeys/k", storage.KMSKeyName)
+	assert.Equal(t, "projects/p/locations/global/keyRings/r/cryptoKeys/k", resultStorage.KMSKeyName)
+	assert.Empty(t, resultStorage.EncryptionKey)
+	assert.False(t, storage.Versioning)
+	assert.False(t, resultStorage.Versioning)
+}
+
+func TestGCSLoaderBucketRouter(t *testing.T) {
+	svr := fakeGCSServer()
+	defer svr.Stop()
+
+	routerConfig := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(routerConfig, []byte(`
+routing_pattern: "^(?P<bucket>[a-z]+)/(?P<path>.+)$"
+default_bucket:
+  name: default-bucket
+fallback_buckets:
+  - name: fallback-bucket
+rules:
+  - match: sg
+    bucket:
+      name: sg-bucket
+`), 0644))
+
+	srv := config.CreateServer([]string{
+		"-gcloud-loader-bucket-router-config", routerConfig,
+	}, WithGCloud)
+	app := srv.App.(*imagor.Imagor)
+	assert.Equal(t, 2, len(app.Loaders))
+	assert.IsType(t, &gcloudrouterloader.GCloudRouterLoader{}, app.Loaders[0])
+
+	router, err := LoadBucketRouterFromYAML(routerConfig)
+	require.NoError(t, err)
+	assert.Equal(t, "sg-bucket", router.ConfigFor("sg/foo.jpg").Name)
+	assert.Equal(t, "default-bucket", router.ConfigFor("us/foo.jpg").Name)
+	assert.Equal(t, "foo.jpg", router.KeyFor("sg/foo.jpg"))
+	assert.Equal(t, "fallback-bucket", router.Fallbacks()[0].Name)
+
+	_, err = LoadBucketRouterFromYAML(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestGCSInvalidEncryptionKey(t *testing.T) {
+	svr := fakeGCSServer()
+	defer svr.Stop()
+
+	var buf bytes.Buffer
+	err := config.Check([]string{
+		"-gcloud-storage-bucket", "a",
+		"-gcloud-storage-encryption-key", "Zm9v",
+	}, &buf, WithGCloud)
+	assert.EqualError(t, err, "invalid config: gcloud-storage-encryption-key: gcloudstorage: encryption key is not base64 encoded 256-bit key")
+	assert.Equal(t, "error: gcloud-storage-encryption-key: gcloudstorage: encryption key is not base64 encoded 256-bit key\n", buf.String())
 }
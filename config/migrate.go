@@ -0,0 +1,120 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/migrate"
+	"go.uber.org/zap"
+)
+
+// Migrate runs the storage migration command from config flags,
+// copying every object from one configured storage to another and writing the report to w
+func Migrate(ctx context.Context, args []string, w io.Writer, funcs ...Option) error {
+	var (
+		fs     = flag.NewFlagSet("imagor migrate", flag.ExitOnError)
+		logger = zap.NewNop()
+		err    error
+
+		debug = fs.Bool("debug", false, "Debug mode")
+		_     = fs.String("config", ".env", "Retrieve configuration from the given file")
+
+		migrateFrom = fs.String("migrate-from", "",
+			"Storage to copy objects from. Available values: file, s3, gcloud")
+		migrateTo = fs.String("migrate-to", "",
+			"Storage to copy objects to. Available values: file, s3, gcloud, azure, sftp, webdav, redis. Default same as migrate-from")
+		migrateResultStorage = fs.Bool("migrate-result-storage", false,
+			"Migrate result storages instead of storages")
+		migrateRewriteKeys = fs.Bool("migrate-rewrite-keys", false,
+			"Rewrite keys with the configured imagor-storage-path-style or imagor-result-storage-path-style. Keys of the source storage must be of original path style")
+		migrateConcurrency = fs.Int("migrate-concurrency", 8,
+			"Number of objects copied concurrently")
+		migrateDryRun = fs.Bool("migrate-dry-run", false,
+			"Report objects to be copied without copying them")
+		migrateOverwrite = fs.Bool("migrate-overwrite", false,
+			"Copy objects even if they exist in the destination with the same size. Default skipped, so that migration can be resumed")
+		migrateQuiet = fs.Bool("migrate-quiet", false,
+			"Do not print each copied object")
+	)
+	app, rl := newImagor(fs, func() (*zap.Logger, bool) {
+		if err = parseFlags(fs, args); err != nil {
+			panic(err)
+		}
+		if *debug {
+			logger = zap.Must(zap.NewDevelopment())
+		}
+		return logger, *debug
+	}, funcs...)
+
+	storages := app.Storages
+	if *migrateResultStorage {
+		storages = app.ResultStorages
+	}
+	if *migrateFrom == "" {
+		return errors.New("migrate-from: storage required")
+	}
+	if *migrateTo == "" {
+		*migrateTo = *migrateFrom
+	}
+	from, err := rl.names.findStorage(storages, *migrateFrom)
+	if err != nil {
+		return fmt.Errorf("migrate-from: %w", err)
+	}
+	to, err := rl.names.findStorage(storages, *migrateTo)
+	if err != nil {
+		return fmt.Errorf("migrate-to: %w", err)
+	}
+	var rewrite func(string) (string, error)
+	if *migrateRewriteKeys {
+		if *migrateResultStorage && app.ResultStoragePathStyle != nil {
+			rewrite = migrate.ResultStorageKeys(app.ResultStoragePathStyle)
+		} else if !*migrateResultStorage && app.StoragePathStyle != nil {
+			rewrite = migrate.StorageKeys(app.StoragePathStyle)
+		} else {
+			return errors.New("migrate-rewrite-keys: path style not configured")
+		}
+	} else if from == to {
+		return errors.New("migrate-to: same storage as migrate-from without migrate-rewrite-keys")
+	}
+
+	report, err := migrate.New(from, to,
+		migrate.WithRewrite(rewrite),
+		migrate.WithConcurrency(*migrateConcurrency),
+		migrate.WithDryRun(*migrateDryRun),
+		migrate.WithOverwrite(*migrateOverwrite),
+		migrate.WithProgress(func(item migrate.Item) {
+			if item.Status == migrate.StatusFailed {
+				_, _ = fmt.Fprintf(w, "failed %s: %s\n", item.Key, item.Err)
+			} else if item.Status == migrate.StatusCopied && !*migrateQuiet {
+				_, _ = fmt.Fprintf(w, "%s -> %s\n", item.Key, item.NewKey)
+			}
+		}),
+	).Run(ctx)
+	copied := "copied"
+	if *migrateDryRun {
+		copied = "to be copied"
+	}
+	_, _ = fmt.Fprintf(w, "%d listed, %d %s (%d bytes), %d skipped, %d failed\n",
+		report.Listed, report.Copied, copied, report.Bytes, report.Skipped, report.Failed)
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d objects failed to copy", report.Failed)
+	}
+	return nil
+}
+
+// findStorage finds the first storage of name
+func (n backendNames) findStorage(storages []imagor.Storage, name string) (imagor.Storage, error) {
+	for _, storage := range storages {
+		if n.storageName(storage) == name {
+			return storage, nil
+		}
+	}
+	return nil, fmt.Errorf("storage %s not configured", name)
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"flag"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/storage/mirrorstorage"
+	"go.uber.org/zap"
+)
+
+// withMirrorStorage with Mirror Storage config option.
+// It wraps all storages configured before it as replicas
+func withMirrorStorage(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+	var (
+		mirrorStorageEnable = fs.Bool("mirror-storage-enable", false,
+			"Enable Mirror Storage, writing to all storages as replicas and reading from the fastest healthy one, with a miss in one replica copied from another")
+		mirrorStorageVerifyInterval = fs.Duration("mirror-storage-verify-interval", time.Hour,
+			"Mirror Storage minimum interval of background verification of the same key by comparing replicas. Set 0 to disable background verification")
+		mirrorStorageVerifyETag = fs.Bool("mirror-storage-verify-etag", false,
+			"Mirror Storage compare ETags in addition to sizes on verification. Only applicable for storages with content based ETags e.g. S3 without KMS encryption")
+		mirrorStorageFailureCooldown = fs.Duration("mirror-storage-failure-cooldown", time.Second*30,
+			"Mirror Storage duration a failed replica is skipped for reading")
+		mirrorStorageTimeout = fs.Duration("mirror-storage-timeout", time.Minute,
+			"Mirror Storage timeout of asynchronous repair and verification")
+
+		logger, _ = cb()
+	)
+	return func(app *imagor.Imagor) {
+		if !*mirrorStorageEnable || len(app.Storages) < 2 {
+			return
+		}
+		app.Storages = []imagor.Storage{
+			mirrorstorage.New(app.Storages,
+				mirrorstorage.WithVerifyInterval(*mirrorStorageVerifyInterval),
+				mirrorstorage.WithCompareETag(*mirrorStorageVerifyETag),
+				mirrorstorage.WithFailureCooldown(*mirrorStorageFailureCooldown),
+				mirrorstorage.WithTimeout(*mirrorStorageTimeout),
+				mirrorstorage.WithLogger(logger),
+			),
+		}
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"flag"
+	"fmt"
 
 	"github.com/cshum/imagor"
 	"go.uber.org/zap"
@@ -36,3 +37,39 @@ func applyOptions(
 	}
 	return
 }
+
+// optionFlags returns names of flags defined by option
+func optionFlags(option Option) (names []string) {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	option(fs, func() (*zap.Logger, bool) {
+		return zap.NewNop(), false
+	})
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+	return
+}
+
+// buildOption builds a standalone app by option alone, with its flags set by set
+func buildOption(
+	option Option, set func(fs *flag.FlagSet) error, logger *zap.Logger, isDebug bool,
+) (app *imagor.Imagor, err error) {
+	defer func() {
+		// config options panic on invalid values
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	opt := option(fs, func() (*zap.Logger, bool) {
+		if err = set(fs); err != nil {
+			panic(err)
+		}
+		return logger, isDebug
+	})
+	return imagor.New(opt, imagor.WithLogger(logger)), nil
+}
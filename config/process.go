@@ -0,0 +1,98 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/cshum/imagor/batch"
+	"go.uber.org/zap"
+)
+
+// Process runs the batch processing command from config flags, processing the input files
+// or globs in args through imagor processors into the output dir, and writing the report to w
+func Process(ctx context.Context, args []string, w io.Writer, funcs ...Option) error {
+	var (
+		fs     = flag.NewFlagSet("imagor process", flag.ExitOnError)
+		logger = zap.NewNop()
+		err    error
+
+		debug = fs.Bool("debug", false, "Debug mode")
+		_     = fs.String("config", ".env", "Retrieve configuration from the given file")
+
+		processParams = fs.String("process-params", "",
+			"imagor params applied to input files e.g. fit-in/200x200/filters:format(webp)")
+		processPreset = fs.String("process-preset", "",
+			"imagor preset applied to input files, alternative to process-params")
+		processOutputDir = fs.String("process-output-dir", "",
+			"Output directory of processed files")
+		processBaseDir = fs.String("process-base-dir", "",
+			"Base directory of input files, keeping their directories relative to base directory in output directory. Output file names only if empty")
+		processConcurrency = fs.Int("process-concurrency", 0,
+			"Number of files processed concurrently. Default number of CPUs")
+		processQuiet = fs.Bool("process-quiet", false,
+			"Do not print each processed file")
+	)
+	app := NewImagor(fs, func() (*zap.Logger, bool) {
+		if err = parseFlags(fs, args); err != nil {
+			panic(err)
+		}
+		if *debug {
+			logger = zap.Must(zap.NewDevelopment())
+		}
+		return logger, *debug
+	}, funcs...)
+
+	if *processOutputDir == "" {
+		return errors.New("process-output-dir required")
+	}
+	if fs.NArg() == 0 {
+		return errors.New("input files required")
+	}
+	params := batch.ParseParams(*processParams)
+	if *processPreset != "" {
+		if *processParams != "" {
+			return errors.New("process-params and process-preset cannot be both set")
+		}
+		// placeholder image, so that the last preset segment is not parsed as image
+		if params, err = app.Preset(*processPreset, "_"); err != nil {
+			return fmt.Errorf("process-preset %s: %w", *processPreset, err)
+		}
+		params.Image = ""
+	}
+	inputs, err := batch.Glob(fs.Args()...)
+	if err != nil {
+		return err
+	}
+	var done int
+	b := batch.New(app, params, *processOutputDir,
+		batch.WithBaseDir(*processBaseDir),
+		batch.WithConcurrency(*processConcurrency),
+		batch.WithProgress(func(item batch.Item) {
+			done++
+			if item.Err != nil {
+				_, _ = fmt.Fprintf(w, "[%d/%d] failed %s: %s\n", done, len(inputs), item.Input, item.Err)
+			} else if !*processQuiet {
+				_, _ = fmt.Fprintf(w, "[%d/%d] %s -> %s (%d bytes)\n",
+					done, len(inputs), item.Input, item.Output, item.Size)
+			}
+		}),
+	)
+	if err = app.Startup(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		_ = app.Shutdown(context.Background())
+	}()
+	report, err := b.Run(ctx, inputs)
+	_, _ = fmt.Fprintf(w, "%d processed (%d bytes), %d failed\n", report.Processed, report.Bytes, report.Failed)
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d files failed to process", report.Failed)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package redisconfig
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/storage/redisstorage"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// WithRedis with Redis Result Storage config option.
+// Redis Result Storage should be placed before other result storage config options,
+// so that it is looked up first in front of slower ones
+func WithRedis(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+	var (
+		redisResultStorageAddr = fs.String("redis-result-storage-addr", "",
+			"Redis address for Redis Result Storage e.g. localhost:6379. Comma separated for Redis Cluster. Enable Redis Result Storage only if this value present")
+		redisResultStorageUsername = fs.String("redis-result-storage-username", "",
+			"Redis username for Redis Result Storage")
+		redisResultStoragePassword = fs.String("redis-result-storage-password", "",
+			"Redis password for Redis Result Storage")
+		redisResultStorageDB = fs.Int("redis-result-storage-db", 0,
+			"Redis database for Redis Result Storage")
+		redisResultStorageKeyPrefix = fs.String("redis-result-storage-key-prefix", "imagor:",
+			"Redis key prefix for Redis Result Storage")
+		redisResultStorageExpiration = fs.Duration("redis-result-storage-expiration", 0,
+			"Redis Result Storage TTL expiration duration e.g. 24h. Default no expiration")
+		redisResultStorageMaxSize = fs.Int64("redis-result-storage-max-size", 1<<20,
+			"Redis Result Storage maximum blob size in bytes. Larger results are not stored")
+		redisSafeChars = fs.String("redis-safe-chars", "",
+			"Redis safe characters to be excluded from image key escape. Set -- for no-op")
+
+		_, _ = cb()
+	)
+	return func(app *imagor.Imagor) {
+		if *redisResultStorageAddr == "" {
+			return
+		}
+		client := redis.NewUniversalClient(&redis.UniversalOptions{
+			Addrs:    strings.Split(*redisResultStorageAddr, ","),
+			Username: *redisResultStorageUsername,
+			Password: *redisResultStoragePassword,
+			DB:       *redisResultStorageDB,
+		})
+		app.ResultStorages = append(app.ResultStorages,
+			redisstorage.New(client,
+				redisstorage.WithKeyPrefix(*redisResultStorageKeyPrefix),
+				redisstorage.WithExpiration(*redisResultStorageExpiration),
+				redisstorage.WithMaxSize(*redisResultStorageMaxSize),
+				redisstorage.WithSafeChars(*redisSafeChars),
+			),
+		)
+	}
+}
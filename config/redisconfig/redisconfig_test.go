@@ -0,0 +1,39 @@
+package redisconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/config"
+	"github.com/cshum/imagor/storage/filestorage"
+	"github.com/cshum/imagor/storage/redisstorage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisEmpty(t *testing.T) {
+	srv := config.CreateServer([]string{}, WithRedis)
+	app := srv.App.(*imagor.Imagor)
+	assert.Empty(t, app.ResultStorages)
+}
+
+func TestRedisResultStorage(t *testing.T) {
+	mr := miniredis.RunT(t)
+	srv := config.CreateServer([]string{
+		"-redis-result-storage-addr", mr.Addr(),
+		"-redis-result-storage-key-prefix", "res:",
+		"-redis-result-storage-expiration", "1h",
+		"-redis-result-storage-max-size", "1024",
+		"-redis-safe-chars", "!",
+		"-file-result-storage-base-dir", t.TempDir(),
+	}, WithRedis)
+	app := srv.App.(*imagor.Imagor)
+	assert.Len(t, app.ResultStorages, 2)
+	resultStorage := app.ResultStorages[0].(*redisstorage.RedisStorage)
+	assert.Equal(t, "res:", resultStorage.KeyPrefix)
+	assert.Equal(t, time.Hour, resultStorage.Expiration)
+	assert.Equal(t, int64(1024), resultStorage.MaxSize)
+	assert.Equal(t, "!", resultStorage.SafeChars)
+	assert.IsType(t, &filestorage.FileStorage{}, app.ResultStorages[1])
+}
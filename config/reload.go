@@ -0,0 +1,290 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/cshum/imagor"
+	"go.uber.org/zap"
+)
+
+// reloadFlags flags of hot reloadable settings, swapped on config reload without restart
+var reloadFlags = map[string]bool{
+	"imagor-secret":                     true,
+	"imagor-signer-type":                true,
+	"imagor-signer-truncate":            true,
+	"imagor-presets":                    true,
+	"imagor-process-concurrency":        true,
+	"imagor-process-queue-size":         true,
+	"http-loader-allowed-sources":       true,
+	"http-loader-allowed-source-regexp": true,
+	"vips-disable-filters":              true,
+	"vips-disable-blur":                 true,
+}
+
+// reloader hot reloads settings of reloadFlags from args, env vars and config file,
+// into the app and the loaders and processors configured by flags.
+// Loaders and processors replaced by config sections are not reloaded,
+// and changes of their settings are logged as restart required
+type reloader struct {
+	fs      *flag.FlagSet
+	args    []string
+	logger  *zap.Logger
+	isDebug bool
+	entries []*reloadEntry
+	values  map[string]string
+	lock    sync.Mutex
+	// names of backends declared in config sections, for storage names of the app built
+	names backendNames
+}
+
+// reloadEntry config option of hot reloadable settings, with the instances it configured
+type reloadEntry struct {
+	option     Option
+	app        *imagor.Imagor
+	loaders    []imagor.Loader
+	processors []imagor.Processor
+	// replaced instances not installed, replaced by config sections
+	replaced bool
+}
+
+// options returns options with those defining reloadFlags recorded for reload.
+// settings configures hot reloadable settings of the app itself
+func (rl *reloader) options(settings Option, options ...Option) []Option {
+	var wrapped = []Option{rl.wrap(settings, true)}
+	for _, option := range options {
+		if option == nil {
+			continue
+		}
+		for _, name := range optionFlags(option) {
+			if reloadFlags[name] {
+				option = rl.wrap(option, false)
+				break
+			}
+		}
+		wrapped = append(wrapped, option)
+	}
+	return wrapped
+}
+
+// wrap records the app, or the loaders and processors configured by option
+func (rl *reloader) wrap(option Option, self bool) Option {
+	return func(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+		opt := option(fs, cb)
+		return func(app *imagor.Imagor) {
+			var (
+				entry         = &reloadEntry{option: option}
+				numLoaders    = len(app.Loaders)
+				numProcessors = len(app.Processors)
+			)
+			opt(app)
+			if self {
+				entry.app = app
+			} else {
+				entry.loaders = append(entry.loaders, app.Loaders[numLoaders:]...)
+				entry.processors = append(entry.processors, app.Processors[numProcessors:]...)
+			}
+			rl.entries = append(rl.entries, entry)
+		}
+	}
+}
+
+// installed returns option marking recorded entries replaced,
+// if their loaders or processors are no longer installed after config sections are built
+func (rl *reloader) installed() Option {
+	return func(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+		return func(app *imagor.Imagor) {
+			for _, entry := range rl.entries {
+				for _, loader := range entry.loaders {
+					entry.replaced = entry.replaced || !containsInstance(app.Loaders, loader)
+				}
+				for _, processor := range entry.processors {
+					entry.replaced = entry.replaced || !containsInstance(app.Processors, processor)
+				}
+			}
+		}
+	}
+}
+
+// containsInstance checks if items contains the same instance of item
+func containsInstance[T any](items []T, item T) bool {
+	if t := reflect.TypeOf(item); t == nil || !t.Comparable() {
+		return false
+	}
+	for _, v := range items {
+		// comparable as item type is comparable
+		if any(v) == any(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// replacedFlags returns names of reloadFlags of the replaced entries
+func (rl *reloader) replacedFlags() map[string]bool {
+	names := map[string]bool{}
+	for _, entry := range rl.entries {
+		if !entry.replaced {
+			continue
+		}
+		for _, name := range optionFlags(entry.option) {
+			if reloadFlags[name] {
+				names[name] = true
+			}
+		}
+	}
+	return names
+}
+
+// init takes the current flag values of fs from args, env vars and config file
+func (rl *reloader) init(fs *flag.FlagSet, args []string) (err error) {
+	rl.fs = fs
+	rl.args = args
+	rl.values, err = parseValues(fs, args)
+	return
+}
+
+// Reload parses args, env vars and config file again,
+// swapping hot reloadable settings atomically if changed.
+// Changes of other settings are logged as restart required
+func (rl *reloader) Reload() error {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	values, err := parseValues(rl.fs, rl.args)
+	if err != nil {
+		return err
+	}
+	var (
+		reloaded, restart []string
+		replaced          = rl.replacedFlags()
+	)
+	for _, name := range changedFlags(rl.values, values) {
+		if reloadFlags[name] && !replaced[name] {
+			reloaded = append(reloaded, name)
+		} else {
+			restart = append(restart, name)
+		}
+	}
+	if len(reloaded) > 0 {
+		if err := rl.apply(values); err != nil {
+			return err
+		}
+		rl.logger.Info("config-reload", zap.Strings("flags", reloaded))
+	}
+	if len(restart) > 0 {
+		rl.logger.Warn("config-reload-restart-required", zap.Strings("flags", restart))
+	}
+	rl.values = values
+	return nil
+}
+
+// apply builds each recorded option by values, then reloads the recorded instances from them
+func (rl *reloader) apply(values map[string]string) error {
+	var (
+		targets []imagor.Reloader
+		nexts   []any
+	)
+	for _, entry := range rl.entries {
+		if entry.replaced {
+			continue
+		}
+		next, err := buildOption(entry.option, func(fs *flag.FlagSet) (err error) {
+			fs.VisitAll(func(f *flag.Flag) {
+				if value, ok := values[f.Name]; ok && err == nil {
+					if err = fs.Set(f.Name, value); err != nil {
+						err = fmt.Errorf("%s: %w", f.Name, err)
+					}
+				}
+			})
+			return
+		}, rl.logger, rl.isDebug)
+		if err != nil {
+			return err
+		}
+		if entry.app != nil {
+			targets = append(targets, entry.app)
+			nexts = append(nexts, next)
+			continue
+		}
+		if len(next.Loaders) != len(entry.loaders) || len(next.Processors) != len(entry.processors) {
+			return errors.New("loaders or processors changed, restart required")
+		}
+		for i, loader := range entry.loaders {
+			if r, ok := loader.(imagor.Reloader); ok {
+				targets = append(targets, r)
+				nexts = append(nexts, next.Loaders[i])
+			}
+		}
+		for i, processor := range entry.processors {
+			if r, ok := processor.(imagor.Reloader); ok {
+				targets = append(targets, r)
+				nexts = append(nexts, next.Processors[i])
+			}
+		}
+	}
+	for i, target := range targets {
+		if err := target.Reload(nexts[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rawFlag flag value kept as is, for parsing values of flags regardless of their types
+type rawFlag struct {
+	value  string
+	isBool bool
+}
+
+func (f *rawFlag) String() string {
+	return f.value
+}
+
+func (f *rawFlag) Set(value string) error {
+	f.value = value
+	return nil
+}
+
+func (f *rawFlag) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// parseValues parses values of flags defined in fs from args, env vars and config file,
+// by names of the flags set
+func parseValues(fs *flag.FlagSet, args []string) (map[string]string, error) {
+	raw := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	raw.SetOutput(io.Discard)
+	fs.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		raw.Var(&rawFlag{value: f.DefValue, isBool: ok && b.IsBoolFlag()}, f.Name, f.Usage)
+	})
+	if err := parseFlags(raw, args); err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	raw.Visit(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+	return values, nil
+}
+
+// changedFlags returns sorted names of flags with values changed
+func changedFlags(prev, next map[string]string) (names []string) {
+	for name, value := range next {
+		if v, ok := prev[name]; !ok || v != value {
+			names = append(names, name)
+		}
+	}
+	for name := range prev {
+		if _, ok := next[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/gc"
+	"github.com/cshum/imagor/storage/encryptedstorage"
+	"github.com/cshum/imagor/storage/filestorage"
+	"github.com/cshum/imagor/storage/mirrorstorage"
+	"github.com/cshum/imagor/storage/tieredstorage"
+	"go.uber.org/zap"
+)
+
+// Reshard runs the file storage reshard command from config flags,
+// moving files of the configured File Storage or File Result Storage into its sharded directory layout
+// and writing the report to w
+func Reshard(ctx context.Context, args []string, w io.Writer, funcs ...Option) error {
+	var (
+		fs     = flag.NewFlagSet("imagor reshard", flag.ExitOnError)
+		logger = zap.NewNop()
+		err    error
+
+		debug = fs.Bool("debug", false, "Debug mode")
+		_     = fs.String("config", ".env", "Retrieve configuration from the given file")
+
+		reshardResultStorage = fs.Bool("reshard-result-storage", false,
+			"Reshard File Result Storage instead of File Storage")
+		reshardDryRun = fs.Bool("reshard-dry-run", false,
+			"Report files to be moved without moving them")
+		reshardQuiet = fs.Bool("reshard-quiet", false,
+			"Do not print each moved file")
+	)
+	app := NewImagor(fs, func() (*zap.Logger, bool) {
+		if err = parseFlags(fs, args); err != nil {
+			panic(err)
+		}
+		if *debug {
+			logger = zap.Must(zap.NewDevelopment())
+		}
+		return logger, *debug
+	}, funcs...)
+
+	storages, prefix := app.Storages, "file-storage"
+	if *reshardResultStorage {
+		storages, prefix = app.ResultStorages, "file-result-storage"
+	}
+	s := findFileStorage(storages)
+	if s == nil {
+		return fmt.Errorf("%s-base-dir: storage required", prefix)
+	}
+	if s.ShardDepth == 0 {
+		return fmt.Errorf("%s-shard-depth: sharded directory layout required", prefix)
+	}
+	moved, err := s.Reshard(ctx, *reshardDryRun, func(from, to string) {
+		if !*reshardQuiet {
+			from, _ = filepath.Rel(s.BaseDir, from)
+			to, _ = filepath.Rel(s.BaseDir, to)
+			_, _ = fmt.Fprintf(w, "%s -> %s\n", from, to)
+		}
+	})
+	if *reshardDryRun {
+		_, _ = fmt.Fprintf(w, "%d files to be moved\n", moved)
+	} else {
+		_, _ = fmt.Fprintf(w, "%d files moved\n", moved)
+	}
+	return err
+}
+
+// findFileStorage finds the first File Storage of storages, including those wrapped
+func findFileStorage(storages []imagor.Storage) *filestorage.FileStorage {
+	for _, storage := range storages {
+		var wrapped []imagor.Storage
+		switch s := storage.(type) {
+		case *filestorage.FileStorage:
+			return s
+		case *gc.Collector:
+			wrapped = []imagor.Storage{s.Storage}
+		case *encryptedstorage.EncryptedStorage:
+			wrapped = []imagor.Storage{s.Storage}
+		case *mirrorstorage.MirrorStorage:
+			wrapped = s.Storages
+		case *tieredstorage.TieredStorage:
+			for _, tier := range s.Tiers {
+				wrapped = append(wrapped, tier.Storage)
+			}
+		}
+		if s := findFileStorage(wrapped); s != nil {
+			return s
+		}
+	}
+	return nil
+}
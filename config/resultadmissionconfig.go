@@ -0,0 +1,41 @@
+package config
+
+import (
+	"flag"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/resultadmission"
+	"go.uber.org/zap"
+)
+
+// withResultAdmission with Result Storage write-admission config option
+func withResultAdmission(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+	var (
+		resultStorageAdmissionMinHits = fs.Int("result-storage-admission-min-hits", 0,
+			"Result Storage admission saves results requested at least the number of times within window. Set 0 to disable")
+		resultStorageAdmissionWindow = fs.Duration("result-storage-admission-window", time.Hour,
+			"Result Storage admission window of counting requests")
+		resultStorageAdmissionMinProcessTime = fs.Duration("result-storage-admission-min-process-time", 0,
+			"Result Storage admission saves results taken at least the duration to load and process. Set 0 to disable")
+		resultStorageAdmissionMinSourceSize = fs.Int64("result-storage-admission-min-source-size", 0,
+			"Result Storage admission saves results of source images at least the size in bytes. Set 0 to disable")
+		resultStorageAdmissionSketchWidth = fs.Int("result-storage-admission-sketch-width", 1<<16,
+			"Result Storage admission number of counters of each of the 4 rows of the count-min sketch")
+	)
+	_, _ = cb()
+	return func(app *imagor.Imagor) {
+		if *resultStorageAdmissionMinHits <= 0 &&
+			*resultStorageAdmissionMinProcessTime <= 0 &&
+			*resultStorageAdmissionMinSourceSize <= 0 {
+			return
+		}
+		app.ResultAdmission = resultadmission.New(
+			resultadmission.WithMinHits(*resultStorageAdmissionMinHits),
+			resultadmission.WithWindow(*resultStorageAdmissionWindow),
+			resultadmission.WithMinProcessTime(*resultStorageAdmissionMinProcessTime),
+			resultadmission.WithMinSourceSize(*resultStorageAdmissionMinSourceSize),
+			resultadmission.WithWidth(*resultStorageAdmissionSketchWidth),
+		)
+	}
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"flag"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/resultcache"
+	"go.uber.org/zap"
+)
+
+// withResultCache with in-process Result Cache config option
+func withResultCache(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+	var (
+		resultCacheSize = fs.Int64("result-cache-size", 0,
+			"In-process Result Cache size in bytes, consulted before result storages. Set 0 to disable cache")
+		resultCacheMaxItemSize = fs.Int64("result-cache-max-item-size", 1<<20,
+			"In-process Result Cache maximum size in bytes of a cached result")
+		resultCacheTTL = fs.Duration("result-cache-ttl", time.Hour,
+			"In-process Result Cache TTL")
+	)
+	_, _ = cb()
+	return func(app *imagor.Imagor) {
+		if *resultCacheSize <= 0 {
+			return
+		}
+		cache, err := resultcache.New(
+			resultcache.WithMaxSize(*resultCacheSize),
+			resultcache.WithMaxItemSize(*resultCacheMaxItemSize),
+			resultcache.WithTTL(*resultCacheTTL),
+		)
+		if err != nil {
+			panic(err)
+		}
+		app.ResultCache = cache
+	}
+}
@@ -0,0 +1,166 @@
+package sftpconfig
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/storage/sftpstorage"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// WithSFTP with SFTP Loader, Storage, Result Storage config option
+func WithSFTP(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+	var (
+		sftpAddr = fs.String("sftp-addr", "",
+			"SFTP server address e.g. sftp.example.com:22")
+		sftpUsername = fs.String("sftp-username", "",
+			"SFTP username")
+		sftpPassword = fs.String("sftp-password", "",
+			"SFTP password. Private key auth is preferred if present")
+		sftpPrivateKey = fs.String("sftp-private-key", "",
+			"SFTP private key file path for public key auth")
+		sftpPrivateKeyPassphrase = fs.String("sftp-private-key-passphrase", "",
+			"SFTP private key passphrase, if private key is encrypted")
+		sftpKnownHosts = fs.String("sftp-known-hosts", "",
+			"SFTP known_hosts file path for host key verification")
+		sftpHostKey = fs.String("sftp-host-key", "",
+			"SFTP server public host key in authorized_keys format e.g. ssh-ed25519 AAAA...")
+		sftpInsecureIgnoreHostKey = fs.Bool("sftp-insecure-ignore-host-key", false,
+			"SFTP skip host key verification. Insecure, for testing only")
+		sftpTimeout = fs.Duration("sftp-timeout", time.Second*10,
+			"SFTP connection timeout")
+		sftpPoolSize = fs.Int("sftp-pool-size", 4,
+			"SFTP maximum concurrent connections per loader or storage")
+		sftpSafeChars = fs.String("sftp-safe-chars", "",
+			"SFTP safe characters to be excluded from image key escape. Set -- for no-op")
+
+		sftpLoaderBaseDir = fs.String("sftp-loader-base-dir", "",
+			"Base directory for SFTP Loader. Relative to login directory if not absolute. Enable SFTP Loader only if this value present")
+		sftpLoaderPathPrefix = fs.String("sftp-loader-path-prefix", "",
+			"Base path prefix for SFTP Loader")
+
+		sftpStorageBaseDir = fs.String("sftp-storage-base-dir", "",
+			"Base directory for SFTP Storage. Relative to login directory if not absolute. Enable SFTP Storage only if this value present")
+		sftpStoragePathPrefix = fs.String("sftp-storage-path-prefix", "",
+			"Base path prefix for SFTP Storage")
+		sftpStorageWritePermission = fs.String("sftp-storage-write-permission", "0644",
+			"SFTP Storage write permission")
+		sftpStorageExpiration = fs.Duration("sftp-storage-expiration", 0,
+			"SFTP Storage expiration duration e.g. 24h. Default no expiration")
+
+		sftpResultStorageBaseDir = fs.String("sftp-result-storage-base-dir", "",
+			"Base directory for SFTP Result Storage. Relative to login directory if not absolute. Enable SFTP Result Storage only if this value present")
+		sftpResultStoragePathPrefix = fs.String("sftp-result-storage-path-prefix", "",
+			"Base path prefix for SFTP Result Storage")
+		sftpResultStorageWritePermission = fs.String("sftp-result-storage-write-permission", "0644",
+			"SFTP Result Storage write permission")
+		sftpResultStorageExpiration = fs.Duration("sftp-result-storage-expiration", 0,
+			"SFTP Result Storage expiration duration e.g. 24h. Default no expiration")
+
+		_, _ = cb()
+	)
+	return func(app *imagor.Imagor) {
+		if *sftpLoaderBaseDir == "" && *sftpStorageBaseDir == "" && *sftpResultStorageBaseDir == "" {
+			return
+		}
+		if *sftpAddr == "" {
+			panic(errors.New("sftpconfig: sftp-addr is required"))
+		}
+		clientConfig, err := newClientConfig(
+			*sftpUsername, *sftpPassword, *sftpPrivateKey, *sftpPrivateKeyPassphrase,
+			*sftpKnownHosts, *sftpHostKey, *sftpInsecureIgnoreHostKey, *sftpTimeout)
+		if err != nil {
+			panic(err)
+		}
+		if *sftpStorageBaseDir != "" {
+			// activate SFTP Storage only if base dir config presents
+			app.Storages = append(app.Storages,
+				sftpstorage.New(*sftpAddr, clientConfig,
+					sftpstorage.WithBaseDir(*sftpStorageBaseDir),
+					sftpstorage.WithPathPrefix(*sftpStoragePathPrefix),
+					sftpstorage.WithWritePermission(*sftpStorageWritePermission),
+					sftpstorage.WithSafeChars(*sftpSafeChars),
+					sftpstorage.WithExpiration(*sftpStorageExpiration),
+					sftpstorage.WithPoolSize(*sftpPoolSize),
+				),
+			)
+		}
+		if *sftpLoaderBaseDir != "" {
+			// activate SFTP Loader only if base dir config presents
+			app.Loaders = append(app.Loaders,
+				sftpstorage.New(*sftpAddr, clientConfig,
+					sftpstorage.WithBaseDir(*sftpLoaderBaseDir),
+					sftpstorage.WithPathPrefix(*sftpLoaderPathPrefix),
+					sftpstorage.WithSafeChars(*sftpSafeChars),
+					sftpstorage.WithPoolSize(*sftpPoolSize),
+				),
+			)
+		}
+		if *sftpResultStorageBaseDir != "" {
+			// activate SFTP Result Storage only if base dir config presents
+			app.ResultStorages = append(app.ResultStorages,
+				sftpstorage.New(*sftpAddr, clientConfig,
+					sftpstorage.WithBaseDir(*sftpResultStorageBaseDir),
+					sftpstorage.WithPathPrefix(*sftpResultStoragePathPrefix),
+					sftpstorage.WithWritePermission(*sftpResultStorageWritePermission),
+					sftpstorage.WithSafeChars(*sftpSafeChars),
+					sftpstorage.WithExpiration(*sftpResultStorageExpiration),
+					sftpstorage.WithPoolSize(*sftpPoolSize),
+				),
+			)
+		}
+	}
+}
+
+func newClientConfig(
+	username, password, privateKey, passphrase,
+	knownHostsFile, hostKey string, insecure bool, timeout time.Duration,
+) (*ssh.ClientConfig, error) {
+	config := &ssh.ClientConfig{
+		User:    username,
+		Timeout: timeout,
+	}
+	if privateKey != "" {
+		pem, err := os.ReadFile(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		var signer ssh.Signer
+		if passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(pem)
+		}
+		if err != nil {
+			return nil, err
+		}
+		config.Auth = append(config.Auth, ssh.PublicKeys(signer))
+	}
+	if password != "" {
+		config.Auth = append(config.Auth, ssh.Password(password))
+	}
+	switch {
+	case hostKey != "":
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+		if err != nil {
+			return nil, err
+		}
+		config.HostKeyCallback = ssh.FixedHostKey(pub)
+	case knownHostsFile != "":
+		callback, err := knownhosts.New(knownHostsFile)
+		if err != nil {
+			return nil, err
+		}
+		config.HostKeyCallback = callback
+	case insecure:
+		config.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, errors.New("sftpconfig: sftp-known-hosts or sftp-host-key is required for host key verification")
+	}
+	return config, nil
+}
@@ -0,0 +1,100 @@
+package sftpconfig
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/config"
+	"github.com/cshum/imagor/storage/sftpstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSFTPEmpty(t *testing.T) {
+	srv := config.CreateServer([]string{}, WithSFTP)
+	app := srv.App.(*imagor.Imagor)
+	for _, loader := range app.Loaders {
+		_, ok := loader.(*sftpstorage.SFTPStorage)
+		assert.False(t, ok)
+	}
+	assert.Empty(t, app.Storages)
+	assert.Empty(t, app.ResultStorages)
+}
+
+func TestSFTPLoaderStorageResultStorage(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(key, "")
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600))
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	hostKey := string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+
+	srv := config.CreateServer([]string{
+		"-sftp-addr", "localhost:2222",
+		"-sftp-username", "imagor",
+		"-sftp-private-key", keyFile,
+		"-sftp-host-key", hostKey,
+		"-sftp-pool-size", "8",
+		"-sftp-safe-chars", "!",
+
+		"-sftp-loader-base-dir", "/loader",
+		"-sftp-loader-path-prefix", "/abc",
+
+		"-sftp-storage-base-dir", "/storage",
+		"-sftp-storage-path-prefix", "/def",
+		"-sftp-storage-write-permission", "0600",
+		"-sftp-storage-expiration", "1h",
+
+		"-sftp-result-storage-base-dir", "results",
+		"-sftp-result-storage-path-prefix", "/ghi",
+		"-sftp-result-storage-expiration", "2h",
+	}, WithSFTP)
+	app := srv.App.(*imagor.Imagor)
+
+	loader := app.Loaders[0].(*sftpstorage.SFTPStorage)
+	assert.Equal(t, "localhost:2222", loader.Addr)
+	assert.Equal(t, "/loader", loader.BaseDir)
+	assert.Equal(t, "/abc/", loader.PathPrefix)
+	assert.Equal(t, 8, loader.PoolSize)
+	assert.Equal(t, "!", loader.SafeChars)
+
+	storage := app.Storages[0].(*sftpstorage.SFTPStorage)
+	assert.Equal(t, "/storage", storage.BaseDir)
+	assert.Equal(t, "/def/", storage.PathPrefix)
+	assert.Equal(t, os.FileMode(0600), storage.WritePermission)
+	assert.Equal(t, time.Hour, storage.Expiration)
+
+	resultStorage := app.ResultStorages[0].(*sftpstorage.SFTPStorage)
+	assert.Equal(t, "results", resultStorage.BaseDir)
+	assert.Equal(t, "/ghi/", resultStorage.PathPrefix)
+	assert.Equal(t, os.FileMode(0644), resultStorage.WritePermission)
+	assert.Equal(t, time.Hour*2, resultStorage.Expiration)
+}
+
+func TestSFTPHostKeyRequired(t *testing.T) {
+	assert.Panics(t, func() {
+		config.CreateServer([]string{
+			"-sftp-addr", "localhost:2222",
+			"-sftp-password", "pass",
+			"-sftp-loader-base-dir", "/loader",
+		}, WithSFTP)
+	})
+	assert.NotPanics(t, func() {
+		config.CreateServer([]string{
+			"-sftp-addr", "localhost:2222",
+			"-sftp-password", "pass",
+			"-sftp-insecure-ignore-host-key",
+			"-sftp-loader-base-dir", "/loader",
+		}, WithSFTP)
+	})
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/gc"
+	"github.com/cshum/imagor/storage/encryptedstorage"
+	"github.com/cshum/imagor/storage/tieredstorage"
+	"go.uber.org/zap"
+)
+
+// withTieredResultStorage with Tiered Result Storage config option.
+// It wraps all result storages configured before it into tiers,
+// ordered by storage names including names declared in YAML config sections
+func withTieredResultStorage(names backendNames) Option {
+	return func(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+		var (
+			tieredResultStorageEnable = fs.Bool("tiered-result-storage-enable", false,
+				"Enable Tiered Result Storage, with a hit in a slower result storage copied into faster ones")
+			tieredResultStorageOrder = fs.String("tiered-result-storage-order", "",
+				"Tiered Result Storage tiers from fastest to slowest, comma separated e.g. file,redis,s3. Available values: file, redis, s3, gcloud, azure, sftp, webdav. Default config order")
+			tieredResultStorageTTL = fs.String("tiered-result-storage-ttl", "",
+				"Tiered Result Storage TTL of each tier in order, comma separated e.g. 1h,24h. Empty or 0 for no TTL")
+			tieredResultStorageMaxSize = fs.String("tiered-result-storage-max-size", "",
+				"Tiered Result Storage maximum blob size in bytes of each tier in order, comma separated e.g. 1048576,0. Empty or 0 for no limit")
+			tieredResultStorageWriteBack = fs.Bool("tiered-result-storage-write-back", false,
+				"Tiered Result Storage write-back, writing the fastest tier synchronously and slower tiers asynchronously. Default write-through")
+			tieredResultStorageTimeout = fs.Duration("tiered-result-storage-timeout", time.Minute,
+				"Tiered Result Storage timeout of asynchronous promotion and write-back")
+
+			logger, _ = cb()
+		)
+		return func(app *imagor.Imagor) {
+			if !*tieredResultStorageEnable || len(app.ResultStorages) == 0 {
+				return
+			}
+			storages := names.orderStorages(app.ResultStorages, splitList(*tieredResultStorageOrder))
+			ttls := splitList(*tieredResultStorageTTL)
+			maxSizes := splitList(*tieredResultStorageMaxSize)
+			tiers := make([]tieredstorage.Tier, len(storages))
+			for i, storage := range storages {
+				tiers[i].Storage = storage
+				if i < len(ttls) && ttls[i] != "" {
+					ttl, err := time.ParseDuration(ttls[i])
+					if err != nil {
+						panic(fmt.Errorf("tiered-result-storage-ttl: %w", err))
+					}
+					tiers[i].TTL = ttl
+				}
+				if i < len(maxSizes) && maxSizes[i] != "" {
+					maxSize, err := strconv.ParseInt(maxSizes[i], 10, 64)
+					if err != nil {
+						panic(fmt.Errorf("tiered-result-storage-max-size: %w", err))
+					}
+					tiers[i].MaxSize = maxSize
+				}
+			}
+			app.ResultStorages = []imagor.Storage{
+				tieredstorage.New(tiers,
+					tieredstorage.WithWriteBack(*tieredResultStorageWriteBack),
+					tieredstorage.WithTimeout(*tieredResultStorageTimeout),
+					tieredstorage.WithLogger(logger),
+				),
+			}
+		}
+	}
+}
+
+// storageName returns name of storage declared in YAML config sections,
+// or derives from its package e.g. *s3storage.S3Storage as s3
+func (n backendNames) storageName(storage imagor.Storage) string {
+	if c, ok := storage.(*gc.Collector); ok {
+		storage = c.Storage
+	}
+	if s, ok := storage.(*encryptedstorage.EncryptedStorage); ok {
+		storage = s.Storage
+	}
+	if name, ok := n.name(storage); ok {
+		return name
+	}
+	name := strings.TrimPrefix(fmt.Sprintf("%T", storage), "*")
+	if i := strings.Index(name, "."); i > -1 {
+		name = name[:i]
+	}
+	// s3routerloader routes to s3 storages
+	name = strings.TrimSuffix(name, "routerloader")
+	return strings.TrimSuffix(name, "storage")
+}
+
+// orderStorages orders storages by names,
+// storages not named are kept after in their original order
+func (n backendNames) orderStorages(storages []imagor.Storage, names []string) []imagor.Storage {
+	var ordered []imagor.Storage
+	used := make([]bool, len(storages))
+	for _, name := range names {
+		for i, storage := range storages {
+			if !used[i] && n.storageName(storage) == name {
+				ordered = append(ordered, storage)
+				used[i] = true
+			}
+		}
+	}
+	for i, storage := range storages {
+		if !used[i] {
+			ordered = append(ordered, storage)
+		}
+	}
+	return ordered
+}
+
+func splitList(s string) (list []string) {
+	if s = strings.TrimSpace(s); s == "" {
+		return
+	}
+	for _, item := range strings.Split(s, ",") {
+		list = append(list, strings.ToLower(strings.TrimSpace(item)))
+	}
+	return
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/cshum/imagor/warm"
+	"go.uber.org/zap"
+)
+
+// warmFlags defines cache warm-up flags shared by the warm command and endpoint,
+// returning the Warmer constructor
+func warmFlags(fs *flag.FlagSet) func(app *imagor.Imagor, options ...warm.Option) *warm.Warmer {
+	var (
+		warmConcurrency = fs.Int("warm-concurrency", 8,
+			"Number of paths processed concurrently on cache warm-up, should not exceed imagor-process-concurrency and queue size")
+		warmPresets = fs.String("warm-presets", "",
+			"Comma separated presets applied to source keys of cache warm-up lines without presets. Lines are read as imagor paths if empty")
+		warmAccept = fs.String("warm-accept", "",
+			"Accept header of cache warm-up requests e.g. image/avif,image/webp, deciding the result format if imagor auto WebP, AVIF or JPEG enabled")
+	)
+	return func(app *imagor.Imagor, options ...warm.Option) *warm.Warmer {
+		var presets []string
+		if *warmPresets != "" {
+			presets = strings.Split(*warmPresets, ",")
+		}
+		return warm.New(app, append([]warm.Option{
+			warm.WithConcurrency(*warmConcurrency),
+			warm.WithPresets(presets...),
+			warm.WithAccept(*warmAccept),
+		}, options...)...)
+	}
+}
+
+// Warm runs the cache warm-up command from config flags, processing targets of the files in args,
+// or r if none, through imagor and writing the report to w
+func Warm(ctx context.Context, args []string, r io.Reader, w io.Writer, funcs ...Option) error {
+	var (
+		fs     = flag.NewFlagSet("imagor warm", flag.ExitOnError)
+		logger = zap.NewNop()
+		err    error
+
+		debug = fs.Bool("debug", false, "Debug mode")
+		_     = fs.String("config", ".env", "Retrieve configuration from the given file")
+
+		warmQuiet = fs.Bool("warm-quiet", false,
+			"Do not print each warmed path")
+
+		newWarmer = warmFlags(fs)
+	)
+	app := NewImagor(fs, func() (*zap.Logger, bool) {
+		if err = parseFlags(fs, args); err != nil {
+			panic(err)
+		}
+		if *debug {
+			logger = zap.Must(zap.NewDevelopment())
+		}
+		return logger, *debug
+	}, funcs...)
+
+	warmer := newWarmer(app, warm.WithProgress(func(item warm.Item) {
+		if item.Err != nil {
+			_, _ = fmt.Fprintf(w, "failed %s: %s\n", item.Path, item.Err)
+		} else if !*warmQuiet {
+			_, _ = fmt.Fprintf(w, "%s (%d bytes)\n", item.Path, item.Size)
+		}
+	}))
+	var targets []imagorpath.Params
+	if files := fs.Args(); len(files) > 0 {
+		for _, file := range files {
+			t, err := readWarmFile(warmer, file)
+			if err != nil {
+				return err
+			}
+			targets = append(targets, t...)
+		}
+	} else if targets, err = warmer.Read(r); err != nil {
+		return err
+	}
+	if err = app.Startup(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		_ = app.Shutdown(context.Background())
+	}()
+	report, err := warmer.Run(ctx, targets)
+	_, _ = fmt.Fprintf(w, "%d requested, %d succeeded (%d bytes), %d failed\n",
+		report.Requested, report.Succeeded, report.Bytes, report.Failed)
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d paths failed to warm up", report.Failed)
+	}
+	return nil
+}
+
+func readWarmFile(warmer *warm.Warmer, file string) ([]imagorpath.Params, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	targets, err := warmer.Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return targets, nil
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"flag"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/storage/webdavstorage"
+	"go.uber.org/zap"
+)
+
+// withWebDAV with WebDAV Loader, Storage, Result Storage based config option
+func withWebDAV(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+	var (
+		webdavUsername = fs.String("webdav-username", "",
+			"WebDAV basic auth username")
+		webdavPassword = fs.String("webdav-password", "",
+			"WebDAV basic auth password")
+		webdavBearerToken = fs.String("webdav-bearer-token", "",
+			"WebDAV bearer token auth. Takes precedence over basic auth")
+		webdavSafeChars = fs.String("webdav-safe-chars", "",
+			"WebDAV safe characters to be excluded from image key escape. Set -- for no-op")
+		webdavDisablePropfind = fs.Bool("webdav-disable-propfind", false,
+			"WebDAV use HEAD instead of PROPFIND for stat, for non WebDAV compliant PUT gateways")
+		webdavDisableMkcol = fs.Bool("webdav-disable-mkcol", false,
+			"WebDAV disable MKCOL of missing parent collections, for servers that create them on PUT")
+
+		webdavLoaderBaseURL = fs.String("webdav-loader-base-url", "",
+			"Base URL for WebDAV Loader. Enable WebDAV Loader only if this value present")
+		webdavLoaderPathPrefix = fs.String("webdav-loader-path-prefix", "",
+			"Base path prefix for WebDAV Loader")
+
+		webdavStorageBaseURL = fs.String("webdav-storage-base-url", "",
+			"Base URL for WebDAV Storage. Enable WebDAV Storage only if this value present")
+		webdavStoragePathPrefix = fs.String("webdav-storage-path-prefix", "",
+			"Base path prefix for WebDAV Storage")
+		webdavStorageExpiration = fs.Duration("webdav-storage-expiration", 0,
+			"WebDAV Storage expiration duration e.g. 24h. Default no expiration")
+
+		webdavResultStorageBaseURL = fs.String("webdav-result-storage-base-url", "",
+			"Base URL for WebDAV Result Storage. Enable WebDAV Result Storage only if this value present")
+		webdavResultStoragePathPrefix = fs.String("webdav-result-storage-path-prefix", "",
+			"Base path prefix for WebDAV Result Storage")
+		webdavResultStorageExpiration = fs.Duration("webdav-result-storage-expiration", 0,
+			"WebDAV Result Storage expiration duration e.g. 24h. Default no expiration")
+
+		_, _ = cb()
+	)
+	newStorage := func(baseURL string, options ...webdavstorage.Option) *webdavstorage.WebDAVStorage {
+		s, err := webdavstorage.New(baseURL, append([]webdavstorage.Option{
+			webdavstorage.WithBasicAuth(*webdavUsername, *webdavPassword),
+			webdavstorage.WithBearerToken(*webdavBearerToken),
+			webdavstorage.WithSafeChars(*webdavSafeChars),
+			webdavstorage.WithDisablePropfind(*webdavDisablePropfind),
+			webdavstorage.WithDisableMkcol(*webdavDisableMkcol),
+		}, options...)...)
+		if err != nil {
+			panic(err)
+		}
+		return s
+	}
+	return func(o *imagor.Imagor) {
+		if *webdavStorageBaseURL != "" {
+			// activate WebDAV Storage only if base URL config presents
+			o.Storages = append(o.Storages, newStorage(*webdavStorageBaseURL,
+				webdavstorage.WithPathPrefix(*webdavStoragePathPrefix),
+				webdavstorage.WithExpiration(*webdavStorageExpiration),
+			))
+		}
+		if *webdavLoaderBaseURL != "" {
+			// activate WebDAV Loader only if base URL config presents
+			o.Loaders = append(o.Loaders, newStorage(*webdavLoaderBaseURL,
+				webdavstorage.WithPathPrefix(*webdavLoaderPathPrefix),
+			))
+		}
+		if *webdavResultStorageBaseURL != "" {
+			// activate WebDAV Result Storage only if base URL config presents
+			o.ResultStorages = append(o.ResultStorages, newStorage(*webdavResultStorageBaseURL,
+				webdavstorage.WithPathPrefix(*webdavResultStoragePathPrefix),
+				webdavstorage.WithExpiration(*webdavResultStorageExpiration),
+			))
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/cshum/imagor"
+	"github.com/peterbourgon/ff/v3"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// backendEntry named backend of a YAML config section
+type backendEntry struct {
+	// Name of the backend, used by storage names of config flags e.g. migrate-from. Default Type
+	Name string `yaml:"name"`
+	// Type of the backend, by prefix of its config flags e.g. file, s3, gcloud, vips
+	Type string `yaml:"type"`
+	// Options config flags of the backend without the flag prefix dash e.g. s3-loader-bucket
+	Options map[string]any `yaml:"options"`
+}
+
+// backendSection is a flag of YAML list of named backends
+type backendSection struct {
+	Name    string
+	Kind    string
+	Entries []backendEntry
+	IsSet   bool
+}
+
+// String implements flag.Value interface
+func (s *backendSection) String() string {
+	if s == nil || !s.IsSet {
+		return ""
+	}
+	buf, _ := yaml.Marshal(s.Entries)
+	return string(buf)
+}
+
+// Set implements flag.Value interface
+func (s *backendSection) Set(value string) error {
+	var entries []backendEntry
+	dec := yaml.NewDecoder(strings.NewReader(value))
+	dec.KnownFields(true)
+	if err := dec.Decode(&entries); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	s.Entries = entries
+	s.IsSet = true
+	return nil
+}
+
+// backendNames names of backends declared in YAML config sections, by backend instance
+type backendNames map[any]string
+
+// name returns name of backend declared in YAML config sections
+func (n backendNames) name(backend any) (string, bool) {
+	if backend == nil || reflect.TypeOf(backend).Kind() != reflect.Ptr {
+		return "", false
+	}
+	name, ok := n[backend]
+	return name, ok
+}
+
+// withBackendSections with loaders, storages, result storages and processors
+// declared as YAML config sections, built by the backend config options.
+// A section declared replaces backends of the section configured by flags,
+// with names of the backends built registered to names
+func withBackendSections(backends []Option, names backendNames) Option {
+	return func(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+		var (
+			loaders        = &backendSection{Name: "loaders", Kind: "loader"}
+			storages       = &backendSection{Name: "storages", Kind: "storage"}
+			resultStorages = &backendSection{Name: "result-storages", Kind: "result storage"}
+			processors     = &backendSection{Name: "processors", Kind: "processor"}
+		)
+		fs.Var(loaders, loaders.Name,
+			"YAML list of named loaders in order, replacing loaders of flags e.g. [{name: eu, type: s3, options: {s3-loader-bucket: images-eu}}]")
+		fs.Var(storages, storages.Name,
+			"YAML list of named storages in order, replacing storages of flags")
+		fs.Var(resultStorages, resultStorages.Name,
+			"YAML list of named result storages in order, replacing result storages of flags")
+		fs.Var(processors, processors.Name,
+			"YAML list of named processors in order, replacing processors of flags")
+		logger, isDebug := cb()
+		return func(app *imagor.Imagor) {
+			if !loaders.IsSet && !storages.IsSet && !resultStorages.IsSet && !processors.IsSet {
+				return
+			}
+			b := &backendBuilder{
+				options: backends,
+				logger:  logger,
+				isDebug: isDebug,
+				names:   map[string]bool{},
+				named:   names,
+			}
+			if loaders.IsSet {
+				app.Loaders = buildSection(b, loaders, app, func(app *imagor.Imagor) []imagor.Loader {
+					return app.Loaders
+				})
+			}
+			if storages.IsSet {
+				app.Storages = buildSection(b, storages, app, func(app *imagor.Imagor) []imagor.Storage {
+					return app.Storages
+				})
+			}
+			if resultStorages.IsSet {
+				app.ResultStorages = buildSection(b, resultStorages, app, func(app *imagor.Imagor) []imagor.Storage {
+					return app.ResultStorages
+				})
+			}
+			if processors.IsSet {
+				app.Processors = buildSection(b, processors, app, func(app *imagor.Imagor) []imagor.Processor {
+					return app.Processors
+				})
+			}
+		}
+	}
+}
+
+// backendBuilder builds backends of YAML config sections by the backend config options
+type backendBuilder struct {
+	options []Option
+	flags   [][]string
+	logger  *zap.Logger
+	isDebug bool
+	names   map[string]bool
+	named   backendNames
+}
+
+// optionFlags returns flag names of each backend config option
+func (b *backendBuilder) optionFlags() [][]string {
+	if b.flags == nil {
+		b.flags = make([][]string, len(b.options))
+		for i, option := range b.options {
+			b.flags[i] = optionFlags(option)
+		}
+	}
+	return b.flags
+}
+
+// resolve returns config option of backend type, by prefix of its config flags
+func (b *backendBuilder) resolve(typ string) (Option, error) {
+	if typ == "" {
+		return nil, errors.New("type required")
+	}
+	var found []int
+	for i, names := range b.optionFlags() {
+		for _, name := range names {
+			if strings.HasPrefix(name, typ+"-") {
+				found = append(found, i)
+				break
+			}
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+	if len(found) > 1 {
+		return nil, fmt.Errorf("ambiguous type %s", typ)
+	}
+	return b.options[found[0]], nil
+}
+
+// build builds backends configured by entry options, into a standalone app
+func (b *backendBuilder) build(entry backendEntry) (*imagor.Imagor, error) {
+	option, err := b.resolve(entry.Type)
+	if err != nil {
+		return nil, err
+	}
+	return buildOption(option, func(fs *flag.FlagSet) error {
+		var names []string
+		for name := range entry.Options {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if fs.Lookup(name) == nil {
+				return fmt.Errorf("unknown option %s of type %s", name, entry.Type)
+			}
+			value, err := optionValue(entry.Options[name])
+			if err == nil {
+				err = fs.Set(name, value)
+			}
+			if err != nil {
+				return fmt.Errorf("option %s: %w", name, err)
+			}
+		}
+		return nil
+	}, b.logger, b.isDebug)
+}
+
+// buildSection builds backends of section in order, registering their names
+func buildSection[T any](
+	b *backendBuilder, section *backendSection, app *imagor.Imagor, get func(app *imagor.Imagor) []T,
+) (backends []T) {
+	for i, entry := range section.Entries {
+		if entry.Name == "" {
+			entry.Name = entry.Type
+		}
+		if b.names[entry.Name] {
+			panic(fmt.Errorf("%s[%d]: duplicated name %s", section.Name, i, entry.Name))
+		}
+		b.names[entry.Name] = true
+		built, err := b.build(entry)
+		if err != nil {
+			panic(fmt.Errorf("%s[%d] %s: %w", section.Name, i, entry.Name, err))
+		}
+		items := get(built)
+		if len(items) == 0 {
+			panic(fmt.Errorf("%s[%d] %s: no %s configured by options", section.Name, i, entry.Name, section.Kind))
+		}
+		if n := len(built.Loaders) + len(built.Storages) + len(built.ResultStorages) +
+			len(built.Processors); n > len(items) {
+			panic(fmt.Errorf("%s[%d] %s: options configure backends other than %s", section.Name, i, entry.Name, section.Kind))
+		}
+		if built.EnablePostRequests {
+			// upload loader accepts POST requests
+			app.EnablePostRequests = true
+		}
+		for _, item := range items {
+			if reflect.TypeOf(item).Kind() == reflect.Ptr {
+				b.named[item] = entry.Name
+			}
+		}
+		backends = append(backends, items...)
+	}
+	return
+}
+
+// optionValue returns flag value of YAML option, with lists joined by comma
+func optionValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		var values []string
+		for _, item := range v {
+			s, err := optionValue(item)
+			if err != nil {
+				return "", err
+			}
+			values = append(values, s)
+		}
+		return strings.Join(values, ","), nil
+	case map[string]any:
+		return "", errors.New("unsupported map value")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// configFileParser parses YAML config file of flags and backend sections,
+// or .env config file if not a YAML mapping of flag names
+func configFileParser(r io.Reader, set func(name, value string) error) error {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var doc map[string]yaml.Node
+	if yaml.Unmarshal(buf, &doc) != nil || len(doc) == 0 {
+		return ff.EnvParser(bytes.NewReader(buf), set)
+	}
+	for key := range doc {
+		if strings.ContainsAny(key, "= \t") {
+			return ff.EnvParser(bytes.NewReader(buf), set)
+		}
+	}
+	for key, node := range doc {
+		name := strings.ReplaceAll(key, "_", "-")
+		var value string
+		switch node.Kind {
+		case yaml.ScalarNode:
+			if node.Tag != "!!null" {
+				value = node.Value
+			}
+		case yaml.SequenceNode:
+			var items []any
+			if err := node.Decode(&items); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			if value, err = optionValue(items); err != nil {
+				// list of mappings e.g. backend sections
+				out, err := yaml.Marshal(&node)
+				if err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+				value = string(out)
+			}
+		default:
+			return fmt.Errorf("%s: unsupported value", key)
+		}
+		if err := set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
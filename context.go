@@ -14,6 +14,8 @@ type contextKey struct {
 var imagorContextKey = contextKey{1}
 var detachContextKey = contextKey{2}
 var sourceImageKeyContextKey = contextKey{3}
+var resultRedirectContextKey = contextKey{4}
+var saveResultContextKey = contextKey{5}
 
 type imagorContextRef struct {
 	funcs []func()
@@ -115,3 +117,24 @@ func SourceImageKeyFromContext(ctx context.Context) string {
 	}
 	return ""
 }
+
+// withResultRedirect context allowing result storage hit to be answered by redirect
+func withResultRedirect(ctx context.Context) context.Context {
+	return context.WithValue(ctx, resultRedirectContextKey, true)
+}
+
+func isResultRedirect(ctx context.Context) bool {
+	v, _ := ctx.Value(resultRedirectContextKey).(bool)
+	return v
+}
+
+// ContextWithSaveResult annotates a context so that Do saves the result to result storages
+// regardless of ResultAdmission, and returns only after the result saved, e.g. for cache warm-up
+func ContextWithSaveResult(ctx context.Context) context.Context {
+	return context.WithValue(ctx, saveResultContextKey, true)
+}
+
+func isSaveResult(ctx context.Context) bool {
+	v, _ := ctx.Value(saveResultContextKey).(bool)
+	return v
+}
@@ -29,12 +29,19 @@ const sidebars: SidebarsConfig = {
         "storage-filesystem",
         "storage-s3",
         "storage-gcloud",
+        "storage-azure",
+        "storage-sftp",
         "loader-http",
         {
           type: "doc",
           id: "storage-path-style",
           label: "Path Style",
         },
+        {
+          type: "doc",
+          id: "storage-migration",
+          label: "Migration",
+        },
       ],
     },
     {
@@ -53,6 +60,8 @@ const sidebars: SidebarsConfig = {
       items: [
         "metadata-and-exif",
         "in-memory-cache",
+        "cache-warm-up",
+        "batch-processing",
         "color-image",
         "benchmarks",
         "vips-performance",
@@ -35,6 +35,8 @@ var (
 	ErrMaxResolutionExceeded = NewError("maximum resolution exceeded", http.StatusUnprocessableEntity)
 	// ErrTooManyRequests too many requests error
 	ErrTooManyRequests = NewError("too many requests", http.StatusTooManyRequests)
+	// ErrPresetNotFound preset not found error
+	ErrPresetNotFound = NewError("preset not found", http.StatusBadRequest)
 	// ErrInternal internal error
 	ErrInternal = NewError("internal error", http.StatusInternalServerError)
 )
@@ -53,6 +55,16 @@ func (p ErrForward) Error() string {
 	return fmt.Sprintf("%s forward %s", errPrefix, imagorpath.GeneratePath(p.Params))
 }
 
+// ErrRedirect indicator answering request by redirect to the result URL
+type ErrRedirect struct {
+	URL string
+}
+
+// Error implements error
+func (e ErrRedirect) Error() string {
+	return fmt.Sprintf("%s redirect %s", errPrefix, e.URL)
+}
+
 // Error imagor error convention
 type Error struct {
 	Message string `json:"message,omitempty"`
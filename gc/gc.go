@@ -0,0 +1,338 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// Status garbage collection status of a blob
+type Status string
+
+const (
+	// StatusDeleted blob deleted, or to be deleted in dry run
+	StatusDeleted Status = "deleted"
+	// StatusFailed blob failed to delete
+	StatusFailed Status = "failed"
+)
+
+// Item garbage collection result of a blob
+type Item struct {
+	Key          string
+	Size         int64
+	ModifiedTime time.Time
+	Status       Status
+	Err          error
+}
+
+// Report garbage collection report
+type Report struct {
+	Listed  int
+	Deleted int
+	Failed  int
+	// Bytes total size of blobs deleted
+	Bytes int64
+}
+
+// Collector garbage collector of a storage, deleting blobs modified longer than MaxAge ago,
+// or not accessed within MaxIdle. Collector implements imagor.Storage interface wrapping the storage,
+// so that access of blobs read through it is tracked in memory of the process.
+// Access before the last startup, or through other processes, is unknown,
+// for which the later of modified time and startup time is used instead.
+// MaxIdle is therefore only reliable with a single instance serving the storage
+type Collector struct {
+	Storage imagor.Storage
+	// MaxAge deletes blobs modified longer than MaxAge ago. Set 0 to disable
+	MaxAge time.Duration
+	// MaxIdle deletes blobs not accessed within MaxIdle. Set 0 to disable
+	MaxIdle time.Duration
+	// Interval runs garbage collection periodically in background from app startup until shutdown.
+	// Set 0 to disable
+	Interval time.Duration
+	// BatchSize number of blobs deleted in each batch
+	BatchSize int
+	// BatchInterval pause between batches, limiting the rate of deletes
+	BatchInterval time.Duration
+	Concurrency   int
+	// DryRun reports blobs to be deleted without deleting them
+	DryRun bool
+	// Progress is called with the result of each blob, one at a time
+	Progress func(item Item)
+	Logger   *zap.Logger
+
+	mu        sync.Mutex
+	accessed  map[string]time.Time
+	startedAt time.Time
+	runMu     sync.Mutex
+
+	bgMu   sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates Collector of storage
+func New(storage imagor.Storage, options ...Option) *Collector {
+	c := &Collector{
+		Storage:     storage,
+		BatchSize:   1000,
+		Concurrency: 8,
+		Logger:      zap.NewNop(),
+		accessed:    map[string]time.Time{},
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+func (c *Collector) enabled() bool {
+	return c.MaxAge > 0 || c.MaxIdle > 0
+}
+
+func (c *Collector) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			report, err := c.Run(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				c.Logger.Warn("gc", zap.Error(err))
+			}
+			c.Logger.Info("gc",
+				zap.Int("listed", report.Listed),
+				zap.Int("deleted", report.Deleted),
+				zap.Int("failed", report.Failed),
+				zap.Int64("bytes", report.Bytes),
+				zap.Bool("dry_run", c.DryRun))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Startup implements imagor.Lifecycle interface, starting the storage if it implements it,
+// and the background garbage collection if Interval set.
+// Blobs are not deleted for MaxIdle until at least MaxIdle after startup, as access before startup is unknown
+func (c *Collector) Startup(ctx context.Context) error {
+	if lc, ok := c.Storage.(imagor.Lifecycle); ok {
+		if err := lc.Startup(ctx); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	if c.startedAt.IsZero() {
+		c.startedAt = time.Now()
+	}
+	c.mu.Unlock()
+	c.bgMu.Lock()
+	defer c.bgMu.Unlock()
+	if c.Interval <= 0 || !c.enabled() || c.cancel != nil {
+		return nil
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	go c.run(runCtx, c.done)
+	return nil
+}
+
+// Shutdown implements imagor.Lifecycle interface, stopping the background garbage collection,
+// waiting for the run in progress to be cancelled, then stopping the storage if it implements it
+func (c *Collector) Shutdown(ctx context.Context) error {
+	c.bgMu.Lock()
+	cancel, done := c.cancel, c.done
+	c.cancel, c.done = nil, nil
+	c.bgMu.Unlock()
+	if cancel != nil {
+		cancel()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if lc, ok := c.Storage.(imagor.Lifecycle); ok {
+		return lc.Shutdown(ctx)
+	}
+	return nil
+}
+
+// Get implements imagor.Storage interface, tracking access of the blob
+func (c *Collector) Get(r *http.Request, key string) (*imagor.Blob, error) {
+	blob, err := c.Storage.Get(r, key)
+	if err == nil && blob != nil && c.MaxIdle > 0 {
+		c.mu.Lock()
+		c.accessed[key] = time.Now()
+		c.mu.Unlock()
+	}
+	return blob, err
+}
+
+// Put implements imagor.Storage interface
+func (c *Collector) Put(ctx context.Context, key string, blob *imagor.Blob) error {
+	return c.Storage.Put(ctx, key, blob)
+}
+
+// Delete implements imagor.Storage interface
+func (c *Collector) Delete(ctx context.Context, key string) error {
+	return c.Storage.Delete(ctx, key)
+}
+
+// Stat implements imagor.Storage interface
+func (c *Collector) Stat(ctx context.Context, key string) (*imagor.Stat, error) {
+	return c.Storage.Stat(ctx, key)
+}
+
+// List implements imagor.Lister interface if the wrapped storage implements it
+func (c *Collector) List(ctx context.Context, fn func(key string, stat *imagor.Stat) error) error {
+	lister, ok := c.Storage.(imagor.Lister)
+	if !ok {
+		return fmt.Errorf("gc: %T does not support listing", c.Storage)
+	}
+	return lister.List(ctx, fn)
+}
+
+// Presign implements imagor.Presigner interface if the wrapped storage implements it
+func (c *Collector) Presign(ctx context.Context, key string, expires time.Duration) (string, error) {
+	presigner, ok := c.Storage.(imagor.Presigner)
+	if !ok {
+		return "", imagor.ErrMethodNotAllowed
+	}
+	return presigner.Presign(ctx, key, expires)
+}
+
+// expired checks if blob exceeds MaxAge or MaxIdle
+func (c *Collector) expired(key string, stat *imagor.Stat, now time.Time) bool {
+	if stat == nil || stat.ModifiedTime.IsZero() {
+		return false
+	}
+	if c.MaxAge > 0 && now.Sub(stat.ModifiedTime) > c.MaxAge {
+		return true
+	}
+	if c.MaxIdle > 0 {
+		accessed := stat.ModifiedTime
+		c.mu.Lock()
+		if c.startedAt.After(accessed) {
+			accessed = c.startedAt
+		}
+		if t, ok := c.accessed[key]; ok && t.After(accessed) {
+			accessed = t
+		}
+		c.mu.Unlock()
+		return now.Sub(accessed) > c.MaxIdle
+	}
+	return false
+}
+
+// Run runs garbage collection once, listing all blobs of the storage
+// and deleting expired blobs in batches.
+// Blobs failed to delete are reported without stopping the garbage collection,
+// error is returned only if listing failed or ctx is done
+func (c *Collector) Run(ctx context.Context) (report Report, err error) {
+	if !c.enabled() {
+		return
+	}
+	lister, ok := c.Storage.(imagor.Lister)
+	if !ok {
+		return report, fmt.Errorf("gc: %T does not support listing", c.Storage)
+	}
+	c.runMu.Lock()
+	defer c.runMu.Unlock()
+	now := time.Now()
+	kept := map[string]bool{}
+	var batch []Item
+	flush := func() {
+		c.deleteBatch(ctx, batch, &report)
+		batch = batch[:0]
+	}
+	err = lister.List(ctx, func(key string, stat *imagor.Stat) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		report.Listed++
+		if !c.expired(key, stat, now) {
+			if c.MaxIdle > 0 {
+				kept[key] = true
+			}
+			return nil
+		}
+		batch = append(batch, Item{Key: key, Size: stat.Size, ModifiedTime: stat.ModifiedTime})
+		if len(batch) < max(c.BatchSize, 1) {
+			return nil
+		}
+		flush()
+		if c.BatchInterval > 0 {
+			select {
+			case <-time.After(c.BatchInterval):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	if err == nil && len(batch) > 0 {
+		flush()
+	}
+	if err == nil {
+		// drop access of blobs no longer exist
+		c.mu.Lock()
+		for key, t := range c.accessed {
+			if !kept[key] && t.Before(now) {
+				delete(c.accessed, key)
+			}
+		}
+		c.mu.Unlock()
+	}
+	return
+}
+
+func (c *Collector) deleteBatch(ctx context.Context, batch []Item, report *Report) {
+	var mu sync.Mutex
+	var g errgroup.Group
+	g.SetLimit(max(c.Concurrency, 1))
+	for _, item := range batch {
+		g.Go(func() error {
+			item.Status = StatusDeleted
+			if !c.DryRun {
+				if err := c.Storage.Delete(ctx, item.Key); err != nil &&
+					!errors.Is(err, imagor.ErrNotFound) && !errors.Is(err, os.ErrNotExist) {
+					item.Status = StatusFailed
+					item.Err = err
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if item.Status == StatusDeleted {
+				report.Deleted++
+				report.Bytes += item.Size
+			} else {
+				report.Failed++
+			}
+			if c.Progress != nil {
+				c.Progress(item)
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+	if !c.DryRun && c.MaxIdle > 0 {
+		c.mu.Lock()
+		for _, item := range batch {
+			delete(c.accessed, item.Key)
+		}
+		c.mu.Unlock()
+	}
+}
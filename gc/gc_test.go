@@ -0,0 +1,161 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+// put puts blob modified age ago
+func put(t *testing.T, s *filestorage.FileStorage, key string, age time.Duration) {
+	require.NoError(t, s.Put(ctx, key, imagor.NewBlobFromBytes([]byte(key))))
+	p, _ := s.Path(key)
+	modTime := time.Now().Add(-age)
+	require.NoError(t, os.Chtimes(p, modTime, modTime))
+}
+
+func TestMaxAge(t *testing.T) {
+	fs := filestorage.New(t.TempDir())
+	day := time.Hour * 24
+	put(t, fs, "new.jpg", day)
+	put(t, fs, "foo/old.jpg", day*10)
+	put(t, fs, "foo/bar/older.jpg", day*20)
+
+	var items []Item
+	c := New(fs, WithMaxAge(day*7), WithDryRun(true), WithProgress(func(item Item) {
+		items = append(items, item)
+	}))
+	report, err := c.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Listed: 3, Deleted: 2, Bytes: 28}, report)
+	assert.Len(t, items, 2)
+	_, err = fs.Stat(ctx, "foo/old.jpg")
+	assert.NoError(t, err)
+
+	report, err = New(fs, WithMaxAge(day*7), WithBatchSize(1), WithBatchInterval(time.Millisecond)).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Listed: 3, Deleted: 2, Bytes: 28}, report)
+	_, err = fs.Stat(ctx, "new.jpg")
+	assert.NoError(t, err)
+	_, err = fs.Stat(ctx, "foo/old.jpg")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	_, err = fs.Stat(ctx, "foo/bar/older.jpg")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	// disabled without limits
+	report, err = New(fs).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{}, report)
+}
+
+func TestMaxIdle(t *testing.T) {
+	fs := filestorage.New(t.TempDir())
+	day := time.Hour * 24
+	put(t, fs, "accessed.jpg", day*10)
+	put(t, fs, "idle.jpg", day*10)
+	put(t, fs, "new.jpg", day)
+
+	c := New(fs, WithMaxIdle(day*7))
+	_, err := c.Get((&http.Request{}).WithContext(ctx), "accessed.jpg")
+	require.NoError(t, err)
+	_, err = c.Get((&http.Request{}).WithContext(ctx), "missing.jpg")
+	assert.Error(t, err)
+
+	report, err := c.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Listed: 3, Deleted: 1, Bytes: 8}, report)
+	_, err = c.Stat(ctx, "accessed.jpg")
+	assert.NoError(t, err)
+	_, err = c.Stat(ctx, "idle.jpg")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	_, err = c.Stat(ctx, "new.jpg")
+	assert.NoError(t, err)
+	assert.Len(t, c.accessed, 1)
+
+	// access before startup is unknown, kept for MaxIdle after startup
+	c = New(fs, WithMaxIdle(day*7))
+	require.NoError(t, c.Startup(ctx))
+	report, err = c.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Listed: 2}, report)
+	c.startedAt = c.startedAt.Add(-day * 8)
+	report, err = c.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Listed: 2, Deleted: 1, Bytes: 12}, report)
+	_, err = c.Stat(ctx, "accessed.jpg")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	require.NoError(t, c.Shutdown(ctx))
+}
+
+type failingStorage struct {
+	*filestorage.FileStorage
+}
+
+var errDelete = errors.New("delete")
+
+func (s failingStorage) Delete(context.Context, string) error {
+	return errDelete
+}
+
+func TestFailures(t *testing.T) {
+	fs := filestorage.New(t.TempDir())
+	put(t, fs, "old.jpg", time.Hour)
+
+	var failed []Item
+	report, err := New(failingStorage{fs}, WithMaxAge(time.Minute), WithProgress(func(item Item) {
+		if item.Status == StatusFailed {
+			failed = append(failed, item)
+		}
+	})).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Listed: 1, Failed: 1}, report)
+	require.Len(t, failed, 1)
+	assert.Equal(t, errDelete, failed[0].Err)
+
+	_, err = New(struct{ imagor.Storage }{fs}, WithMaxAge(time.Minute)).Run(ctx)
+	assert.Error(t, err)
+	_, err = New(fs, WithMaxAge(time.Minute)).Presign(ctx, "old.jpg", time.Minute)
+	assert.Equal(t, imagor.ErrMethodNotAllowed, err)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = New(fs, WithMaxAge(time.Minute)).Run(cancelled)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = fs.Stat(ctx, "old.jpg")
+	assert.NoError(t, err)
+}
+
+func TestBackground(t *testing.T) {
+	fs := filestorage.New(t.TempDir())
+	put(t, fs, "old.jpg", time.Hour)
+	c := New(fs, WithMaxAge(time.Minute), WithInterval(time.Millisecond*10))
+
+	// not run before startup
+	time.Sleep(time.Millisecond * 50)
+	_, err := fs.Stat(ctx, "old.jpg")
+	assert.NoError(t, err)
+
+	require.NoError(t, c.Startup(ctx))
+	require.NoError(t, c.Startup(ctx))
+	assert.Eventually(t, func() bool {
+		_, err := fs.Stat(ctx, "old.jpg")
+		return err == imagor.ErrNotFound
+	}, time.Second, time.Millisecond*10)
+
+	require.NoError(t, c.Shutdown(ctx))
+	require.NoError(t, c.Shutdown(ctx))
+	put(t, fs, "old.jpg", time.Hour)
+	time.Sleep(time.Millisecond * 50)
+	_, err = fs.Stat(ctx, "old.jpg")
+	assert.NoError(t, err)
+}
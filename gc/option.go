@@ -0,0 +1,87 @@
+package gc
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Option Collector option
+type Option func(c *Collector)
+
+// WithMaxAge with maximum age since modified time of blobs option
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(c *Collector) {
+		if maxAge >= 0 {
+			c.MaxAge = maxAge
+		}
+	}
+}
+
+// WithMaxIdle with maximum duration since last access of blobs option
+func WithMaxIdle(maxIdle time.Duration) Option {
+	return func(c *Collector) {
+		if maxIdle >= 0 {
+			c.MaxIdle = maxIdle
+		}
+	}
+}
+
+// WithInterval with interval of background garbage collection option
+func WithInterval(interval time.Duration) Option {
+	return func(c *Collector) {
+		if interval >= 0 {
+			c.Interval = interval
+		}
+	}
+}
+
+// WithBatchSize with number of blobs deleted in each batch option
+func WithBatchSize(size int) Option {
+	return func(c *Collector) {
+		if size > 0 {
+			c.BatchSize = size
+		}
+	}
+}
+
+// WithBatchInterval with pause between batches option
+func WithBatchInterval(interval time.Duration) Option {
+	return func(c *Collector) {
+		if interval >= 0 {
+			c.BatchInterval = interval
+		}
+	}
+}
+
+// WithConcurrency with number of blobs deleted concurrently option
+func WithConcurrency(concurrency int) Option {
+	return func(c *Collector) {
+		if concurrency > 0 {
+			c.Concurrency = concurrency
+		}
+	}
+}
+
+// WithDryRun with dry run option, reporting blobs to be deleted without deleting them
+func WithDryRun(dryRun bool) Option {
+	return func(c *Collector) {
+		c.DryRun = dryRun
+	}
+}
+
+// WithProgress with callback option called with the result of each blob
+func WithProgress(progress func(item Item)) Option {
+	return func(c *Collector) {
+		c.Progress = progress
+	}
+}
+
+// WithLogger with logger option
+func WithLogger(logger *zap.Logger) Option {
+	return func(c *Collector) {
+		if logger != nil {
+			c.Logger = logger
+		}
+	}
+}
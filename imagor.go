@@ -13,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cshum/imagor/imagorpath"
@@ -61,6 +62,53 @@ type Cacher interface {
 	LoadFromCache(key string, w, h int) (*Blob, bool)
 }
 
+// ResultCache in-process cache of result blobs, consulted before ResultStorages
+type ResultCache interface {
+	// Get returns cached result blob
+	Get(key string) (*Blob, bool)
+	// Set caches result blob if eligible.
+	// Returns the in-memory cached copy, which can be served in place of blob
+	Set(key string, blob *Blob) (*Blob, bool)
+	// Delete removes cached result blob
+	Delete(key string)
+}
+
+// ResultAdmission write-admission policy of results into ResultStorages,
+// e.g. to keep one-off results out of result storages
+type ResultAdmission interface {
+	// Admit reports whether result of key should be saved to result storages.
+	// elapsed is the duration of loading and processing,
+	// sourceSize the size of the source image, or -1 if not available
+	Admit(key string, elapsed time.Duration, sourceSize int64) bool
+}
+
+// Presigner optional interface for Storage to provide a public or presigned URL
+// of the stored image, so that clients can fetch it from the storage directly
+type Presigner interface {
+	Presign(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// Lister optional interface for Storage to enumerate stored blobs, e.g. for storage migration.
+// fn is called with the key, as accepted by Get, and Stat of each blob.
+// Listing stops on the first error returned by fn
+type Lister interface {
+	List(ctx context.Context, fn func(key string, stat *Stat) error) error
+}
+
+// Lifecycle optional interface for Loader and Storage running background work,
+// started on app Startup and stopped on app Shutdown.
+// Loaders and storages wrapping others forward their lifecycle to the wrapped ones
+type Lifecycle interface {
+	Startup(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+}
+
+// Reloader optional interface for Imagor, Loader and Processor to hot reload settings
+// from a newly configured instance of the same type, without interrupting in-flight requests
+type Reloader interface {
+	Reload(next any) error
+}
+
 // LoadFunc function handler for Processor to call loader
 type LoadFunc func(string) (*Blob, error)
 
@@ -90,6 +138,10 @@ type Imagor struct {
 	Loaders                []Loader
 	Storages               []Storage
 	ResultStorages         []Storage
+	ResultCache            ResultCache
+	ResultAdmission        ResultAdmission
+	ResultRedirect         int
+	ResultRedirectExpires  time.Duration
 	Processors             []Processor
 	RequestTimeout         time.Duration
 	LoadTimeout            time.Duration
@@ -108,15 +160,25 @@ type Imagor struct {
 	EnablePostRequests     bool
 	ResponseRawOnError     bool
 	BaseParams             string
+	Presets                map[string]string
 	Logger                 *zap.Logger
 	Debug                  bool
 
 	g          singleflight.Group
-	sema       *semaphore.Weighted
-	queueSema  *semaphore.Weighted
+	settings   atomic.Pointer[settings]
 	baseParams imagorpath.Params
 }
 
+// settings hot reloadable settings of Imagor, swapped atomically on Reload
+type settings struct {
+	signer             imagorpath.Signer
+	presets            map[string]string
+	processConcurrency int64
+	processQueueSize   int64
+	sema               *semaphore.Weighted
+	queueSema          *semaphore.Weighted
+}
+
 // New create new Imagor
 func New(options ...Option) *Imagor {
 	app := &Imagor{
@@ -127,20 +189,29 @@ func New(options ...Option) *Imagor {
 		ProcessTimeout: time.Second * 20,
 		CacheHeaderTTL: time.Hour * 24 * 7,
 		CacheHeaderSWR: time.Hour * 24,
+
+		ResultRedirectExpires: time.Hour,
 	}
 	for _, option := range options {
 		option(app)
 	}
-	if app.ProcessConcurrency > 0 {
-		app.sema = semaphore.NewWeighted(app.ProcessConcurrency)
-		app.queueSema = semaphore.NewWeighted(app.ProcessQueueSize + app.ProcessConcurrency)
-	}
 	if app.Debug {
 		app.debugLog()
 	}
 	if app.Signer == nil && app.GetSigner == nil {
 		app.Signer = imagorpath.NewDefaultSigner("")
 	}
+	s := &settings{
+		signer:             app.Signer,
+		presets:            app.Presets,
+		processConcurrency: app.ProcessConcurrency,
+		processQueueSize:   app.ProcessQueueSize,
+	}
+	if app.ProcessConcurrency > 0 {
+		s.sema = semaphore.NewWeighted(app.ProcessConcurrency)
+		s.queueSema = semaphore.NewWeighted(app.ProcessQueueSize + app.ProcessConcurrency)
+	}
+	app.settings.Store(s)
 	app.BaseParams = strings.TrimSpace(app.BaseParams)
 	if app.BaseParams != "" {
 		app.BaseParams = strings.TrimSuffix(app.BaseParams, "/") + "/"
@@ -155,11 +226,21 @@ func (app *Imagor) Startup(ctx context.Context) (err error) {
 			return
 		}
 	}
+	for _, lc := range app.lifecycles() {
+		if err = lc.Startup(ctx); err != nil {
+			return
+		}
+	}
 	return
 }
 
 // Shutdown Imagor shutdown lifecycle
 func (app *Imagor) Shutdown(ctx context.Context) (err error) {
+	for _, lc := range app.lifecycles() {
+		if err = lc.Shutdown(ctx); err != nil {
+			return
+		}
+	}
 	for _, processor := range app.Processors {
 		if err = processor.Shutdown(ctx); err != nil {
 			return
@@ -168,6 +249,21 @@ func (app *Imagor) Shutdown(ctx context.Context) (err error) {
 	return
 }
 
+// lifecycles returns loaders, storages and result storages implementing Lifecycle
+func (app *Imagor) lifecycles() (lcs []Lifecycle) {
+	for _, loader := range app.Loaders {
+		if lc, ok := loader.(Lifecycle); ok {
+			lcs = append(lcs, lc)
+		}
+	}
+	for _, storage := range append(append([]Storage{}, app.Storages...), app.ResultStorages...) {
+		if lc, ok := storage.(Lifecycle); ok {
+			lcs = append(lcs, lc)
+		}
+	}
+	return
+}
+
 // ServeHTTP implements http.Handler for imagor operations
 func (app *Imagor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodPost {
@@ -207,6 +303,9 @@ func (app *Imagor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if app.ResultRedirect > 0 {
+		r = r.WithContext(withResultRedirect(r.Context()))
+	}
 	blob, err := checkBlob(app.Do(r, p))
 	if err == ErrInvalid || err == ErrSignatureMismatch {
 		if path2, e := url.QueryUnescape(path); e == nil {
@@ -215,6 +314,11 @@ func (app *Imagor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			blob, err = checkBlob(app.Do(r, p))
 		}
 	}
+	var redirect ErrRedirect
+	if errors.As(err, &redirect) {
+		http.Redirect(w, r, redirect.URL, app.ResultRedirect)
+		return
+	}
 	if err != nil {
 		// Check if we should respond with raw image on error
 		if app.ResponseRawOnError && !isBlobEmpty(blob) {
@@ -280,7 +384,8 @@ func (app *Imagor) Do(r *http.Request, p imagorpath.Params) (blob *Blob, err err
 		contextDefer(ctx, cancel)
 		r = r.WithContext(ctx)
 	}
-	signer := app.Signer
+	var s = app.loadSettings()
+	signer := s.signer
 	if app.GetSigner != nil {
 		signer = app.GetSigner(r)
 		if signer == nil {
@@ -400,31 +505,37 @@ func (app *Imagor) Do(r *http.Request, p imagorpath.Params) (blob *Blob, err err
 		blob, _, err := app.loadStorage(r, image)
 		return blob, err
 	}
-	return app.suppress(ctx, resultKey, func(ctx context.Context, cb func(*Blob, error)) (*Blob, error) {
+	groupKey := resultKey
+	if groupKey != "" && app.ResultRedirect > 0 && isResultRedirect(ctx) {
+		// redirect answers are not shared with requests expecting the blob
+		groupKey = "redirect:" + resultKey
+	}
+	return app.suppress(ctx, groupKey, func(ctx context.Context, cb func(*Blob, error)) (*Blob, error) {
 		if resultKey != "" && !isRaw {
-			if blob := app.loadResult(r, resultKey, p.Image); blob != nil {
-				return blob, nil
+			if blob, err := app.loadResult(r, resultKey, p.Image); blob != nil || err != nil {
+				return blob, err
 			}
 		}
-		if app.queueSema != nil && !isRaw {
-			if !app.queueSema.TryAcquire(1) {
+		if s.queueSema != nil && !isRaw {
+			if !s.queueSema.TryAcquire(1) {
 				err = ErrTooManyRequests
 				if app.Debug {
 					app.Logger.Debug("queue-acquire", zap.Error(err))
 				}
 				return blob, err
 			}
-			defer app.queueSema.Release(1)
+			defer s.queueSema.Release(1)
 		}
-		if app.sema != nil && !isRaw {
-			if err = app.sema.Acquire(ctx, 1); err != nil {
+		if s.sema != nil && !isRaw {
+			if err = s.sema.Acquire(ctx, 1); err != nil {
 				if app.Debug {
 					app.Logger.Debug("acquire", zap.Error(err))
 				}
 				return blob, err
 			}
-			defer app.sema.Release(1)
+			defer s.sema.Release(1)
 		}
+		var start = time.Now()
 		var shouldSave bool
 		if isColorImage(p.Image) {
 			// color image — skip storage/loader, processor will generate it
@@ -514,7 +625,11 @@ func (app *Imagor) Do(r *http.Request, p imagorpath.Params) (blob *Blob, err err
 		cb(blob, err)
 		ctx = detachContext(ctx)
 		if err == nil && !isBlobEmpty(blob) && resultKey != "" && !isRaw &&
-			len(app.ResultStorages) > 0 {
+			app.ResultCache != nil {
+			app.ResultCache.Set(resultKey, blob)
+		}
+		if err == nil && !isBlobEmpty(blob) && resultKey != "" && !isRaw &&
+			len(app.ResultStorages) > 0 && app.admitResult(ctx, resultKey, time.Since(start), sourceBlob) {
 			app.saveWithErrorHandling(ContextWithSourceImageKey(ctx, p.Image), app.ResultStorages, resultKey, blob)
 		}
 		if err != nil && shouldSave {
@@ -597,71 +712,124 @@ func (app *Imagor) requestWithLoadContext(r *http.Request) *http.Request {
 	return r
 }
 
-func (app *Imagor) loadResult(r *http.Request, resultKey, imageKey string) *Blob {
+func (app *Imagor) loadResult(r *http.Request, resultKey, imageKey string) (*Blob, error) {
 	r = app.requestWithLoadContext(r)
 	r = r.WithContext(ContextWithSourceImageKey(r.Context(), imageKey))
 	ctx := r.Context()
+	if app.ResultCache != nil {
+		if blob, ok := app.ResultCache.Get(resultKey); ok {
+			if !app.ModifiedTimeCheck || blob.Stat == nil ||
+				app.isResultFresh(ctx, blob.Stat, resultKey, imageKey) {
+				return blob, nil
+			}
+			app.ResultCache.Delete(resultKey)
+		}
+	}
+	if app.ResultRedirect > 0 && isResultRedirect(ctx) {
+		if u := app.resultRedirectURL(ctx, resultKey, imageKey); u != "" {
+			return nil, ErrRedirect{URL: u}
+		}
+	}
 	blob, origin, err := fromStorages(r, app.ResultStorages, resultKey)
 	if err == nil && !isBlobEmpty(blob) {
 		if app.ModifiedTimeCheck && origin != nil && blob.Stat != nil {
-			var sourceStat *Stat
-			var sourceStatErr error
-
-			// Try loader stat first (if no Storages configured)
-			if len(app.Storages) == 0 {
-				sourceStat, sourceStatErr = app.loaderStat(ctx, imageKey)
-				if app.Debug {
-					if sourceStatErr == nil && sourceStat != nil {
-						app.Logger.Debug("source stat from loader succeeded",
-							zap.String("image_key", imageKey),
-							zap.Time("source_time", sourceStat.ModifiedTime))
-					} else {
-						app.Logger.Debug("source stat from loader failed",
-							zap.String("image_key", imageKey),
-							zap.Error(sourceStatErr))
-					}
-				}
+			if !app.isResultFresh(ctx, blob.Stat, resultKey, imageKey) {
+				return nil, nil
 			}
-
-			// Fallback to storage stat if loader didn't work or Storages is configured
-			if (sourceStat == nil || sourceStatErr != nil) && len(app.Storages) > 0 {
-				sourceStat, sourceStatErr = app.storageStat(ctx, imageKey)
+		} else if app.Debug && app.ModifiedTimeCheck {
+			app.Logger.Debug("modified-time-check-skipped",
+				zap.Bool("has_origin", origin != nil),
+				zap.Bool("has_blob_stat", blob.Stat != nil),
+				zap.String("result_key", resultKey))
+		}
+		if app.ResultCache != nil {
+			// serve the in-memory copy so that result is not fetched twice
+			if cached, ok := app.ResultCache.Set(resultKey, blob); ok {
+				return cached, nil
 			}
+		}
+		return blob, nil
+	}
+	return nil, nil
+}
 
-			if sourceStat != nil && sourceStatErr == nil {
-				if app.Debug {
-					app.Logger.Debug("modified-time-check",
-						zap.Time("result_time", blob.Stat.ModifiedTime),
-						zap.Time("source_time", sourceStat.ModifiedTime),
-						zap.Bool("result_before_source", blob.Stat.ModifiedTime.Before(sourceStat.ModifiedTime)),
-						zap.String("result_key", resultKey),
-						zap.String("image_key", imageKey))
-				}
-				if !blob.Stat.ModifiedTime.Before(sourceStat.ModifiedTime) {
-					return blob
-				}
+// resultRedirectURL returns public or presigned URL of the result,
+// from the first result storage that implements Presigner and has the result.
+// Returns empty if result is not available for redirect
+func (app *Imagor) resultRedirectURL(ctx context.Context, resultKey, imageKey string) string {
+	for _, storage := range app.ResultStorages {
+		presigner, ok := storage.(Presigner)
+		if !ok {
+			continue
+		}
+		stat, err := storage.Stat(ctx, resultKey)
+		if err != nil || stat == nil {
+			continue
+		}
+		if app.ModifiedTimeCheck && !app.isResultFresh(ctx, stat, resultKey, imageKey) {
+			return ""
+		}
+		u, err := presigner.Presign(ctx, resultKey, app.ResultRedirectExpires)
+		if err != nil {
+			app.Logger.Warn("result-redirect", zap.String("key", resultKey), zap.Error(err))
+			continue
+		}
+		if app.Debug {
+			app.Logger.Debug("result-redirect",
+				zap.String("key", resultKey), zap.String("storage", getType(storage)))
+		}
+		return u
+	}
+	return ""
+}
+
+// isResultFresh checks result modified time against the source image.
+// Result is considered fresh if source stat is not available
+func (app *Imagor) isResultFresh(ctx context.Context, resultStat *Stat, resultKey, imageKey string) bool {
+	var sourceStat *Stat
+	var sourceStatErr error
+
+	// Try loader stat first (if no Storages configured)
+	if len(app.Storages) == 0 {
+		sourceStat, sourceStatErr = app.loaderStat(ctx, imageKey)
+		if app.Debug {
+			if sourceStatErr == nil && sourceStat != nil {
+				app.Logger.Debug("source stat from loader succeeded",
+					zap.String("image_key", imageKey),
+					zap.Time("source_time", sourceStat.ModifiedTime))
 			} else {
-				if app.Debug {
-					app.Logger.Debug("modified-time-check-failed-fallback-to-cache",
-						zap.Bool("has_source_stat", sourceStat != nil),
-						zap.Error(sourceStatErr),
-						zap.String("image_key", imageKey))
-				}
-				// If we can't stat the source, use the cached result
-				// This handles cases where source is in loader but not storage
-				return blob
+				app.Logger.Debug("source stat from loader failed",
+					zap.String("image_key", imageKey),
+					zap.Error(sourceStatErr))
 			}
-		} else {
-			if app.Debug && app.ModifiedTimeCheck {
-				app.Logger.Debug("modified-time-check-skipped",
-					zap.Bool("has_origin", origin != nil),
-					zap.Bool("has_blob_stat", blob.Stat != nil),
-					zap.String("result_key", resultKey))
-			}
-			return blob
 		}
 	}
-	return nil
+
+	// Fallback to storage stat if loader didn't work or Storages is configured
+	if (sourceStat == nil || sourceStatErr != nil) && len(app.Storages) > 0 {
+		sourceStat, sourceStatErr = app.storageStat(ctx, imageKey)
+	}
+
+	if sourceStat != nil && sourceStatErr == nil {
+		if app.Debug {
+			app.Logger.Debug("modified-time-check",
+				zap.Time("result_time", resultStat.ModifiedTime),
+				zap.Time("source_time", sourceStat.ModifiedTime),
+				zap.Bool("result_before_source", resultStat.ModifiedTime.Before(sourceStat.ModifiedTime)),
+				zap.String("result_key", resultKey),
+				zap.String("image_key", imageKey))
+		}
+		return !resultStat.ModifiedTime.Before(sourceStat.ModifiedTime)
+	}
+	if app.Debug {
+		app.Logger.Debug("modified-time-check-failed-fallback-to-cache",
+			zap.Bool("has_source_stat", sourceStat != nil),
+			zap.Error(sourceStatErr),
+			zap.String("image_key", imageKey))
+	}
+	// If we can't stat the source, use the cached result
+	// This handles cases where source is in loader but not storage
+	return true
 }
 
 func fromStorages(
@@ -780,6 +948,78 @@ func (app *Imagor) loaderStat(ctx context.Context, key string) (stat *Stat, err
 	return
 }
 
+// Preset returns params of the named preset applied to image
+func (app *Imagor) Preset(name, image string) (imagorpath.Params, error) {
+	params, ok := app.loadSettings().presets[name]
+	if !ok {
+		return imagorpath.Params{}, ErrPresetNotFound
+	}
+	params = strings.Trim(strings.TrimSpace(params), "/")
+	if params == "" {
+		return imagorpath.Parse(image), nil
+	}
+	return imagorpath.Parse(params + "/" + image), nil
+}
+
+// RequestSigner returns URL signer of the request by GetSigner if configured,
+// or the current Signer
+func (app *Imagor) RequestSigner(r *http.Request) imagorpath.Signer {
+	if app.GetSigner != nil {
+		return app.GetSigner(r)
+	}
+	return app.loadSettings().signer
+}
+
+// Reload implements Reloader interface, swapping Signer, Presets, ProcessConcurrency
+// and ProcessQueueSize of next Imagor atomically. Requests in-flight or queued
+// complete with the settings they started with. Exported fields keep their initial values
+func (app *Imagor) Reload(next any) error {
+	n, ok := next.(*Imagor)
+	if !ok || n == nil {
+		return fmt.Errorf("imagor: cannot reload from %T", next)
+	}
+	s := *n.loadSettings()
+	if cur := app.loadSettings(); cur.processConcurrency == s.processConcurrency &&
+		cur.processQueueSize == s.processQueueSize {
+		// keep the semaphores if limits unchanged, so that in-flight requests are counted
+		s.sema, s.queueSema = cur.sema, cur.queueSema
+	}
+	app.settings.Store(&s)
+	return nil
+}
+
+// loadSettings returns the current hot reloadable settings
+func (app *Imagor) loadSettings() *settings {
+	if s := app.settings.Load(); s != nil {
+		return s
+	}
+	// not created by New
+	return &settings{
+		signer:             app.Signer,
+		presets:            app.Presets,
+		processConcurrency: app.ProcessConcurrency,
+		processQueueSize:   app.ProcessQueueSize,
+	}
+}
+
+// admitResult checks result write-admission policy if configured
+func (app *Imagor) admitResult(ctx context.Context, resultKey string, elapsed time.Duration, sourceBlob *Blob) bool {
+	if app.ResultAdmission == nil || isSaveResult(ctx) {
+		return true
+	}
+	var sourceSize int64 = -1
+	if !isBlobEmpty(sourceBlob) {
+		sourceSize = sourceBlob.Size()
+	}
+	if app.ResultAdmission.Admit(resultKey, elapsed, sourceSize) {
+		return true
+	}
+	if app.Debug {
+		app.Logger.Debug("result-admission-rejected", zap.String("key", resultKey))
+	}
+	return false
+}
+
 // saveWithErrorHandling saves blob to storage with cleanup on error
 func (app *Imagor) saveWithErrorHandling(ctx context.Context, storages []Storage, key string, blob *Blob) {
 	if key == "" {
@@ -858,6 +1098,10 @@ func (app *Imagor) suppress(
 	cb := func(blob *Blob, err error) {
 		chanCb <- singleflight.Result{Val: blob, Err: err}
 	}
+	if isSaveResult(ctx) {
+		// respond after result saved instead of right after processed
+		cb = blobNoop
+	}
 	isCanceled := false
 	ch := app.g.DoChan(key, func() (v interface{}, err error) {
 		v, err = fn(context.WithValue(ctx, suppressKey{key}, true), cb)
@@ -951,6 +1195,9 @@ func (app *Imagor) debugLog() {
 		zap.Strings("loaders", loaders),
 		zap.Strings("storages", storages),
 		zap.Strings("result_storages", resultStorages),
+		zap.Bool("result_cache", app.ResultCache != nil),
+		zap.Bool("result_admission", app.ResultAdmission != nil),
+		zap.Int("result_redirect", app.ResultRedirect),
 		zap.Strings("processors", processors),
 	)
 }
@@ -1116,6 +1116,52 @@ func (fn resultKeyFunc) HashResult(p imagorpath.Params) string {
 	return fn(p)
 }
 
+type resultAdmissionFunc func(key string, elapsed time.Duration, sourceSize int64) bool
+
+func (f resultAdmissionFunc) Admit(key string, elapsed time.Duration, sourceSize int64) bool {
+	return f(key, elapsed, sourceSize)
+}
+
+func TestWithResultAdmission(t *testing.T) {
+	resultStore := newMapStore()
+	var hits = map[string]int{}
+	var sizes []int64
+	app := New(
+		WithDebug(true), WithLogger(zap.NewExample()),
+		WithResultStorages(resultStore),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+		WithResultAdmission(resultAdmissionFunc(func(key string, elapsed time.Duration, sourceSize int64) bool {
+			hits[key]++
+			sizes = append(sizes, sourceSize)
+			assert.Greater(t, elapsed, time.Duration(0))
+			return hits[key] >= 2
+		})),
+		WithUnsafe(true),
+	)
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/foo", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "foo", w.Body.String())
+	}
+	// rejected, admitted, then served from result storage
+	assert.Equal(t, 2, hits["foo"])
+	assert.Equal(t, []int64{3, 3}, sizes)
+	assert.Equal(t, 1, resultStore.SaveCnt["foo"])
+	assert.Equal(t, 1, resultStore.LoadCnt["foo"])
+
+	// saved regardless of result admission
+	r := httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/bar", nil)
+	blob, err := app.Do(r.WithContext(ContextWithSaveResult(r.Context())), imagorpath.Parse("unsafe/bar"))
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(blob.Sniff()))
+	assert.Equal(t, 0, hits["bar"])
+	assert.Equal(t, 1, resultStore.SaveCnt["bar"])
+}
+
 func TestWithResultStorageHasher(t *testing.T) {
 	store := newMapStore()
 	resultStore := newMapStore()
@@ -1406,6 +1452,178 @@ func TestWithModifiedTimeCheck(t *testing.T) {
 	assert.Equal(t, 2, resultStore.SaveCnt["foo"])
 }
 
+type mapResultCache struct {
+	l      sync.Mutex
+	Map    map[string][]byte
+	Stat   map[string]*Stat
+	SetCnt map[string]int
+	DelCnt map[string]int
+}
+
+func newMapResultCache() *mapResultCache {
+	return &mapResultCache{
+		Map: map[string][]byte{}, Stat: map[string]*Stat{},
+		SetCnt: map[string]int{}, DelCnt: map[string]int{},
+	}
+}
+
+func (c *mapResultCache) Get(key string) (*Blob, bool) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	buf, ok := c.Map[key]
+	if !ok {
+		return nil, false
+	}
+	blob := NewBlobFromBytes(buf)
+	blob.Stat = c.Stat[key]
+	return blob, true
+}
+
+func (c *mapResultCache) Set(key string, blob *Blob) (*Blob, bool) {
+	buf, err := blob.ReadAll()
+	if err != nil {
+		return nil, false
+	}
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.Map[key] = buf
+	c.Stat[key] = blob.Stat
+	c.SetCnt[key]++
+	cached := NewBlobFromBytes(buf)
+	cached.Stat = blob.Stat
+	return cached, true
+}
+
+func (c *mapResultCache) Delete(key string) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	delete(c.Map, key)
+	c.DelCnt[key]++
+}
+
+func TestWithResultCache(t *testing.T) {
+	store := newMapStore()
+	resultStore := newMapStore()
+	cache := newMapResultCache()
+	app := New(
+		WithDebug(true), WithLogger(zap.NewExample()),
+		WithStorages(store),
+		WithResultStorages(resultStore),
+		WithResultCache(cache),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+		WithUnsafe(true),
+		WithModifiedTimeCheck(true),
+	)
+	request := func() {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/foo", nil))
+		time.Sleep(time.Millisecond * 10) // make sure storage reached
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "foo", w.Body.String())
+	}
+	request()
+	assert.Equal(t, 1, store.SaveCnt["foo"])
+	assert.Equal(t, 1, resultStore.SaveCnt["foo"])
+	assert.Equal(t, 1, cache.SetCnt["foo"])
+
+	// served from cache without reaching result storage
+	request()
+	request()
+	assert.Equal(t, 0, resultStore.LoadCnt["foo"])
+	assert.Equal(t, 1, cache.SetCnt["foo"])
+
+	// stale cache entry deleted and result storage consulted
+	cache.Stat["foo"] = &Stat{ModifiedTime: clock}
+	clock = clock.Add(1)
+	store.ModTime["foo"] = clock
+	request()
+	assert.Equal(t, 1, cache.DelCnt["foo"])
+	assert.Equal(t, 1, resultStore.LoadCnt["foo"])
+	assert.Equal(t, 2, resultStore.SaveCnt["foo"])
+	assert.Equal(t, 2, cache.SetCnt["foo"])
+
+	// result storage hit populates cache
+	delete(cache.Map, "foo")
+	request()
+	assert.Equal(t, 2, resultStore.LoadCnt["foo"])
+	assert.Equal(t, 3, cache.SetCnt["foo"])
+	request()
+	assert.Equal(t, 2, resultStore.LoadCnt["foo"])
+}
+
+type presignStore struct {
+	*mapStore
+}
+
+func (s presignStore) Presign(ctx context.Context, key string, expires time.Duration) (string, error) {
+	return "https://bucket.example.com/" + key + "?expires=" + expires.String(), nil
+}
+
+func TestWithResultRedirect(t *testing.T) {
+	resultStore := presignStore{newMapStore()}
+	app := New(
+		WithDebug(true), WithLogger(zap.NewExample()),
+		WithResultStorages(resultStore),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+		WithUnsafe(true),
+		WithResultRedirect(http.StatusTemporaryRedirect),
+		WithResultRedirectExpires(time.Minute),
+	)
+	assert.Equal(t, http.StatusTemporaryRedirect, app.ResultRedirect)
+
+	// result storage miss processed and streamed
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodGet, "https://example.com/unsafe/foo", nil))
+	time.Sleep(time.Millisecond * 10) // make sure storage reached
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "foo", w.Body.String())
+	assert.Equal(t, 1, resultStore.SaveCnt["foo"])
+
+	// result storage hit redirected
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodGet, "https://example.com/unsafe/foo", nil))
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://bucket.example.com/foo?expires=1m0s", w.Header().Get("Location"))
+	assert.Equal(t, 0, resultStore.LoadCnt["foo"])
+
+	// Serve returns the blob
+	blob, err := app.Serve(context.Background(), imagorpath.Params{Image: "foo"})
+	require.NoError(t, err)
+	buf, err := blob.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(buf))
+	assert.Equal(t, 1, resultStore.LoadCnt["foo"])
+
+	// result storage without presigner streamed
+	store := newMapStore()
+	app = New(
+		WithResultStorages(store),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+		WithUnsafe(true),
+		WithResultRedirect(http.StatusFound),
+	)
+	for i := 0; i < 2; i++ {
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/foo", nil))
+		time.Sleep(time.Millisecond * 10) // make sure storage reached
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "foo", w.Body.String())
+	}
+	assert.Equal(t, 1, store.LoadCnt["foo"])
+
+	assert.Equal(t, 0, New(WithResultRedirect(http.StatusMovedPermanently)).ResultRedirect)
+}
+
 func TestWithSameStore(t *testing.T) {
 	store := newMapStore()
 	app := New(
@@ -3205,9 +3423,85 @@ func TestResponseRawOnError(t *testing.T) {
 	})
 }
 
+func TestReload(t *testing.T) {
+	signerA := imagorpath.NewDefaultSigner("a")
+	signerB := imagorpath.NewDefaultSigner("b")
+	app := New(
+		WithSigner(signerA),
+		WithPresets(map[string]string{"thumb": "100x100"}),
+		WithProcessConcurrency(1),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+	)
+	serve := func(signer imagorpath.Signer) int {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/"+
+			imagorpath.Generate(imagorpath.Params{Image: "foo.jpg"}, signer), nil))
+		return w.Code
+	}
+	assert.Equal(t, 200, serve(signerA))
+	sema := app.loadSettings().sema
+
+	require.NoError(t, app.Reload(New(
+		WithSigner(signerB),
+		WithPresets(map[string]string{"hero": "1200x0"}),
+		WithProcessConcurrency(1),
+	)))
+	assert.Equal(t, 403, serve(signerA))
+	assert.Equal(t, 200, serve(signerB))
+	assert.Equal(t, signerB, app.RequestSigner(nil))
+	assert.Equal(t, signerA, app.Signer, "exported fields keep initial values")
+	_, err := app.Preset("thumb", "foo.jpg")
+	assert.ErrorIs(t, err, ErrPresetNotFound)
+	p, err := app.Preset("hero", "foo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, 1200, p.Width)
+	assert.Same(t, sema, app.loadSettings().sema, "semaphore kept if limits unchanged")
+
+	require.NoError(t, app.Reload(New(WithSigner(signerB), WithProcessConcurrency(2))))
+	assert.NotSame(t, sema, app.loadSettings().sema)
+	assert.Equal(t, int64(2), app.loadSettings().processConcurrency)
+	assert.Equal(t, 200, serve(signerB))
+
+	assert.Error(t, app.Reload("foo"))
+	assert.Error(t, app.Reload((*Imagor)(nil)))
+	assert.Equal(t, 200, serve(signerB))
+}
+
 // TestWithGetSignerOption verifies that WithGetSigner correctly wires the
 // function into the Imagor struct and that the static Signer is NOT set
 // (so New() won't install the default signer and shadow it).
+type lifecycleStore struct {
+	*mapStore
+	name   string
+	events *[]string
+}
+
+func (s *lifecycleStore) Startup(_ context.Context) error {
+	*s.events = append(*s.events, "startup "+s.name)
+	return nil
+}
+
+func (s *lifecycleStore) Shutdown(_ context.Context) error {
+	*s.events = append(*s.events, "shutdown "+s.name)
+	return nil
+}
+
+func TestLifecycle(t *testing.T) {
+	var events []string
+	app := New(
+		WithLoaders(&lifecycleStore{mapStore: newMapStore(), name: "loader", events: &events}),
+		WithStorages(&lifecycleStore{mapStore: newMapStore(), name: "storage", events: &events}, newMapStore()),
+		WithResultStorages(&lifecycleStore{mapStore: newMapStore(), name: "result", events: &events}),
+	)
+	require.NoError(t, app.Startup(context.Background()))
+	assert.Equal(t, []string{"startup loader", "startup storage", "startup result"}, events)
+	events = nil
+	require.NoError(t, app.Shutdown(context.Background()))
+	assert.Equal(t, []string{"shutdown loader", "shutdown storage", "shutdown result"}, events)
+}
+
 func TestWithGetSignerOption(t *testing.T) {
 	staticSigner := imagorpath.NewDefaultSigner("abc")
 
@@ -57,10 +57,12 @@ var SuffixResultStorageHasher = ResultStorageHasherFunc(func(p Params) string {
 	}
 	var digest = sha1.Sum([]byte(p.Path))
 	var hash = "." + hex.EncodeToString(digest[:])[:20]
-	var dotIdx = strings.LastIndex(p.Image, ".")
-	var slashIdx = strings.LastIndex(p.Image, "/")
+	// version selector is covered by digest
+	var image, _ = SplitVersion(p.Image)
+	var dotIdx = strings.LastIndex(image, ".")
+	var slashIdx = strings.LastIndex(image, "/")
 	if dotIdx > -1 && slashIdx < dotIdx {
-		ext := p.Image[dotIdx:]
+		ext := image[dotIdx:]
 		if p.Meta {
 			ext = ".json"
 		} else {
@@ -70,9 +72,9 @@ var SuffixResultStorageHasher = ResultStorageHasherFunc(func(p Params) string {
 				}
 			}
 		}
-		return p.Image[:dotIdx] + hash + ext // /abc/def.{digest}.jpg
+		return image[:dotIdx] + hash + ext // /abc/def.{digest}.jpg
 	}
-	return p.Image + hash // /abc/def.{digest}
+	return image + hash // /abc/def.{digest}
 })
 
 // SizeSuffixResultStorageHasher  ResultStorageHasher using storage path with digest and size suffix
@@ -85,10 +87,12 @@ var SizeSuffixResultStorageHasher = ResultStorageHasherFunc(func(p Params) strin
 	if p.Width != 0 || p.Height != 0 {
 		hash += "_" + strconv.Itoa(p.Width) + "x" + strconv.Itoa(p.Height)
 	}
-	var dotIdx = strings.LastIndex(p.Image, ".")
-	var slashIdx = strings.LastIndex(p.Image, "/")
+	// version selector is covered by digest
+	var image, _ = SplitVersion(p.Image)
+	var dotIdx = strings.LastIndex(image, ".")
+	var slashIdx = strings.LastIndex(image, "/")
 	if dotIdx > -1 && slashIdx < dotIdx {
-		ext := p.Image[dotIdx:]
+		ext := image[dotIdx:]
 		if p.Meta {
 			ext = ".json"
 		} else {
@@ -98,7 +102,7 @@ var SizeSuffixResultStorageHasher = ResultStorageHasherFunc(func(p Params) strin
 				}
 			}
 		}
-		return p.Image[:dotIdx] + hash + ext // /abc/def.{digest}_{width}x{height}.jpg
+		return image[:dotIdx] + hash + ext // /abc/def.{digest}_{width}x{height}.jpg
 	}
-	return p.Image + hash // /abc/def.{digest}_{width}x{height}
+	return image + hash // /abc/def.{digest}_{width}x{height}
 })
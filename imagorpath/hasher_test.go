@@ -55,6 +55,16 @@ func TestSuffixResultStorageHasher(t *testing.T) {
 	fmt.Println(GeneratePath(p))
 	assert.Equal(t, "example.com/foobar.c80ab0faf85b35a140a8.json", SuffixResultStorageHasher.HashResult(p))
 	assert.Equal(t, "example.com/foobar.c80ab0faf85b35a140a8_17x19.json", SizeSuffixResultStorageHasher.HashResult(p))
+
+	// version selector covered by digest
+	p1 := Params{Width: 17, Height: 19, Image: "foobar.jpg?versionId=3/L4k.x"}
+	p2 := Params{Width: 17, Height: 19, Image: "foobar.jpg?versionId=3/L4k.y"}
+	h1 := SuffixResultStorageHasher.HashResult(p1)
+	assert.Regexp(t, `^foobar\.[0-9a-f]{20}\.jpg$`, h1)
+	assert.NotEqual(t, h1, SuffixResultStorageHasher.HashResult(p2))
+	h1 = SizeSuffixResultStorageHasher.HashResult(p1)
+	assert.Regexp(t, `^foobar\.[0-9a-f]{20}_17x19\.jpg$`, h1)
+	assert.NotEqual(t, h1, SizeSuffixResultStorageHasher.HashResult(p2))
 }
 
 func TestHasherFunctionAdapters(t *testing.T) {
@@ -1,6 +1,7 @@
 package imagorpath
 
 import (
+	"net/url"
 	"path"
 	"strings"
 )
@@ -141,3 +142,39 @@ func Normalize(image string, safeChars SafeChars) string {
 	}
 	return escape(image, safeChars.ShouldEscape)
 }
+
+// Denormalize reverses the escaping of Normalize, for storage paths listed from storages
+// to be used as image keys, e.g. foo%3Abar.jpg as foo:bar.jpg.
+// Path cleaning of Normalize is not reversible, e.g. https:// normalized as https:/
+func Denormalize(image string, safeChars SafeChars) string {
+	if safeChars == nil {
+		safeChars = defaultSafeChars
+	}
+	if !safeChars.ShouldEscape('%') {
+		// escaped and literal % are indistinguishable
+		return image
+	}
+	unescape := url.PathUnescape
+	if safeChars.ShouldEscape('+') {
+		// space escaped as +
+		unescape = url.QueryUnescape
+	}
+	if res, err := unescape(image); err == nil {
+		return res
+	}
+	return image
+}
+
+// VersionSelector image key suffix selecting an object version e.g. image.jpg?versionId=abc
+const VersionSelector = "?versionId="
+
+// SplitVersion splits image key into key and version ID of its version selector.
+// Version ID is empty if image key has no version selector
+func SplitVersion(image string) (key, versionID string) {
+	if i := strings.LastIndex(image, VersionSelector); i > 0 {
+		if v := image[i+len(VersionSelector):]; v != "" && !strings.ContainsAny(v, "?&") {
+			return image[:i], v
+		}
+	}
+	return image, ""
+}
@@ -612,6 +612,21 @@ func TestParamsNegativeDimensionFlip(t *testing.T) {
 	}))
 }
 
+func TestDenormalize(t *testing.T) {
+	for _, image := range []string{
+		"fit-in/800x800/filters:fill(white):format(jpeg)/gopher .png",
+		"foo/bar+baz%2B.jpg",
+		"foo/bar.jpg",
+	} {
+		assert.Equal(t, image, Denormalize(Normalize(image, nil), nil))
+	}
+	safe := NewSafeChars("+")
+	assert.Equal(t, "foo/bar+baz.jpg", Denormalize(Normalize("foo/bar+baz.jpg", safe), safe))
+	noop := NewNoopSafeChars()
+	assert.Equal(t, "foo/bar%3A.jpg", Denormalize("foo/bar%3A.jpg", noop))
+	assert.Equal(t, "foo/%zz.jpg", Denormalize("foo/%zz.jpg", nil))
+}
+
 func TestNormalize(t *testing.T) {
 	assert.Equal(t,
 		"unsafe/fit-in/800x800/filters%3Afill%28white%29%3Awatermark%28raw.githubusercontent.com/cshum/imagor/master/testdata/gopher.png%2Crepeat%2Cbottom%2C10%29%3Aformat%28jpeg%29/https%3A/raw.githubusercontent.com/golang-samples/gopher-vector/master/gopher+.png",
@@ -811,3 +826,21 @@ func TestHasCacheBypass(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitVersion(t *testing.T) {
+	tests := []struct {
+		image, key, versionID string
+	}{
+		{"foo/bar.jpg", "foo/bar.jpg", ""},
+		{"foo/bar.jpg?versionId=abc.123", "foo/bar.jpg", "abc.123"},
+		{"foo/bar.jpg?versionId=", "foo/bar.jpg?versionId=", ""},
+		{"foo/bar.jpg?versionId=3/L4kq+tJlc=", "foo/bar.jpg", "3/L4kq+tJlc="},
+		{"foo/bar.jpg?versionId=abc&foo=bar", "foo/bar.jpg?versionId=abc&foo=bar", ""},
+		{"?versionId=abc", "?versionId=abc", ""},
+	}
+	for _, tt := range tests {
+		key, versionID := SplitVersion(tt.image)
+		assert.Equal(t, tt.key, key, tt.image)
+		assert.Equal(t, tt.versionID, versionID, tt.image)
+	}
+}
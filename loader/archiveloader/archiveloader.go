@@ -0,0 +1,402 @@
+package archiveloader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/dgraph-io/ristretto/v2"
+)
+
+// ArchiveLoader Archive Loader implements imagor.Loader interface.
+// It serves entries inside zip and tar archives with keys like
+// bundles/set42.zip!/img/001.jpg, reading the archive through the underlying
+// loaders or storages with random access
+type ArchiveLoader struct {
+	Loaders   []imagor.Loader
+	Separator string
+	CacheSize int64
+	CacheTTL  time.Duration
+
+	cache *ristretto.Cache[string, *index]
+}
+
+// New creates ArchiveLoader reading archives from loaders in order
+func New(loaders []imagor.Loader, options ...Option) *ArchiveLoader {
+	l := &ArchiveLoader{
+		Loaders:   loaders,
+		Separator: "!/",
+		CacheSize: 32 << 20,
+		CacheTTL:  time.Hour,
+	}
+	for _, option := range options {
+		option(l)
+	}
+	if l.CacheSize > 0 {
+		l.cache, _ = ristretto.NewCache[string, *index](&ristretto.Config[string, *index]{
+			NumCounters: 10000,
+			MaxCost:     l.CacheSize,
+			BufferItems: 64,
+		})
+	}
+	return l
+}
+
+// Path splits image key into archive key and entry name
+func (l *ArchiveLoader) Path(image string) (archive, name string, ok bool) {
+	idx := strings.Index(image, l.Separator)
+	if idx <= 0 {
+		return "", "", false
+	}
+	archive = image[:idx]
+	name = strings.TrimPrefix(image[idx+len(l.Separator):], "/")
+	if name == "" || strings.HasSuffix(name, "/") || formatOf(archive) == formatUnknown {
+		return "", "", false
+	}
+	return archive, name, true
+}
+
+// Get implements imagor.Loader interface
+func (l *ArchiveLoader) Get(r *http.Request, image string) (*imagor.Blob, error) {
+	archiveKey, name, ok := l.Path(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	archive, err := l.load(r, archiveKey)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := l.index(archiveKey, archive)
+	if err != nil {
+		return nil, err
+	}
+	switch idx.format {
+	case formatZip:
+		return l.zipEntry(archive, idx, name)
+	default:
+		return l.tarEntry(archive, idx, name)
+	}
+}
+
+func (l *ArchiveLoader) load(r *http.Request, key string) (blob *imagor.Blob, err error) {
+	err = imagor.ErrNotFound
+	for _, loader := range l.Loaders {
+		b, e := loader.Get(r, key)
+		if b != nil && e == nil {
+			if e = b.Err(); e == nil {
+				return b, nil
+			}
+		}
+		if e != nil && !errors.Is(e, imagor.ErrInvalid) {
+			err = e
+		}
+	}
+	return nil, err
+}
+
+// index returns cached archive index,
+// or builds one if absent or the archive size, ETag or modified time changed
+func (l *ArchiveLoader) index(key string, archive *imagor.Blob) (*index, error) {
+	if size := archive.Size(); size > 0 && l.cache != nil {
+		if idx, ok := l.cache.Get(key); ok && idx.matches(size, archive.Stat) {
+			return idx, nil
+		}
+	}
+	rs, size, err := openReadSeeker(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rs.Close()
+	}()
+	var idx *index
+	switch formatOf(key) {
+	case formatZip:
+		idx, err = buildZipIndex(rs, size)
+	default:
+		idx, err = buildTarIndex(rs, size)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if archive.Stat != nil {
+		idx.etag = archive.Stat.ETag
+		idx.modTime = archive.Stat.ModifiedTime
+	}
+	if l.cache != nil {
+		l.cache.SetWithTTL(key, idx, idx.cost(), l.CacheTTL)
+	}
+	return idx, nil
+}
+
+func (l *ArchiveLoader) zipEntry(archive *imagor.Blob, idx *index, name string) (*imagor.Blob, error) {
+	f, err := findZipFile(idx, &readerAt{}, name)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(f.UncompressedSize64)
+	blob := imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+		rs, _, err := openReadSeeker(archive)
+		if err != nil {
+			return nil, 0, err
+		}
+		f, err := findZipFile(idx, &readerAt{rs: rs}, name)
+		if err != nil {
+			_ = rs.Close()
+			return nil, 0, err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			_ = rs.Close()
+			return nil, 0, err
+		}
+		return &entryReader{Reader: rc, closers: []io.Closer{rc, rs}}, size, nil
+	})
+	blob.Stat = &imagor.Stat{
+		Size:         size,
+		ModifiedTime: f.Modified,
+	}
+	return blob, nil
+}
+
+func (l *ArchiveLoader) tarEntry(archive *imagor.Blob, idx *index, name string) (*imagor.Blob, error) {
+	e, ok := idx.entries[name]
+	if !ok {
+		return nil, imagor.ErrNotFound
+	}
+	blob := imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+		rs, _, err := openReadSeeker(archive)
+		if err != nil {
+			return nil, 0, err
+		}
+		if _, err = rs.Seek(e.offset, io.SeekStart); err != nil {
+			_ = rs.Close()
+			return nil, 0, err
+		}
+		return &entryReader{
+			Reader: io.LimitReader(rs, e.size), closers: []io.Closer{rs},
+		}, e.size, nil
+	})
+	blob.Stat = &imagor.Stat{
+		Size:         e.size,
+		ModifiedTime: e.modTime,
+	}
+	return blob, nil
+}
+
+type format int
+
+const (
+	formatUnknown format = iota
+	formatZip
+	formatTar
+)
+
+func formatOf(key string) format {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".zip", ".cbz":
+		return formatZip
+	case ".tar":
+		return formatTar
+	default:
+		return formatUnknown
+	}
+}
+
+type entry struct {
+	offset  int64
+	size    int64
+	modTime time.Time
+}
+
+// index of archive.
+// For zip, it keeps the archive tail holding the central directory,
+// so that the directory is parsed without fetching the archive again.
+// For tar, it keeps the data offset of each regular file
+type index struct {
+	format     format
+	size       int64
+	etag       string
+	modTime    time.Time
+	tail       []byte
+	tailOffset int64
+	entries    map[string]entry
+}
+
+// matches checks if the index is built from an archive of the same size, ETag and modified time
+func (idx *index) matches(size int64, stat *imagor.Stat) bool {
+	var (
+		etag    string
+		modTime time.Time
+	)
+	if stat != nil {
+		etag = stat.ETag
+		modTime = stat.ModifiedTime
+	}
+	return idx.size == size && idx.etag == etag && idx.modTime.Equal(modTime)
+}
+
+func (idx *index) cost() int64 {
+	cost := int64(len(idx.tail)) + 64
+	for name := range idx.entries {
+		cost += int64(len(name)) + 48
+	}
+	return cost
+}
+
+func buildZipIndex(rs io.ReadSeeker, size int64) (*index, error) {
+	ra := &readerAt{rs: rs, minOffset: size}
+	if _, err := zip.NewReader(ra, size); err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return nil, imagor.NewError(err.Error(), http.StatusUnprocessableEntity)
+	}
+	tail := make([]byte, size-ra.minOffset)
+	if _, err := ra.ReadAt(tail, ra.minOffset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return &index{
+		format:     formatZip,
+		size:       size,
+		tail:       tail,
+		tailOffset: ra.minOffset,
+	}, nil
+}
+
+// findZipFile parses the cached central directory, reading file data from ra
+func findZipFile(idx *index, ra *readerAt, name string) (*zip.File, error) {
+	ra.tail = idx.tail
+	ra.tailOffset = idx.tailOffset
+	zr, err := zip.NewReader(ra, idx.size)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return nil, imagor.NewError(err.Error(), http.StatusUnprocessableEntity)
+	}
+	for _, f := range zr.File {
+		if f.Name == name && !f.FileInfo().IsDir() {
+			return f, nil
+		}
+	}
+	return nil, imagor.ErrNotFound
+}
+
+func buildTarIndex(rs io.ReadSeeker, size int64) (*index, error) {
+	cr := &countingReader{rs: rs}
+	tr := tar.NewReader(cr)
+	entries := map[string]entry{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, imagor.NewError(err.Error(), http.StatusUnprocessableEntity)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		// tar reader consumes exactly the header blocks,
+		// so current position is the start of file data
+		entries[strings.TrimPrefix(path.Clean(hdr.Name), "/")] = entry{
+			offset:  cr.pos,
+			size:    hdr.Size,
+			modTime: hdr.ModTime,
+		}
+	}
+	return &index{
+		format:  formatTar,
+		size:    size,
+		entries: entries,
+	}, nil
+}
+
+func openReadSeeker(blob *imagor.Blob) (io.ReadSeekCloser, int64, error) {
+	rs, size, err := blob.NewReadSeeker()
+	if err != nil {
+		return nil, 0, err
+	}
+	if size <= 0 {
+		if size, err = rs.Seek(0, io.SeekEnd); err != nil {
+			_ = rs.Close()
+			return nil, 0, err
+		}
+	}
+	return rs, size, nil
+}
+
+// readerAt adapts io.ReadSeeker to io.ReaderAt, serving reads at or after
+// tailOffset from the cached tail, and tracking the minimum offset read
+type readerAt struct {
+	rs         io.ReadSeeker
+	tail       []byte
+	tailOffset int64
+	minOffset  int64
+	mu         sync.Mutex
+}
+
+func (r *readerAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if r.tail != nil && off >= r.tailOffset {
+		if off-r.tailOffset >= int64(len(r.tail)) {
+			return 0, io.EOF
+		}
+		n = copy(p, r.tail[off-r.tailOffset:])
+		if n < len(p) {
+			err = io.EOF
+		}
+		return
+	}
+	if r.rs == nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if off < r.minOffset {
+		r.minOffset = off
+	}
+	if _, err = r.rs.Seek(off, io.SeekStart); err != nil {
+		return
+	}
+	n, err = io.ReadFull(r.rs, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return
+}
+
+// countingReader tracks read position, skipping with Seek
+type countingReader struct {
+	rs  io.ReadSeeker
+	pos int64
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.rs.Read(p)
+	r.pos += int64(n)
+	return
+}
+
+func (r *countingReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.rs.Seek(offset, whence)
+	if err == nil {
+		r.pos = pos
+	}
+	return pos, err
+}
+
+type entryReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *entryReader) Close() (err error) {
+	for _, c := range r.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
@@ -0,0 +1,244 @@
+package archiveloader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingReadSeeker struct {
+	*bytes.Reader
+	n *int64
+}
+
+func (r *countingReadSeeker) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	atomic.AddInt64(r.n, int64(n))
+	return n, err
+}
+
+func (r *countingReadSeeker) Close() error {
+	return nil
+}
+
+// bytesLoader serves archives from memory, counting bytes read
+type bytesLoader struct {
+	files map[string][]byte
+	etags map[string]string
+	read  int64
+}
+
+func (l *bytesLoader) Get(_ *http.Request, key string) (*imagor.Blob, error) {
+	buf, ok := l.files[key]
+	if !ok {
+		return nil, imagor.ErrNotFound
+	}
+	blob := imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+		return &countingReadSeeker{Reader: bytes.NewReader(buf), n: &l.read}, int64(len(buf)), nil
+	})
+	if etag, ok := l.etags[key]; ok {
+		blob.Stat = &imagor.Stat{ETag: etag, Size: int64(len(buf))}
+	}
+	return blob, nil
+}
+
+func newZip(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func newTar(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	require.NoError(t, w.WriteHeader(&tar.Header{Name: "./img/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for name, content := range files {
+		require.NoError(t, w.WriteHeader(&tar.Header{
+			Name: name, Mode: 0644, Size: int64(len(content)), ModTime: time.Unix(1700000000, 0),
+		}))
+		_, err := w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestArchiveLoader_Path(t *testing.T) {
+	tests := []struct {
+		image      string
+		archive    string
+		name       string
+		expectedOk bool
+	}{
+		{image: "bundles/set42.zip!/img/001.jpg", archive: "bundles/set42.zip", name: "img/001.jpg", expectedOk: true},
+		{image: "bundles/set42.ZIP!/img/001.jpg", archive: "bundles/set42.ZIP", name: "img/001.jpg", expectedOk: true},
+		{image: "set42.tar!//img/001.jpg", archive: "set42.tar", name: "img/001.jpg", expectedOk: true},
+		{image: "bundles/set42.zip", expectedOk: false},
+		{image: "bundles/set42.zip!/", expectedOk: false},
+		{image: "bundles/set42.zip!/img/", expectedOk: false},
+		{image: "bundles/set42.rar!/img/001.jpg", expectedOk: false},
+		{image: "!/img/001.jpg", expectedOk: false},
+	}
+	l := New(nil)
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			archive, name, ok := l.Path(tt.image)
+			assert.Equal(t, tt.expectedOk, ok)
+			assert.Equal(t, tt.archive, archive)
+			assert.Equal(t, tt.name, name)
+		})
+	}
+}
+
+func TestZip(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 50; i++ {
+		files[fmt.Sprintf("img/%03d.jpg", i)] = fmt.Sprintf("image %d %s", i, bytes.Repeat([]byte("x"), 1000))
+	}
+	src := &bytesLoader{files: map[string][]byte{
+		"bundles/set42.zip": newZip(t, files),
+	}}
+	l := New([]imagor.Loader{src})
+	r := (&http.Request{}).WithContext(context.Background())
+
+	b, err := l.Get(r, "bundles/set42.zip!/img/007.jpg")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, files["img/007.jpg"], string(buf))
+	require.NotNil(t, b.Stat)
+	assert.Equal(t, int64(len(files["img/007.jpg"])), b.Stat.Size)
+	l.cache.Wait()
+
+	// cached central directory, only the entry data is read
+	read := atomic.LoadInt64(&src.read)
+	b, err = l.Get(r, "bundles/set42.zip!/img/042.jpg")
+	require.NoError(t, err)
+	buf, err = b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, files["img/042.jpg"], string(buf))
+	assert.Less(t, atomic.LoadInt64(&src.read)-read, read)
+
+	_, err = l.Get(r, "bundles/set42.zip!/img/999.jpg")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	_, err = l.Get(r, "bundles/set43.zip!/img/001.jpg")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	_, err = l.Get(r, "bundles/set42.zip")
+	assert.Equal(t, imagor.ErrInvalid, err)
+}
+
+func TestZipCacheInvalidate(t *testing.T) {
+	src := &bytesLoader{files: map[string][]byte{
+		"a.zip": newZip(t, map[string]string{"foo.txt": "foo"}),
+	}}
+	l := New([]imagor.Loader{src})
+	r := (&http.Request{}).WithContext(context.Background())
+
+	b, err := l.Get(r, "a.zip!/foo.txt")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(buf))
+	l.cache.Wait()
+
+	src.files["a.zip"] = newZip(t, map[string]string{"foo.txt": "foo", "bar.txt": "barbar"})
+	b, err = l.Get(r, "a.zip!/bar.txt")
+	require.NoError(t, err)
+	buf, err = b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "barbar", string(buf))
+}
+
+func TestZipCacheInvalidateSameSize(t *testing.T) {
+	src := &bytesLoader{
+		files: map[string][]byte{"a.zip": newZip(t, map[string]string{"foo.txt": "barbar"})},
+		etags: map[string]string{"a.zip": "v1"},
+	}
+	l := New([]imagor.Loader{src})
+	r := (&http.Request{}).WithContext(context.Background())
+
+	b, err := l.Get(r, "a.zip!/foo.txt")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "barbar", string(buf))
+	l.cache.Wait()
+
+	// replaced by a different archive of the same size
+	next := newZip(t, map[string]string{"bar.txt": "foofoo"})
+	require.Len(t, next, len(src.files["a.zip"]))
+	src.files["a.zip"] = next
+	src.etags["a.zip"] = "v2"
+	b, err = l.Get(r, "a.zip!/bar.txt")
+	require.NoError(t, err)
+	buf, err = b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "foofoo", string(buf))
+
+	_, err = l.Get(r, "a.zip!/foo.txt")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
+
+func TestTarFromStorage(t *testing.T) {
+	files := map[string]string{
+		"./img/001.jpg": "foo",
+		"img/002.jpg":   "barbar",
+	}
+	ctx := context.Background()
+	s := filestorage.New(t.TempDir())
+	require.NoError(t, s.Put(ctx, "bundles/set42.tar", imagor.NewBlobFromBytes(newTar(t, files))))
+
+	l := New([]imagor.Loader{s}, WithCacheSize(0))
+	r := (&http.Request{}).WithContext(ctx)
+
+	b, err := l.Get(r, "bundles/set42.tar!/img/001.jpg")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(buf))
+	assert.Equal(t, time.Unix(1700000000, 0), b.Stat.ModifiedTime)
+
+	b, err = l.Get(r, "bundles/set42.tar!/img/002.jpg")
+	require.NoError(t, err)
+	buf, err = b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "barbar", string(buf))
+
+	_, err = l.Get(r, "bundles/set42.tar!/img")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
+
+func TestInvalidArchive(t *testing.T) {
+	src := &bytesLoader{files: map[string][]byte{
+		"a.zip": []byte("not a zip"),
+		"a.tar": []byte("not a tar"),
+	}}
+	l := New([]imagor.Loader{src})
+	r := (&http.Request{}).WithContext(context.Background())
+
+	_, err := l.Get(r, "a.zip!/foo.txt")
+	assert.Equal(t, http.StatusUnprocessableEntity, imagor.WrapError(err).Code)
+	_, err = l.Get(r, "a.tar!/foo.txt")
+	assert.Equal(t, http.StatusUnprocessableEntity, imagor.WrapError(err).Code)
+}
@@ -0,0 +1,33 @@
+package archiveloader
+
+import "time"
+
+// Option ArchiveLoader option
+type Option func(l *ArchiveLoader)
+
+// WithSeparator with separator between archive key and entry name option, default "!/"
+func WithSeparator(separator string) Option {
+	return func(l *ArchiveLoader) {
+		if separator != "" {
+			l.Separator = separator
+		}
+	}
+}
+
+// WithCacheSize with archive index cache size in bytes option. Set 0 to disable cache
+func WithCacheSize(size int64) Option {
+	return func(l *ArchiveLoader) {
+		if size >= 0 {
+			l.CacheSize = size
+		}
+	}
+}
+
+// WithCacheTTL with archive index cache TTL option
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(l *ArchiveLoader) {
+		if ttl > 0 {
+			l.CacheTTL = ttl
+		}
+	}
+}
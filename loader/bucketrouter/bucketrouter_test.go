@@ -0,0 +1,116 @@
+package bucketrouter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testBucket struct {
+	Name   string `yaml:"name"`
+	Region string `yaml:"region"`
+}
+
+func (b testBucket) BucketName() string {
+	return b.Name
+}
+
+type testStorage struct {
+	bucket string
+	files  map[string]string
+}
+
+func (s *testStorage) Get(_ *http.Request, key string) (*imagor.Blob, error) {
+	if v, ok := s.files[s.bucket+"/"+key]; ok {
+		return imagor.NewBlobFromBytes([]byte(v)), nil
+	}
+	return nil, imagor.ErrNotFound
+}
+
+func TestLoadYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "router.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+routing_pattern: "^(?P<bucket>[a-z]+)/(?P<path>.+)$"
+default_bucket:
+  name: default
+fallback_buckets:
+  - name: fb
+    region: eu
+rules:
+  - match: sg
+    bucket:
+      name: sg-bucket
+      region: ap
+`), 0644))
+	router, err := LoadYAML[testBucket](path)
+	require.NoError(t, err)
+	assert.Equal(t, &testBucket{Name: "sg-bucket", Region: "ap"}, router.ConfigFor("sg/a.jpg"))
+	assert.Equal(t, &testBucket{Name: "default"}, router.ConfigFor("us/a.jpg"))
+	assert.Equal(t, []*testBucket{{Name: "fb", Region: "eu"}}, router.Fallbacks())
+	assert.Equal(t, "a.jpg", router.KeyFor("sg/a.jpg"))
+	assert.Len(t, router.AllConfigs(), 3)
+
+	require.NoError(t, os.WriteFile(path, []byte(`routing_pattern: "^(?P<bucket>[a-z]+)/"`), 0644))
+	router, err = LoadYAML[testBucket](path)
+	require.NoError(t, err)
+	assert.Nil(t, router.DefaultConfig())
+
+	require.NoError(t, os.WriteFile(path, []byte(`default_bucket: {name: foo}`), 0644))
+	_, err = LoadYAML[testBucket](path)
+	assert.Error(t, err)
+}
+
+func TestBuckets(t *testing.T) {
+	files := map[string]string{
+		"sg-bucket/a.jpg": "sg",
+		"fb/b.jpg":        "fb",
+		"us/c.jpg":        "us",
+	}
+	router, err := NewPatternRouter(`^(?P<bucket>[a-z]+)/(?P<path>.+)$`,
+		[]MatchRule[testBucket]{{Match: "sg", Config: &testBucket{Name: "sg-bucket"}}},
+		nil, []*testBucket{{Name: "fb"}})
+	require.NoError(t, err)
+	var dynamic []string
+	b := NewBuckets(router, func(cfg *testBucket) *testStorage {
+		return &testStorage{bucket: cfg.Name, files: files}
+	}, func(bucket string) *testStorage {
+		dynamic = append(dynamic, bucket)
+		return &testStorage{bucket: bucket, files: files}
+	})
+	read := func(image string) (string, error) {
+		s, err := b.Route(image)
+		if err != nil {
+			return "", err
+		}
+		blob, err := b.Get(httptest.NewRequest(http.MethodGet, "/", nil), s, router.KeyFor(image))
+		if err != nil {
+			return "", err
+		}
+		buf, err := blob.ReadAll()
+		return string(buf), err
+	}
+
+	v, err := read("sg/a.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "sg", v)
+	// fallback bucket
+	v, err = read("sg/b.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "fb", v)
+	// passthrough bucket created once
+	v, err = read("us/c.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "us", v)
+	_, err = read("us/d.jpg")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	assert.Equal(t, []string{"us"}, dynamic)
+	// no bucket captured
+	_, err = read("A.jpg")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
@@ -0,0 +1,98 @@
+package bucketrouter
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/cshum/imagor"
+)
+
+// Storage storage of a bucket
+type Storage interface {
+	comparable
+	imagor.Loader
+}
+
+// Buckets storages of buckets routed by Router.
+// Storages of configured buckets are created upfront,
+// and storages of buckets captured by the routing pattern on demand in passthrough mode
+type Buckets[T Bucket, S Storage] struct {
+	router    Router[T]
+	dynamic   func(bucket string) S
+	storages  map[string]S
+	fallbacks []S
+	passthru  sync.Map // bucket name → S
+}
+
+// NewBuckets creates Buckets of router, with storages of configured buckets created by storage,
+// and storages of passthrough buckets created by dynamic
+func NewBuckets[T Bucket, S Storage](
+	router Router[T], storage func(cfg *T) S, dynamic func(bucket string) S,
+) *Buckets[T, S] {
+	b := &Buckets[T, S]{
+		router:   router,
+		dynamic:  dynamic,
+		storages: make(map[string]S),
+	}
+	for _, cfg := range router.AllConfigs() {
+		b.storages[(*cfg).BucketName()] = storage(cfg)
+	}
+	for _, fb := range router.Fallbacks() {
+		if fb == nil {
+			continue
+		}
+		if s, ok := b.storages[(*fb).BucketName()]; ok {
+			b.fallbacks = append(b.fallbacks, s)
+		}
+	}
+	return b
+}
+
+// Route returns storage of the bucket routed by key.
+// If no rule matches and no default bucket configured,
+// the bucket name captured by the routing pattern is used directly
+func (b *Buckets[T, S]) Route(key string) (s S, err error) {
+	if cfg := b.router.ConfigFor(key); cfg != nil {
+		s, ok := b.storages[(*cfg).BucketName()]
+		if !ok {
+			return s, imagor.ErrNotFound
+		}
+		return s, nil
+	}
+	bucket := b.router.BucketNameFor(key)
+	if bucket == "" {
+		return s, imagor.ErrNotFound
+	}
+	if v, ok := b.passthru.Load(bucket); ok {
+		return v.(S), nil
+	}
+	actual, _ := b.passthru.LoadOrStore(bucket, b.dynamic(bucket))
+	return actual.(S), nil
+}
+
+// Get gets key from storage, or from the fallback buckets in order if not found
+func (b *Buckets[T, S]) Get(r *http.Request, storage S, key string) (*imagor.Blob, error) {
+	blob, err := storage.Get(r, key)
+	if err == nil {
+		return blob, nil
+	}
+
+	if err != imagor.ErrNotFound {
+		return nil, err
+	}
+
+	for _, fb := range b.fallbacks {
+		if fb == storage {
+			continue
+		}
+		blob, err = fb.Get(r, key)
+		if err == nil {
+			return blob, nil
+		}
+		if err != imagor.ErrNotFound {
+			return nil, err
+		}
+	}
+
+	return nil, imagor.ErrNotFound
+}
@@ -0,0 +1,219 @@
+// Package bucketrouter routes image keys to buckets by a routing pattern,
+// shared by the bucket router loaders of cloud storages
+package bucketrouter
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Bucket config of a bucket, identified by its name
+type Bucket interface {
+	BucketName() string
+}
+
+// Router routes image keys to bucket configs
+type Router[T Bucket] interface {
+	ConfigFor(key string) *T
+	KeyFor(key string) string
+	BucketNameFor(key string) string
+	Fallbacks() []*T
+	DefaultConfig() *T
+	AllConfigs() []*T
+}
+
+// MatchRule routes keys of bucket identifier Match captured by the routing pattern to Config
+type MatchRule[T Bucket] struct {
+	Match  string
+	Config *T
+}
+
+// PatternRouter routes keys by the (?P<bucket>...) capture group of the routing pattern,
+// and optionally strips keys to the (?P<path>...) capture group
+type PatternRouter[T Bucket] struct {
+	pattern       *regexp.Regexp
+	bucketGroup   int
+	pathGroup     int
+	rules         map[string]*T
+	defaultConfig *T
+	fallbacks     []*T
+}
+
+// NewPatternRouter creates PatternRouter of pattern, with at most 2 fallbacks
+func NewPatternRouter[T Bucket](pattern string, rules []MatchRule[T], defaultConfig *T, fallbacks []*T) (*PatternRouter[T], error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketGroup := -1
+	pathGroup := -1
+	for i, name := range re.SubexpNames() {
+		switch name {
+		case "bucket":
+			bucketGroup = i
+		case "path":
+			pathGroup = i
+		}
+	}
+	if bucketGroup == -1 {
+		return nil, fmt.Errorf("pattern must contain named capture group (?P<bucket>...)")
+	}
+
+	if len(fallbacks) > 2 {
+		fallbacks = fallbacks[:2]
+	}
+
+	rulesMap := make(map[string]*T, len(rules))
+	for _, r := range rules {
+		rulesMap[r.Match] = r.Config
+	}
+
+	return &PatternRouter[T]{
+		pattern:       re,
+		bucketGroup:   bucketGroup,
+		pathGroup:     pathGroup,
+		rules:         rulesMap,
+		defaultConfig: defaultConfig,
+		fallbacks:     fallbacks,
+	}, nil
+}
+
+// ConfigFor returns bucket config of the rule matching key, or the default config
+func (r *PatternRouter[T]) ConfigFor(key string) *T {
+	key = strings.TrimPrefix(key, "/")
+
+	matches := r.pattern.FindStringSubmatch(key)
+	if matches == nil || len(matches) <= r.bucketGroup {
+		return r.defaultConfig
+	}
+
+	bucketID := matches[r.bucketGroup]
+	if cfg, ok := r.rules[bucketID]; ok {
+		return cfg
+	}
+
+	return r.defaultConfig
+}
+
+// BucketNameFor returns the raw bucket identifier captured by the (?P<bucket>...)
+// group for the given key, or an empty string if the pattern does not match.
+// This is used for passthrough/wildcard routing when no rules or default are configured.
+func (r *PatternRouter[T]) BucketNameFor(key string) string {
+	trimmed := strings.TrimPrefix(key, "/")
+	matches := r.pattern.FindStringSubmatch(trimmed)
+	if matches == nil || len(matches) <= r.bucketGroup {
+		return ""
+	}
+	return matches[r.bucketGroup]
+}
+
+// KeyFor returns object key captured by the (?P<path>...) group for the given key,
+// or the key unchanged if there is no path group or the pattern does not match
+func (r *PatternRouter[T]) KeyFor(key string) string {
+	if r.pathGroup == -1 {
+		return key
+	}
+	trimmed := strings.TrimPrefix(key, "/")
+	matches := r.pattern.FindStringSubmatch(trimmed)
+	if matches == nil || len(matches) <= r.pathGroup || matches[r.pathGroup] == "" {
+		return key
+	}
+	return matches[r.pathGroup]
+}
+
+// Fallbacks returns fallback bucket configs
+func (r *PatternRouter[T]) Fallbacks() []*T {
+	return r.fallbacks
+}
+
+// DefaultConfig returns default bucket config
+func (r *PatternRouter[T]) DefaultConfig() *T {
+	return r.defaultConfig
+}
+
+// AllConfigs returns bucket configs of default, rules and fallbacks, distinct by bucket name
+func (r *PatternRouter[T]) AllConfigs() []*T {
+	seen := make(map[string]bool)
+	var configs []*T
+
+	addConfig := func(cfg *T) {
+		if cfg != nil && !seen[(*cfg).BucketName()] {
+			seen[(*cfg).BucketName()] = true
+			configs = append(configs, cfg)
+		}
+	}
+
+	addConfig(r.defaultConfig)
+	for _, cfg := range r.rules {
+		addConfig(cfg)
+	}
+	for _, fb := range r.fallbacks {
+		addConfig(fb)
+	}
+
+	return configs
+}
+
+// Fallback returns name of the default bucket
+func (r *PatternRouter[T]) Fallback() string {
+	if r.defaultConfig != nil {
+		return (*r.defaultConfig).BucketName()
+	}
+	return ""
+}
+
+type yamlConfig[T Bucket] struct {
+	RoutingPattern  string `yaml:"routing_pattern"`
+	DefaultBucket   T      `yaml:"default_bucket"`
+	FallbackBuckets []T    `yaml:"fallback_buckets"`
+	Rules           []struct {
+		Match  string `yaml:"match"`
+		Bucket T      `yaml:"bucket"`
+	} `yaml:"rules"`
+}
+
+// LoadYAML loads PatternRouter from YAML config file of routing_pattern,
+// default_bucket, fallback_buckets and rules, with buckets decoded as T
+func LoadYAML[T Bucket](path string) (*PatternRouter[T], error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg yamlConfig[T]
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.RoutingPattern == "" {
+		return nil, fmt.Errorf("routing_pattern is required")
+	}
+
+	var fallbacks []*T
+	for _, fb := range cfg.FallbackBuckets {
+		fallbacks = append(fallbacks, configOf(fb))
+	}
+
+	rules := make([]MatchRule[T], 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		rules = append(rules, MatchRule[T]{
+			Match:  r.Match,
+			Config: configOf(r.Bucket),
+		})
+	}
+
+	return NewPatternRouter(cfg.RoutingPattern, rules, configOf(cfg.DefaultBucket), fallbacks)
+}
+
+// configOf returns bucket config, or nil if bucket name is empty
+func configOf[T Bucket](cfg T) *T {
+	if cfg.BucketName() == "" {
+		return nil
+	}
+	return &cfg
+}
@@ -0,0 +1,46 @@
+package gcloudrouterloader
+
+import (
+	"net/http"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/cshum/imagor/loader/bucketrouter"
+	"github.com/cshum/imagor/storage/gcloudstorage"
+)
+
+// GCloudRouterLoader routes images to Google Cloud Storage buckets by BucketRouter.
+// It implements imagor.Loader interface
+type GCloudRouterLoader struct {
+	router  BucketRouter
+	buckets *bucketrouter.Buckets[BucketConfig, *gcloudstorage.GCloudStorage]
+}
+
+// StorageFactory creates GCloudStorage for the bucket config
+type StorageFactory func(cfg *BucketConfig) *gcloudstorage.GCloudStorage
+
+// New creates GCloudRouterLoader
+func New(router BucketRouter, storageFactory StorageFactory) *GCloudRouterLoader {
+	return &GCloudRouterLoader{
+		router: router,
+		buckets: bucketrouter.NewBuckets(router, storageFactory, func(bucket string) *gcloudstorage.GCloudStorage {
+			// passthrough mode, bucket name captured by the routing pattern with the default credentials
+			return storageFactory(&BucketConfig{Name: bucket})
+		}),
+	}
+}
+
+// Get implements imagor.Loader interface
+func (l *GCloudRouterLoader) Get(r *http.Request, image string) (*imagor.Blob, error) {
+	// version selector is routed with the key, not matched by routing patterns
+	image, versionID := imagorpath.SplitVersion(image)
+	key := l.router.KeyFor(image)
+	if versionID != "" {
+		key += imagorpath.VersionSelector + versionID
+	}
+	loader, err := l.buckets.Route(image)
+	if err != nil {
+		return nil, err
+	}
+	return l.buckets.Get(r, loader, key)
+}
@@ -0,0 +1,149 @@
+package gcloudrouterloader
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/storage/gcloudstorage"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+func fakeGCSClient(t *testing.T, objects map[string]string) *storage.Client {
+	t.Helper()
+	var initialObjects []fakestorage.Object
+	for key, content := range objects {
+		bucket, name, _ := strings.Cut(key, ":")
+		initialObjects = append(initialObjects, fakestorage.Object{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: bucket, Name: name},
+			Content:     []byte(content),
+		})
+	}
+	srv, err := fakestorage.NewServerWithOptions(fakestorage.Options{
+		InitialObjects: initialObjects,
+		NoListener:     true,
+	})
+	require.NoError(t, err)
+	client, err := storage.NewClient(context.Background(), option.WithHTTPClient(srv.HTTPClient()))
+	require.NoError(t, err)
+	t.Cleanup(func() { srv.Stop(); client.Close() })
+	return client
+}
+
+func read(t *testing.T, l *GCloudRouterLoader, image string) (string, error) {
+	blob, err := l.Get(httptest.NewRequest("GET", "/", nil), image)
+	if err != nil {
+		return "", err
+	}
+	buf, err := blob.ReadAll()
+	return string(buf), err
+}
+
+func TestPatternRouter(t *testing.T) {
+	sg := &BucketConfig{Name: "sg-bucket", CredentialsFile: "sg.json"}
+	def := &BucketConfig{Name: "default-bucket"}
+	fb := &BucketConfig{Name: "fallback-bucket"}
+	router, err := NewPatternRouter(`^(?P<bucket>[A-Z]{2})/(?P<path>.+)$`,
+		[]MatchRule{{Match: "SG", Config: sg}}, def, []*BucketConfig{fb})
+	require.NoError(t, err)
+	assert.Equal(t, sg, router.ConfigFor("/SG/foo.jpg"))
+	assert.Equal(t, def, router.ConfigFor("US/foo.jpg"))
+	assert.Equal(t, def, router.ConfigFor("foo.jpg"))
+	assert.Equal(t, "foo.jpg", router.KeyFor("SG/foo.jpg"))
+	assert.Equal(t, "US", router.BucketNameFor("US/foo.jpg"))
+	assert.Len(t, router.AllConfigs(), 3)
+
+	_, err = NewPatternRouter(`^([A-Z]{2})/`, nil, def, nil)
+	assert.Error(t, err)
+}
+
+func TestGCloudRouterLoader(t *testing.T) {
+	client := fakeGCSClient(t, map[string]string{
+		"sg-bucket:foo.jpg":       "sg",
+		"default-bucket:foo.jpg":  "default",
+		"fallback-bucket:bar.jpg": "fallback",
+	})
+	router, err := NewPatternRouter(`^(?P<bucket>[A-Z]{2})/(?P<path>.+)$`,
+		[]MatchRule{{Match: "SG", Config: &BucketConfig{Name: "sg-bucket"}}},
+		&BucketConfig{Name: "default-bucket"},
+		[]*BucketConfig{{Name: "fallback-bucket"}},
+	)
+	require.NoError(t, err)
+	created := map[string]bool{}
+	l := New(router, func(cfg *BucketConfig) *gcloudstorage.GCloudStorage {
+		created[cfg.Name] = true
+		return gcloudstorage.New(client, cfg.Name)
+	})
+	var _ imagor.Loader = l
+	assert.True(t, created["sg-bucket"])
+	assert.True(t, created["default-bucket"])
+	assert.True(t, created["fallback-bucket"])
+
+	buf, err := read(t, l, "SG/foo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "sg", buf)
+
+	buf, err = read(t, l, "US/foo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "default", buf)
+
+	buf, err = read(t, l, "SG/bar.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "fallback", buf)
+
+	_, err = read(t, l, "SG/baz.jpg")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
+
+func TestGCloudRouterLoader_PassthroughMode(t *testing.T) {
+	client := fakeGCSClient(t, map[string]string{
+		"mysite-test:images/photo.jpg": "test",
+	})
+	router, err := NewPatternRouter(`^(?P<bucket>[^/]+)\/(?P<path>.+)$`, nil, nil, nil)
+	require.NoError(t, err)
+	var created []string
+	l := New(router, func(cfg *BucketConfig) *gcloudstorage.GCloudStorage {
+		created = append(created, cfg.Name)
+		return gcloudstorage.New(client, cfg.Name)
+	})
+	assert.Empty(t, created)
+
+	buf, err := read(t, l, "mysite-test/images/photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "test", buf)
+	_, err = read(t, l, "mysite-test/images/other.jpg")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	assert.Equal(t, []string{"mysite-test"}, created)
+
+	_, err = read(t, l, "nobucket")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
+
+func TestGCloudRouterLoader_Versioning(t *testing.T) {
+	client := fakeGCSClient(t, map[string]string{
+		"sg-bucket:foo.jpg": "sg",
+	})
+	ctx := context.Background()
+	router, err := NewPatternRouter(`^(?P<bucket>[A-Z]{2})/(?P<path>.+)$`,
+		[]MatchRule{{Match: "SG", Config: &BucketConfig{Name: "sg-bucket"}}}, nil, nil)
+	require.NoError(t, err)
+	l := New(router, func(cfg *BucketConfig) *gcloudstorage.GCloudStorage {
+		return gcloudstorage.New(client, cfg.Name, gcloudstorage.WithVersioning(true))
+	})
+	attrs, err := client.Bucket("sg-bucket").Object("foo.jpg").Attrs(ctx)
+	require.NoError(t, err)
+
+	buf, err := read(t, l, "SG/foo.jpg?versionId="+strconv.FormatInt(attrs.Generation, 10))
+	require.NoError(t, err)
+	assert.Equal(t, "sg", buf)
+
+	_, err = read(t, l, "SG/foo.jpg?versionId="+strconv.FormatInt(attrs.Generation+1, 10))
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
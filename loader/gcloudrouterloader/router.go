@@ -0,0 +1,32 @@
+package gcloudrouterloader
+
+import (
+	"github.com/cshum/imagor/loader/bucketrouter"
+)
+
+// BucketConfig Google Cloud Storage bucket config.
+// CredentialsFile is the service account credentials of the bucket,
+// default application credentials are used if empty
+type BucketConfig struct {
+	Name            string `yaml:"name"`
+	CredentialsFile string `yaml:"credentials_file"`
+}
+
+// BucketName implements bucketrouter.Bucket interface
+func (c BucketConfig) BucketName() string {
+	return c.Name
+}
+
+// BucketRouter routes image keys to Google Cloud Storage bucket configs
+type BucketRouter = bucketrouter.Router[BucketConfig]
+
+// MatchRule routes keys of bucket identifier captured by the routing pattern to bucket config
+type MatchRule = bucketrouter.MatchRule[BucketConfig]
+
+// PatternRouter routes image keys to Google Cloud Storage bucket configs by routing pattern
+type PatternRouter = bucketrouter.PatternRouter[BucketConfig]
+
+// NewPatternRouter creates PatternRouter of Google Cloud Storage bucket configs
+func NewPatternRouter(pattern string, rules []MatchRule, defaultConfig *BucketConfig, fallbacks []*BucketConfig) (*PatternRouter, error) {
+	return bucketrouter.NewPatternRouter(pattern, rules, defaultConfig, fallbacks)
+}
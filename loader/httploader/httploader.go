@@ -14,6 +14,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -99,7 +100,8 @@ type HTTPLoader struct {
 	// BaseURL base URL for HTTP loader
 	BaseURL *url.URL
 
-	accepts []string
+	accepts        []string
+	allowedSources atomic.Pointer[[]AllowedSource]
 }
 
 // New creates HTTPLoader
@@ -128,9 +130,30 @@ func New(options ...Option) *HTTPLoader {
 			}
 		}
 	}
+	h.allowedSources.Store(&h.AllowedSources)
 	return h
 }
 
+// Reload implements imagor.Reloader interface,
+// swapping AllowedSources of next HTTPLoader atomically
+func (h *HTTPLoader) Reload(next any) error {
+	n, ok := next.(*HTTPLoader)
+	if !ok || n == nil {
+		return fmt.Errorf("httploader: cannot reload from %T", next)
+	}
+	sources := n.loadAllowedSources()
+	h.allowedSources.Store(&sources)
+	return nil
+}
+
+// loadAllowedSources returns the current allowed sources
+func (h *HTTPLoader) loadAllowedSources() []AllowedSource {
+	if sources := h.allowedSources.Load(); sources != nil {
+		return *sources
+	}
+	return h.AllowedSources
+}
+
 // parseAndValidateURL validates and normalizes the image URL
 // Returns the final URL string or an error
 func (h *HTTPLoader) parseAndValidateURL(image string) (string, error) {
@@ -171,7 +194,7 @@ func (h *HTTPLoader) parseAndValidateURL(image string) (string, error) {
 	u = u.JoinPath()
 	u.Fragment = ""
 
-	if !isURLAllowed(u, h.AllowedSources) {
+	if !isURLAllowed(u, h.loadAllowedSources()) {
 		return "", imagor.ErrSourceNotAllowed
 	}
 
@@ -288,7 +311,7 @@ func (h *HTTPLoader) checkRedirect(r *http.Request, via []*http.Request) error {
 	if len(via) >= 10 {
 		return errors.New("stopped after 10 redirects")
 	}
-	if !isURLAllowed(r.URL, h.AllowedSources) {
+	if !isURLAllowed(r.URL, h.loadAllowedSources()) {
 		return imagor.ErrSourceNotAllowed
 	}
 	return nil
@@ -184,6 +184,27 @@ func TestWithAllowedSourceRegexp(t *testing.T) {
 	})
 }
 
+func TestReload(t *testing.T) {
+	loader := New(
+		WithTransport(testTransport{
+			"https://foo.bar/baz": "baz",
+			"https://foo.abc/bar": "foobar",
+		}),
+		WithAllowedSources("foo.bar"),
+	)
+	doTests(t, loader, []test{
+		{name: "allowed source", target: "https://foo.bar/baz", result: "baz"},
+		{name: "not allowed source", target: "https://foo.abc/bar", err: "imagor: 403 http source not allowed"},
+	})
+	assert.NoError(t, loader.Reload(New(WithAllowedSources("*.abc"))))
+	doTests(t, loader, []test{
+		{name: "reloaded not allowed source", target: "https://foo.bar/baz", err: "imagor: 403 http source not allowed"},
+		{name: "reloaded allowed source", target: "https://foo.abc/bar", result: "foobar"},
+	})
+	assert.Error(t, loader.Reload("foo"))
+	assert.Error(t, loader.Reload((*HTTPLoader)(nil)))
+}
+
 func TestWithAllowedSourcesRedirect(t *testing.T) {
 
 	t.Run("Forbidden redirect", func(t *testing.T) {
@@ -1,155 +1,38 @@
 package s3routerloader
 
 import (
-	"fmt"
-	"regexp"
-	"strings"
+	"github.com/cshum/imagor/loader/bucketrouter"
 )
 
+// BucketConfig S3 bucket config, with region, endpoint and credentials of the bucket.
+// Base AWS config is used for those not set
 type BucketConfig struct {
-	Name            string
-	Region          string
-	Endpoint        string
-	AccessKeyID     string
-	SecretAccessKey string
-	SessionToken    string
+	Name            string `yaml:"name"`
+	Region          string `yaml:"region"`
+	Endpoint        string `yaml:"endpoint"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	SessionToken    string `yaml:"session_token"`
+	// SSECustomerKey base64 encoded 256-bit SSE-C customer-provided key of objects
+	// in the bucket, overriding the key of the loader or storage for this bucket
+	SSECustomerKey string `yaml:"sse_customer_key"`
 }
 
-type BucketRouter interface {
-	ConfigFor(key string) *BucketConfig
-	KeyFor(key string) string
-	BucketNameFor(key string) string
-	Fallbacks() []*BucketConfig
-	DefaultConfig() *BucketConfig
-	AllConfigs() []*BucketConfig
+// BucketName implements bucketrouter.Bucket interface
+func (c BucketConfig) BucketName() string {
+	return c.Name
 }
 
-type MatchRule struct {
-	Match  string
-	Config *BucketConfig
-}
-
-type PatternRouter struct {
-	pattern       *regexp.Regexp
-	bucketGroup   int
-	pathGroup     int
-	rules         map[string]*BucketConfig
-	defaultConfig *BucketConfig
-	fallbacks     []*BucketConfig
-}
-
-func NewPatternRouter(pattern string, rules []MatchRule, defaultConfig *BucketConfig, fallbacks []*BucketConfig) (*PatternRouter, error) {
-	re, err := regexp.Compile(pattern)
-	if err != nil {
-		return nil, err
-	}
-
-	bucketGroup := -1
-	pathGroup := -1
-	for i, name := range re.SubexpNames() {
-		switch name {
-		case "bucket":
-			bucketGroup = i
-		case "path":
-			pathGroup = i
-		}
-	}
-	if bucketGroup == -1 {
-		return nil, fmt.Errorf("pattern must contain named capture group (?P<bucket>...)")
-	}
-
-	if len(fallbacks) > 2 {
-		fallbacks = fallbacks[:2]
-	}
+// BucketRouter routes image keys to S3 bucket configs
+type BucketRouter = bucketrouter.Router[BucketConfig]
 
-	rulesMap := make(map[string]*BucketConfig, len(rules))
-	for _, r := range rules {
-		rulesMap[r.Match] = r.Config
-	}
+// MatchRule routes keys of bucket identifier captured by the routing pattern to S3 bucket config
+type MatchRule = bucketrouter.MatchRule[BucketConfig]
 
-	return &PatternRouter{
-		pattern:       re,
-		bucketGroup:   bucketGroup,
-		pathGroup:     pathGroup,
-		rules:         rulesMap,
-		defaultConfig: defaultConfig,
-		fallbacks:     fallbacks,
-	}, nil
-}
-
-func (r *PatternRouter) ConfigFor(key string) *BucketConfig {
-	key = strings.TrimPrefix(key, "/")
-
-	matches := r.pattern.FindStringSubmatch(key)
-	if matches == nil || len(matches) <= r.bucketGroup {
-		return r.defaultConfig
-	}
-
-	bucketID := matches[r.bucketGroup]
-	if cfg, ok := r.rules[bucketID]; ok {
-		return cfg
-	}
+// PatternRouter routes image keys to S3 bucket configs by routing pattern
+type PatternRouter = bucketrouter.PatternRouter[BucketConfig]
 
-	return r.defaultConfig
-}
-
-// BucketNameFor returns the raw bucket identifier captured by the (?P<bucket>...)
-// group for the given key, or an empty string if the pattern does not match.
-// This is used for passthrough/wildcard routing when no rules or default are configured.
-func (r *PatternRouter) BucketNameFor(key string) string {
-	trimmed := strings.TrimPrefix(key, "/")
-	matches := r.pattern.FindStringSubmatch(trimmed)
-	if matches == nil || len(matches) <= r.bucketGroup {
-		return ""
-	}
-	return matches[r.bucketGroup]
-}
-
-func (r *PatternRouter) KeyFor(key string) string {
-	if r.pathGroup == -1 {
-		return key
-	}
-	trimmed := strings.TrimPrefix(key, "/")
-	matches := r.pattern.FindStringSubmatch(trimmed)
-	if matches == nil || len(matches) <= r.pathGroup || matches[r.pathGroup] == "" {
-		return key
-	}
-	return matches[r.pathGroup]
-}
-
-func (r *PatternRouter) Fallbacks() []*BucketConfig {
-	return r.fallbacks
-}
-
-func (r *PatternRouter) DefaultConfig() *BucketConfig {
-	return r.defaultConfig
-}
-
-func (r *PatternRouter) AllConfigs() []*BucketConfig {
-	seen := make(map[string]bool)
-	var configs []*BucketConfig
-
-	addConfig := func(cfg *BucketConfig) {
-		if cfg != nil && !seen[cfg.Name] {
-			seen[cfg.Name] = true
-			configs = append(configs, cfg)
-		}
-	}
-
-	addConfig(r.defaultConfig)
-	for _, cfg := range r.rules {
-		addConfig(cfg)
-	}
-	for _, fb := range r.fallbacks {
-		addConfig(fb)
-	}
-
-	return configs
-}
-
-func (r *PatternRouter) Fallback() string {
-	if r.defaultConfig != nil {
-		return r.defaultConfig.Name
-	}
-	return ""
+// NewPatternRouter creates PatternRouter of S3 bucket configs
+func NewPatternRouter(pattern string, rules []MatchRule, defaultConfig *BucketConfig, fallbacks []*BucketConfig) (*PatternRouter, error) {
+	return bucketrouter.NewPatternRouter(pattern, rules, defaultConfig, fallbacks)
 }
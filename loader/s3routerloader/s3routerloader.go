@@ -1,22 +1,24 @@
 package s3routerloader
 
 import (
+	"context"
 	"net/http"
-	"sync"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/cshum/imagor/loader/bucketrouter"
 	"github.com/cshum/imagor/storage/s3storage"
 )
 
+// S3RouterLoader routes images to S3 buckets by BucketRouter.
+// It implements imagor.Loader, and imagor.Storage for storages and result storages
 type S3RouterLoader struct {
-	router         BucketRouter
-	baseCfg        aws.Config
-	storageFactory S3StorageFactory
-	loaders        map[string]*s3storage.S3Storage
-	fallbacks      []*s3storage.S3Storage
-	dynamicLoaders sync.Map // bucket name → *s3storage.S3Storage, for passthrough mode
+	router  BucketRouter
+	buckets *bucketrouter.Buckets[BucketConfig, *s3storage.S3Storage]
 }
 
 type S3StorageFactory func(cfg aws.Config, bucket string, extraOpts ...s3storage.Option) *s3storage.S3Storage
@@ -26,29 +28,20 @@ func New(
 	router BucketRouter,
 	storageFactory S3StorageFactory,
 ) *S3RouterLoader {
-	l := &S3RouterLoader{
-		router:         router,
-		baseCfg:        baseCfg,
-		storageFactory: storageFactory,
-		loaders:        make(map[string]*s3storage.S3Storage),
+	return &S3RouterLoader{
+		router: router,
+		buckets: bucketrouter.NewBuckets(router, func(bucketCfg *BucketConfig) *s3storage.S3Storage {
+			awsCfg := createAWSConfig(baseCfg, bucketCfg)
+			var extraOpts []s3storage.Option
+			if bucketCfg.Endpoint != "" {
+				extraOpts = append(extraOpts, s3storage.WithEndpoint(bucketCfg.Endpoint))
+			}
+			return storageFactory(awsCfg, bucketCfg.Name, extraOpts...)
+		}, func(bucket string) *s3storage.S3Storage {
+			// passthrough mode, bucket name captured by the routing pattern with the base AWS config
+			return storageFactory(baseCfg, bucket)
+		}),
 	}
-
-	for _, bucketCfg := range router.AllConfigs() {
-		awsCfg := createAWSConfig(baseCfg, bucketCfg)
-		var extraOpts []s3storage.Option
-		if bucketCfg.Endpoint != "" {
-			extraOpts = append(extraOpts, s3storage.WithEndpoint(bucketCfg.Endpoint))
-		}
-		l.loaders[bucketCfg.Name] = storageFactory(awsCfg, bucketCfg.Name, extraOpts...)
-	}
-
-	for _, fb := range router.Fallbacks() {
-		if loader, ok := l.loaders[fb.Name]; ok {
-			l.fallbacks = append(l.fallbacks, loader)
-		}
-	}
-
-	return l
 }
 
 func createAWSConfig(baseCfg aws.Config, bucketCfg *BucketConfig) aws.Config {
@@ -69,66 +62,87 @@ func createAWSConfig(baseCfg aws.Config, bucketCfg *BucketConfig) aws.Config {
 	return cfg
 }
 
-// getOrCreateDynamicLoader returns a cached or newly created S3Storage for the
-// given bucket name, using the base AWS config. This supports passthrough mode
-// where no rules or default_bucket are configured in the YAML — the bucket name
-// is extracted directly from the routing pattern's (?P<bucket>...) capture group.
-func (l *S3RouterLoader) getOrCreateDynamicLoader(bucket string) *s3storage.S3Storage {
-	if v, ok := l.dynamicLoaders.Load(bucket); ok {
-		return v.(*s3storage.S3Storage)
+// route returns the S3Storage and object key for the image.
+// Routing is based on the source image key from context if present,
+// so that result keys are routed along with their source image
+func (l *S3RouterLoader) route(ctx context.Context, image string) (*s3storage.S3Storage, string, error) {
+	// version selector is routed with the key, not matched by routing patterns
+	image, versionID := imagorpath.SplitVersion(image)
+	routeKey := image
+	key := l.router.KeyFor(image)
+	if source := imagor.SourceImageKeyFromContext(ctx); source != "" {
+		if routeKey, _ = imagorpath.SplitVersion(source); routeKey != image {
+			key = derivedKey(image, routeKey, l.router.KeyFor(routeKey))
+		}
+	}
+	if versionID != "" {
+		key += imagorpath.VersionSelector + versionID
+	}
+	storage, err := l.buckets.Route(routeKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return storage, key, nil
+}
+
+// derivedKey returns object key of image derived from source e.g. result key.
+// The prefix stripped from source by the routing pattern path group is stripped from image likewise,
+// or image is kept unchanged if it does not have the prefix,
+// so that keys derived from the same source are not collapsed into the same object key
+func derivedKey(image, source, sourceKey string) string {
+	source = strings.TrimPrefix(source, "/")
+	if sourceKey == source || !strings.HasSuffix(source, sourceKey) {
+		return image
 	}
-	loader := l.storageFactory(l.baseCfg, bucket)
-	actual, _ := l.dynamicLoaders.LoadOrStore(bucket, loader)
-	return actual.(*s3storage.S3Storage)
+	prefix := strings.TrimSuffix(source, sourceKey)
+	if key, ok := strings.CutPrefix(strings.TrimPrefix(image, "/"), prefix); ok && key != "" {
+		return key
+	}
+	return image
 }
 
+// Get implements imagor.Loader interface
 func (l *S3RouterLoader) Get(r *http.Request, image string) (*imagor.Blob, error) {
-	cfg := l.router.ConfigFor(image)
-	key := l.router.KeyFor(image)
+	loader, key, err := l.route(r.Context(), image)
+	if err != nil {
+		return nil, err
+	}
 
-	var loader *s3storage.S3Storage
+	return l.buckets.Get(r, loader, key)
+}
 
-	if cfg != nil {
-		var ok bool
-		loader, ok = l.loaders[cfg.Name]
-		if !ok {
-			return nil, imagor.ErrNotFound
-		}
-	} else {
-		// Passthrough mode: no matching rule and no default_bucket configured.
-		// Use the bucket name captured by the routing pattern directly.
-		// KeyFor returns the stripped path; we need the bucket from ConfigFor's
-		// pattern match. Extract it via the key difference or use a dedicated method.
-		// Since cfg is nil, the pattern didn't match OR there's no default.
-		// We use the bucket extracted from the image path via the pattern.
-		bucketName := l.router.BucketNameFor(image)
-		if bucketName == "" {
-			return nil, imagor.ErrNotFound
-		}
-		loader = l.getOrCreateDynamicLoader(bucketName)
+// Put implements imagor.Storage interface, writing to the routed bucket
+func (l *S3RouterLoader) Put(ctx context.Context, image string, blob *imagor.Blob) error {
+	storage, key, err := l.route(ctx, image)
+	if err != nil {
+		return err
 	}
+	return storage.Put(ctx, key, blob)
+}
 
-	blob, err := loader.Get(r, key)
-	if err == nil {
-		return blob, nil
+// Delete implements imagor.Storage interface, deleting from the routed bucket
+func (l *S3RouterLoader) Delete(ctx context.Context, image string) error {
+	storage, key, err := l.route(ctx, image)
+	if err != nil {
+		return err
 	}
+	return storage.Delete(ctx, key)
+}
 
-	if err != imagor.ErrNotFound {
+// Stat implements imagor.Storage interface for the routed bucket
+func (l *S3RouterLoader) Stat(ctx context.Context, image string) (*imagor.Stat, error) {
+	storage, key, err := l.route(ctx, image)
+	if err != nil {
 		return nil, err
 	}
+	return storage.Stat(ctx, key)
+}
 
-	for _, fb := range l.fallbacks {
-		if fb == loader {
-			continue
-		}
-		blob, err = fb.Get(r, key)
-		if err == nil {
-			return blob, nil
-		}
-		if err != imagor.ErrNotFound {
-			return nil, err
-		}
+// Presign implements imagor.Presigner interface for the routed bucket
+func (l *S3RouterLoader) Presign(ctx context.Context, image string, expires time.Duration) (string, error) {
+	storage, key, err := l.route(ctx, image)
+	if err != nil {
+		return "", err
 	}
-
-	return nil, imagor.ErrNotFound
+	return storage.Presign(ctx, key, expires)
 }
@@ -1,12 +1,21 @@
 package s3routerloader
 
 import (
+	"context"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/cshum/imagor"
 	"github.com/cshum/imagor/storage/s3storage"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -243,3 +252,188 @@ func TestS3RouterLoader_PerBucketCredentials(t *testing.T) {
 	assert.Equal(t, "global-key", creds2.AccessKeyID)
 	assert.Equal(t, "global-secret", creds2.SecretAccessKey)
 }
+
+func TestS3RouterLoader_Storage(t *testing.T) {
+	ts := httptest.NewServer(gofakes3.New(s3mem.New()).Server())
+	defer ts.Close()
+
+	ctx := context.Background()
+	cfg := aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("key", "secret", ""),
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(ts.URL)
+		o.UsePathStyle = true
+	})
+	for _, bucket := range []string{"tenant-a", "tenant-b", "default"} {
+		_, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+		require.NoError(t, err)
+	}
+	router, err := NewPatternRouter(
+		`^(?P<bucket>[a-z]+)/`,
+		[]MatchRule{
+			{Match: "a", Config: &BucketConfig{Name: "tenant-a"}},
+			{Match: "b", Config: &BucketConfig{Name: "tenant-b"}},
+		},
+		&BucketConfig{Name: "default"},
+		nil,
+	)
+	require.NoError(t, err)
+	s := New(cfg, router, func(cfg aws.Config, bucket string, extraOpts ...s3storage.Option) *s3storage.S3Storage {
+		return s3storage.New(cfg, bucket, append([]s3storage.Option{
+			s3storage.WithEndpoint(ts.URL), s3storage.WithForcePathStyle(true),
+		}, extraOpts...)...)
+	})
+	var _ imagor.Storage = s
+	var _ imagor.Presigner = s
+
+	exists := func(bucket, key string) bool {
+		_, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		return err == nil
+	}
+
+	require.NoError(t, s.Put(ctx, "a/foo.jpg", imagor.NewBlobFromBytes([]byte("a"))))
+	require.NoError(t, s.Put(ctx, "b/foo.jpg", imagor.NewBlobFromBytes([]byte("b"))))
+	assert.True(t, exists("tenant-a", "a/foo.jpg"))
+	assert.False(t, exists("tenant-b", "a/foo.jpg"))
+	assert.True(t, exists("tenant-b", "b/foo.jpg"))
+
+	stat, err := s.Stat(ctx, "a/foo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stat.Size)
+	_, err = s.Stat(ctx, "c/foo.jpg")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	blob, err := s.Get(httptest.NewRequest(http.MethodGet, "/", nil), "b/foo.jpg")
+	require.NoError(t, err)
+	buf, err := blob.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "b", string(buf))
+
+	// result key routed by source image key
+	resultCtx := imagor.ContextWithSourceImageKey(ctx, "b/foo.jpg")
+	require.NoError(t, s.Put(resultCtx, "fit-in/10x10/b/foo.jpg", imagor.NewBlobFromBytes([]byte("b"))))
+	assert.True(t, exists("tenant-b", "fit-in/10x10/b/foo.jpg"))
+	assert.False(t, exists("default", "fit-in/10x10/b/foo.jpg"))
+
+	u, err := s.Presign(resultCtx, "fit-in/10x10/b/foo.jpg", time.Minute)
+	require.NoError(t, err)
+	assert.Contains(t, u, "/tenant-b/fit-in/10x10/b/foo.jpg?")
+
+	require.NoError(t, s.Delete(resultCtx, "fit-in/10x10/b/foo.jpg"))
+	assert.False(t, exists("tenant-b", "fit-in/10x10/b/foo.jpg"))
+}
+
+func TestS3RouterLoader_StoragePathGroup(t *testing.T) {
+	ts := httptest.NewServer(gofakes3.New(s3mem.New()).Server())
+	defer ts.Close()
+
+	ctx := context.Background()
+	cfg := aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("key", "secret", ""),
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(ts.URL)
+		o.UsePathStyle = true
+	})
+	_, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("tenant")})
+	require.NoError(t, err)
+	router, err := NewPatternRouter(`^(?P<bucket>[^/]+)/(?P<path>.+)$`, nil, nil, nil)
+	require.NoError(t, err)
+	s := New(cfg, router, func(cfg aws.Config, bucket string, extraOpts ...s3storage.Option) *s3storage.S3Storage {
+		return s3storage.New(cfg, bucket, append([]s3storage.Option{
+			s3storage.WithEndpoint(ts.URL), s3storage.WithForcePathStyle(true),
+		}, extraOpts...)...)
+	})
+	exists := func(key string) bool {
+		_, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("tenant"), Key: aws.String(key)})
+		return err == nil
+	}
+	read := func(ctx context.Context, key string) string {
+		blob, err := s.Get(httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), key)
+		require.NoError(t, err)
+		buf, err := blob.ReadAll()
+		require.NoError(t, err)
+		return string(buf)
+	}
+
+	// source key stripped of the bucket path prefix
+	require.NoError(t, s.Put(ctx, "tenant/a.jpg", imagor.NewBlobFromBytes([]byte("source"))))
+	assert.True(t, exists("a.jpg"))
+	assert.Equal(t, "source", read(ctx, "tenant/a.jpg"))
+
+	// results of the same source kept apart
+	resultCtx := imagor.ContextWithSourceImageKey(ctx, "tenant/a.jpg")
+	require.NoError(t, s.Put(resultCtx, "200x200/tenant/a.jpg", imagor.NewBlobFromBytes([]byte("200"))))
+	require.NoError(t, s.Put(resultCtx, "300x300/tenant/a.jpg", imagor.NewBlobFromBytes([]byte("300"))))
+	assert.True(t, exists("200x200/tenant/a.jpg"))
+	assert.True(t, exists("300x300/tenant/a.jpg"))
+	assert.Equal(t, "200", read(resultCtx, "200x200/tenant/a.jpg"))
+	assert.Equal(t, "300", read(resultCtx, "300x300/tenant/a.jpg"))
+	assert.Equal(t, "source", read(ctx, "tenant/a.jpg"))
+
+	// results under the source prefix stripped likewise
+	require.NoError(t, s.Put(resultCtx, "tenant/a.200x200.jpg", imagor.NewBlobFromBytes([]byte("suffix"))))
+	assert.True(t, exists("a.200x200.jpg"))
+	assert.Equal(t, "suffix", read(resultCtx, "tenant/a.200x200.jpg"))
+}
+
+func TestS3RouterLoader_Versioning(t *testing.T) {
+	ts := httptest.NewServer(gofakes3.New(s3mem.New()).Server())
+	defer ts.Close()
+
+	ctx := context.Background()
+	cfg := aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("key", "secret", ""),
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(ts.URL)
+		o.UsePathStyle = true
+	})
+	_, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("tenant-a")})
+	require.NoError(t, err)
+	_, err = client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String("tenant-a"),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusEnabled,
+		},
+	})
+	require.NoError(t, err)
+	var versionIDs []string
+	for _, body := range []string{"v1", "v2"} {
+		out, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String("tenant-a"),
+			Key:    aws.String("foo.jpg"),
+			Body:   strings.NewReader(body),
+		})
+		require.NoError(t, err)
+		versionIDs = append(versionIDs, *out.VersionId)
+	}
+
+	router, err := NewPatternRouter(
+		`^(?P<bucket>[a-z]+)/(?P<path>.+)$`,
+		[]MatchRule{{Match: "a", Config: &BucketConfig{Name: "tenant-a"}}},
+		nil, nil,
+	)
+	require.NoError(t, err)
+	l := New(cfg, router, func(cfg aws.Config, bucket string, extraOpts ...s3storage.Option) *s3storage.S3Storage {
+		return s3storage.New(cfg, bucket, append([]s3storage.Option{
+			s3storage.WithEndpoint(ts.URL), s3storage.WithForcePathStyle(true), s3storage.WithVersioning(true),
+		}, extraOpts...)...)
+	})
+	read := func(image string) string {
+		blob, err := l.Get(httptest.NewRequest(http.MethodGet, "/", nil), image)
+		require.NoError(t, err)
+		buf, err := blob.ReadAll()
+		require.NoError(t, err)
+		return string(buf)
+	}
+	assert.Equal(t, "v2", read("a/foo.jpg"))
+	assert.Equal(t, "v1", read("a/foo.jpg?versionId="+versionIDs[0]))
+	stat, err := l.Stat(ctx, "a/foo.jpg?versionId="+versionIDs[0])
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stat.Size)
+}
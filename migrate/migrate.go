@@ -0,0 +1,222 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"golang.org/x/sync/errgroup"
+)
+
+// Status migration status of a blob
+type Status string
+
+const (
+	// StatusCopied blob copied, or to be copied in dry run
+	StatusCopied Status = "copied"
+	// StatusSkipped blob already exists in destination with the same size
+	StatusSkipped Status = "skipped"
+	// StatusFailed blob failed to copy
+	StatusFailed Status = "failed"
+)
+
+// Item migration result of a blob
+type Item struct {
+	Key    string
+	NewKey string
+	Size   int64
+	Status Status
+	Err    error
+}
+
+// Report migration report
+type Report struct {
+	Listed  int
+	Copied  int
+	Skipped int
+	Failed  int
+	// Bytes total size of blobs copied
+	Bytes int64
+}
+
+// Migrator copies every blob listed from storage From into storage To,
+// optionally rewriting keys. Blobs already in To with the same size are skipped,
+// so that an interrupted migration can be resumed by running it again
+type Migrator struct {
+	From imagor.Storage
+	To   imagor.Storage
+	// Rewrite maps key of From into key of To. Keys are kept as is if nil
+	Rewrite     func(key string) (string, error)
+	Concurrency int
+	// DryRun reports blobs to be copied without copying them
+	DryRun bool
+	// Overwrite copies blobs even if they exist in To with the same size
+	Overwrite bool
+	// Progress is called with the result of each blob, one at a time
+	Progress func(item Item)
+}
+
+// New creates Migrator copying blobs from storage into another
+func New(from, to imagor.Storage, options ...Option) *Migrator {
+	m := &Migrator{
+		From:        from,
+		To:          to,
+		Concurrency: 8,
+	}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+// Run runs the migration until all blobs listed are processed.
+// Blobs failed to copy are reported without stopping the migration,
+// error is returned only if listing failed or ctx is done
+func (m *Migrator) Run(ctx context.Context) (report Report, err error) {
+	lister, ok := m.From.(imagor.Lister)
+	if !ok {
+		return report, fmt.Errorf("migrate: %T does not support listing", m.From)
+	}
+	list := lister.List
+	if m.sameStorage() {
+		// collect keys before copying, so that blobs written are not listed again
+		type entry struct {
+			key  string
+			stat *imagor.Stat
+		}
+		var entries []entry
+		if err = lister.List(ctx, func(key string, stat *imagor.Stat) error {
+			entries = append(entries, entry{key, stat})
+			return nil
+		}); err != nil {
+			return
+		}
+		list = func(ctx context.Context, fn func(key string, stat *imagor.Stat) error) error {
+			for _, e := range entries {
+				if err := fn(e.key, e.stat); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	var mu sync.Mutex
+	var g errgroup.Group
+	g.SetLimit(max(m.Concurrency, 1))
+	err = list(ctx, func(key string, stat *imagor.Stat) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		mu.Lock()
+		report.Listed++
+		mu.Unlock()
+		g.Go(func() error {
+			item := m.migrate(ctx, key, stat)
+			mu.Lock()
+			defer mu.Unlock()
+			switch item.Status {
+			case StatusCopied:
+				report.Copied++
+				report.Bytes += item.Size
+			case StatusSkipped:
+				report.Skipped++
+			case StatusFailed:
+				report.Failed++
+			}
+			if m.Progress != nil {
+				m.Progress(item)
+			}
+			return nil
+		})
+		return nil
+	})
+	_ = g.Wait()
+	if err == nil {
+		err = ctx.Err()
+	}
+	return
+}
+
+func (m *Migrator) migrate(ctx context.Context, key string, stat *imagor.Stat) (item Item) {
+	item = Item{Key: key, NewKey: key}
+	if stat != nil {
+		item.Size = stat.Size
+	}
+	fail := func(err error) Item {
+		item.Status = StatusFailed
+		item.Err = err
+		return item
+	}
+	if m.Rewrite != nil {
+		newKey, err := m.Rewrite(key)
+		if err != nil {
+			return fail(err)
+		}
+		item.NewKey = newKey
+	}
+	if item.NewKey == key && m.sameStorage() {
+		item.Status = StatusSkipped
+		return
+	}
+	if !m.Overwrite {
+		if dst, err := m.To.Stat(ctx, item.NewKey); err == nil && dst != nil &&
+			stat != nil && dst.Size == stat.Size {
+			item.Status = StatusSkipped
+			return
+		}
+	}
+	if m.DryRun {
+		item.Status = StatusCopied
+		return
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "", nil)
+	if err != nil {
+		return fail(err)
+	}
+	blob, err := m.From.Get(r, key)
+	if blob == nil && err == nil {
+		err = imagor.ErrNotFound
+	} else if err == nil {
+		err = blob.Err()
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if err = m.To.Put(ctx, item.NewKey, blob); err != nil {
+		return fail(err)
+	}
+	item.Status = StatusCopied
+	return
+}
+
+// sameStorage checks if migrating within the same storage, e.g. rewriting keys
+func (m *Migrator) sameStorage() bool {
+	if m.From == nil || m.To == nil || !reflect.TypeOf(m.From).Comparable() {
+		return false
+	}
+	return m.From == m.To
+}
+
+// StorageKeys rewrites storage keys of original path style with hasher
+func StorageKeys(hasher imagorpath.StorageHasher) func(key string) (string, error) {
+	return func(key string) (string, error) {
+		return hasher.Hash(key), nil
+	}
+}
+
+// ResultStorageKeys rewrites result storage keys of original path style with hasher,
+// parsing keys as imagor paths
+func ResultStorageKeys(hasher imagorpath.ResultStorageHasher) func(key string) (string, error) {
+	return func(key string) (string, error) {
+		// unsafe prefix so that a long first path segment is not taken as signature
+		p := imagorpath.Parse("unsafe/" + key)
+		if p.Image == "" {
+			return "", fmt.Errorf("migrate: invalid result key %q", key)
+		}
+		return hasher.HashResult(p), nil
+	}
+}
@@ -0,0 +1,153 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/cshum/imagor/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+var keys = []string{"abc.gif", "foo/bar.jpg", "foo/baz/qux.png"}
+
+func source(t *testing.T) *filestorage.FileStorage {
+	s := filestorage.New(t.TempDir())
+	for _, key := range keys {
+		require.NoError(t, s.Put(ctx, key, imagor.NewBlobFromBytes([]byte(key))))
+	}
+	return s
+}
+
+func TestMigrate(t *testing.T) {
+	from := source(t)
+	to := filestorage.New(t.TempDir())
+	r := (&http.Request{}).WithContext(ctx)
+
+	report, err := New(from, to, WithDryRun(true)).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Listed: 3, Copied: 3, Bytes: 33}, report)
+	_, err = to.Stat(ctx, "abc.gif")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	var items []Item
+	report, err = New(from, to, WithConcurrency(2), WithProgress(func(item Item) {
+		items = append(items, item)
+	})).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Listed: 3, Copied: 3, Bytes: 33}, report)
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	assert.Equal(t, Item{Key: "abc.gif", NewKey: "abc.gif", Size: 7, Status: StatusCopied}, items[0])
+	for _, key := range keys {
+		b, err := to.Get(r, key)
+		require.NoError(t, err)
+		buf, err := b.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, key, string(buf))
+	}
+
+	// resumed migration skips copied blobs
+	require.NoError(t, to.Delete(ctx, "abc.gif"))
+	require.NoError(t, to.Put(ctx, "foo/bar.jpg", imagor.NewBlobFromBytes([]byte("partial"))))
+	report, err = New(from, to).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Listed: 3, Copied: 2, Skipped: 1, Bytes: 18}, report)
+	b, err := to.Get(r, "foo/bar.jpg")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "foo/bar.jpg", string(buf))
+
+	report, err = New(from, to, WithOverwrite(true)).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Copied)
+}
+
+func TestMigrateRewrite(t *testing.T) {
+	from := source(t)
+	to := filestorage.New(t.TempDir())
+	r := (&http.Request{}).WithContext(ctx)
+	report, err := New(from, to, WithRewrite(StorageKeys(imagorpath.DigestStorageHasher))).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Copied)
+	for _, key := range keys {
+		b, err := to.Get(r, imagorpath.DigestStorageHasher.Hash(key))
+		require.NoError(t, err)
+		buf, err := b.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, key, string(buf))
+	}
+
+	// rewrite within the same storage
+	report, err = New(from, from, WithRewrite(StorageKeys(imagorpath.DigestStorageHasher))).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Listed: 3, Copied: 3, Bytes: 33}, report)
+	for _, key := range keys {
+		for _, k := range []string{imagorpath.DigestStorageHasher.Hash(key), key} {
+			b, err := from.Get(r, k)
+			require.NoError(t, err)
+			buf, err := b.ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, key, string(buf))
+		}
+	}
+	report, err = New(from, from).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 6, report.Skipped)
+}
+
+func TestMigrateFailures(t *testing.T) {
+	from := source(t)
+	to := filestorage.New(t.TempDir())
+	errRewrite := errors.New("rewrite")
+	var failed []Item
+	report, err := New(from, to, WithRewrite(func(key string) (string, error) {
+		switch key {
+		case "abc.gif":
+			return "", errRewrite
+		case "foo/bar.jpg":
+			// dot file rejected by file storage
+			return ".bar.jpg", nil
+		}
+		return key, nil
+	}), WithProgress(func(item Item) {
+		if item.Status == StatusFailed {
+			failed = append(failed, item)
+		}
+	})).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Listed: 3, Copied: 1, Failed: 2, Bytes: 15}, report)
+	require.Len(t, failed, 2)
+
+	_, err = New(struct{ imagor.Storage }{from}, to).Run(ctx)
+	assert.Error(t, err)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = New(from, to).Run(cancelled)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestResultStorageKeys(t *testing.T) {
+	rewrite := ResultStorageKeys(imagorpath.SuffixResultStorageHasher)
+	key, err := rewrite("fit-in/100x100/filters:format(webp)/foo/bar.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, imagorpath.SuffixResultStorageHasher.HashResult(
+		imagorpath.Parse("fit-in/100x100/filters:format(webp)/foo/bar.jpg")), key)
+	assert.Regexp(t, `^foo/bar\.[0-9a-f]{20}\.webp$`, key)
+
+	// long first path segment not taken as signature
+	key, err = ResultStorageKeys(imagorpath.DigestResultStorageHasher)("averyverylongdirectory/bar.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, imagorpath.DigestStorageHasher.Hash("averyverylongdirectory/bar.jpg"), key)
+
+	_, err = rewrite("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,41 @@
+package migrate
+
+// Option Migrator option
+type Option func(m *Migrator)
+
+// WithRewrite with key rewrite option, mapping key of source storage into key of destination
+func WithRewrite(rewrite func(key string) (string, error)) Option {
+	return func(m *Migrator) {
+		m.Rewrite = rewrite
+	}
+}
+
+// WithConcurrency with number of blobs copied concurrently option
+func WithConcurrency(concurrency int) Option {
+	return func(m *Migrator) {
+		if concurrency > 0 {
+			m.Concurrency = concurrency
+		}
+	}
+}
+
+// WithDryRun with dry run option, reporting blobs to be copied without copying them
+func WithDryRun(dryRun bool) Option {
+	return func(m *Migrator) {
+		m.DryRun = dryRun
+	}
+}
+
+// WithOverwrite with overwrite option, copying blobs that exist in destination with the same size
+func WithOverwrite(overwrite bool) Option {
+	return func(m *Migrator) {
+		m.Overwrite = overwrite
+	}
+}
+
+// WithProgress with callback option called with the result of each blob
+func WithProgress(progress func(item Item)) Option {
+	return func(m *Migrator) {
+		m.Progress = progress
+	}
+}
@@ -52,6 +52,40 @@ func WithResultStorages(savers ...Storage) Option {
 	}
 }
 
+// WithResultCache with in-process result cache option, consulted before result storages
+func WithResultCache(cache ResultCache) Option {
+	return func(app *Imagor) {
+		app.ResultCache = cache
+	}
+}
+
+// WithResultAdmission with write-admission policy option of results into result storages
+func WithResultAdmission(admission ResultAdmission) Option {
+	return func(app *Imagor) {
+		app.ResultAdmission = admission
+	}
+}
+
+// WithResultRedirect with result redirect option, answering result storage hit
+// with a redirect to the public or presigned URL of the result.
+// Status code can be http.StatusFound or http.StatusTemporaryRedirect
+func WithResultRedirect(statusCode int) Option {
+	return func(app *Imagor) {
+		if statusCode == http.StatusFound || statusCode == http.StatusTemporaryRedirect {
+			app.ResultRedirect = statusCode
+		}
+	}
+}
+
+// WithResultRedirectExpires with presigned URL expiry of result redirect option
+func WithResultRedirectExpires(expires time.Duration) Option {
+	return func(app *Imagor) {
+		if expires > 0 {
+			app.ResultRedirectExpires = expires
+		}
+	}
+}
+
 // WithProcessors with processor option
 func WithProcessors(processors ...Processor) Option {
 	return func(app *Imagor) {
@@ -203,6 +237,21 @@ func WithDisableParamsEndpoint(disabled bool) Option {
 	}
 }
 
+// WithPresets with named presets of imagor params option, e.g. "thumb": "fit-in/200x200/filters:format(webp)"
+func WithPresets(presets map[string]string) Option {
+	return func(app *Imagor) {
+		if len(presets) == 0 {
+			return
+		}
+		if app.Presets == nil {
+			app.Presets = map[string]string{}
+		}
+		for name, params := range presets {
+			app.Presets[name] = params
+		}
+	}
+}
+
 // WithDebug with debug option
 func WithDebug(debug bool) Option {
 	return func(app *Imagor) {
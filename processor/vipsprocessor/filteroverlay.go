@@ -143,7 +143,7 @@ func (v *Processor) fill(ctx context.Context, img *vips.Image, w, h int, pLeft,
 	top := (h-img.PageHeight())/2 + pTop
 	width := w + pLeft + pRight
 	height := h + pTop + pBottom
-	if colour != "blur" || v.DisableBlur || isAnimated(img) {
+	if colour != "blur" || v.isFilterDisabled("blur") || isAnimated(img) {
 		// fill color
 		isTransparent := colour == "none" || colour == "transparent"
 		if img.HasAlpha() && !isTransparent {
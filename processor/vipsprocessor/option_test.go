@@ -9,6 +9,7 @@ import (
 	"github.com/cshum/imagor"
 	"github.com/cshum/vipsgen/vips"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestWithOption(t *testing.T) {
@@ -94,3 +95,35 @@ func TestWithOption(t *testing.T) {
 		assert.Equal(t, 400, v2.DetectorProbeSize) // unchanged default
 	})
 }
+
+func TestReload(t *testing.T) {
+	// corner of white image filled with blur, black if blur is disabled
+	fillBlur := func(v *Processor) float64 {
+		ctx := withContext(context.Background())
+		defer contextDone(ctx)
+		img, err := vips.NewBlack(10, 10, &vips.BlackOptions{Bands: 3})
+		require.NoError(t, err)
+		defer img.Close()
+		require.NoError(t, img.Linear([]float64{1}, []float64{255}, &vips.LinearOptions{Uchar: true}))
+		require.NoError(t, v.fill(ctx, img, 30, 30, 0, 0, 0, 0, "blur"))
+		point, err := img.Getpoint(0, 0, nil)
+		require.NoError(t, err)
+		return point[0]
+	}
+
+	v := NewProcessor(WithDisableFilters("rgb"))
+	assert.True(t, v.isFilterDisabled("rgb"))
+	assert.False(t, v.isFilterDisabled("blur"))
+	assert.Greater(t, fillBlur(v), 0.0)
+
+	assert.NoError(t, v.Reload(NewProcessor(WithDisableBlur(true))))
+	assert.False(t, v.isFilterDisabled("rgb"))
+	assert.True(t, v.isFilterDisabled("blur"))
+	assert.True(t, v.isFilterDisabled("sharpen"))
+	assert.Equal(t, 0.0, fillBlur(v), "fill blur disabled on reload")
+	assert.Equal(t, []string{"rgb"}, v.DisableFilters, "exported fields keep initial values")
+
+	assert.Error(t, v.Reload("foo"))
+	assert.Error(t, v.Reload((*Processor)(nil)))
+	assert.True(t, v.isFilterDisabled("blur"))
+}
@@ -199,7 +199,7 @@ func (v *Processor) extractExportParams(p imagorpath.Params, blob *imagor.Blob,
 	)
 
 	for _, f := range p.Filters {
-		if v.disableFilters[f.Name] {
+		if v.isFilterDisabled(f.Name) {
 			continue
 		}
 		switch f.Name {
@@ -351,7 +351,7 @@ func (v *Processor) loadAndProcess(
 		}
 	}
 	for _, f := range p.Filters {
-		if v.disableFilters[f.Name] {
+		if v.isFilterDisabled(f.Name) {
 			continue
 		}
 		switch f.Name {
@@ -535,7 +535,7 @@ func (v *Processor) loadAndProcess(
 
 	var focalRects []focal
 	for _, f := range p.Filters {
-		if v.disableFilters[f.Name] {
+		if v.isFilterDisabled(f.Name) {
 			continue
 		}
 		if f.Name == "focal" {
@@ -762,7 +762,7 @@ func (v *Processor) applyTransformations(
 		if err := ctx.Err(); err != nil {
 			return err
 		}
-		if v.disableFilters[filter.Name] {
+		if v.isFilterDisabled(filter.Name) {
 			continue
 		}
 		if v.MaxFilterOps > 0 && i >= v.MaxFilterOps {
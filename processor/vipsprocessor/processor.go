@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cshum/imagor"
@@ -58,7 +59,7 @@ type Processor struct {
 	CacheTTL       time.Duration
 	CacheFormat    imagor.BlobType // BlobTypeMemory (default, raw pixels), BlobTypeWEBP, BlobTypePNG
 
-	disableFilters map[string]bool
+	disableFilters atomic.Pointer[map[string]bool]
 	cache          *imageCache
 	cacheSF        singleflight.Group
 	hasDcrawload   bool
@@ -75,7 +76,6 @@ func NewProcessor(options ...Option) *Processor {
 		MaxAnimationFrames: -1,
 		AvifSpeed:          8,
 		Logger:             zap.NewNop(),
-		disableFilters:     map[string]bool{},
 		CacheMaxWidth:      2400,
 		CacheMaxHeight:     2000,
 		DetectorProbeSize:  400,
@@ -116,15 +116,39 @@ func NewProcessor(options ...Option) *Processor {
 	if v.DisableBlur {
 		v.DisableFilters = append(v.DisableFilters, "blur", "sharpen")
 	}
+	disableFilters := map[string]bool{}
 	for _, name := range v.DisableFilters {
-		v.disableFilters[name] = true
+		disableFilters[name] = true
 	}
+	v.disableFilters.Store(&disableFilters)
 	if v.Concurrency == -1 {
 		v.Concurrency = runtime.NumCPU()
 	}
 	return v
 }
 
+// Reload implements imagor.Reloader interface,
+// swapping disabled filters of next Processor atomically, including blur of DisableBlur.
+// Exported fields keep their initial values
+func (v *Processor) Reload(next any) error {
+	n, ok := next.(*Processor)
+	if !ok || n == nil {
+		return fmt.Errorf("vipsprocessor: cannot reload from %T", next)
+	}
+	if disableFilters := n.disableFilters.Load(); disableFilters != nil {
+		v.disableFilters.Store(disableFilters)
+	}
+	return nil
+}
+
+// isFilterDisabled checks if filter is disabled
+func (v *Processor) isFilterDisabled(name string) bool {
+	if disableFilters := v.disableFilters.Load(); disableFilters != nil {
+		return (*disableFilters)[name]
+	}
+	return false
+}
+
 // Startup implements imagor.Processor interface
 func (v *Processor) Startup(ctx context.Context) error {
 	processorLock.Lock()
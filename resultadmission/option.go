@@ -0,0 +1,51 @@
+package resultadmission
+
+import "time"
+
+// Option ResultAdmission option
+type Option func(a *ResultAdmission)
+
+// WithMinHits with minimum number of requests of a result within window option
+func WithMinHits(hits int) Option {
+	return func(a *ResultAdmission) {
+		if hits >= 0 {
+			a.MinHits = hits
+		}
+	}
+}
+
+// WithWindow with window of counting requests option
+func WithWindow(window time.Duration) Option {
+	return func(a *ResultAdmission) {
+		if window > 0 {
+			a.Window = window
+		}
+	}
+}
+
+// WithMinProcessTime with minimum duration of loading and processing option
+func WithMinProcessTime(d time.Duration) Option {
+	return func(a *ResultAdmission) {
+		if d >= 0 {
+			a.MinProcessTime = d
+		}
+	}
+}
+
+// WithMinSourceSize with minimum size of source image in bytes option
+func WithMinSourceSize(size int64) Option {
+	return func(a *ResultAdmission) {
+		if size >= 0 {
+			a.MinSourceSize = size
+		}
+	}
+}
+
+// WithWidth with number of counters of each row of the count-min sketch option
+func WithWidth(width int) Option {
+	return func(a *ResultAdmission) {
+		if width > 0 {
+			a.Width = width
+		}
+	}
+}
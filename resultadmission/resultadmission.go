@@ -0,0 +1,79 @@
+package resultadmission
+
+import (
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// ResultAdmission result write-admission policy implements imagor.ResultAdmission interface.
+// A result is admitted into result storages if any of the enabled criteria is met:
+// requested at least MinHits times within Window, processed longer than MinProcessTime,
+// or with source image larger than MinSourceSize. All results are admitted if none is enabled
+type ResultAdmission struct {
+	// MinHits minimum number of requests of a result within Window. Set 0 to disable
+	MinHits int
+	// Window of counting requests. Counts are approximate,
+	// covering requests from the last half to the full Window
+	Window time.Duration
+	// MinProcessTime minimum duration of loading and processing. Set 0 to disable
+	MinProcessTime time.Duration
+	// MinSourceSize minimum size of source image in bytes. Set 0 to disable
+	MinSourceSize int64
+	// Width number of counters of each row of the count-min sketch
+	Width int
+
+	mu       sync.Mutex
+	current  *sketch
+	previous *sketch
+	rotated  time.Time
+	now      func() time.Time
+}
+
+// New creates ResultAdmission
+func New(options ...Option) *ResultAdmission {
+	a := &ResultAdmission{
+		Window: time.Hour,
+		Width:  1 << 16,
+		now:    time.Now,
+	}
+	for _, option := range options {
+		option(a)
+	}
+	seed := maphash.MakeSeed()
+	a.current = newSketch(seed, a.Width)
+	a.previous = newSketch(seed, a.Width)
+	a.rotated = a.now()
+	return a
+}
+
+// Admit implements imagor.ResultAdmission interface, counting the request of key
+func (a *ResultAdmission) Admit(key string, elapsed time.Duration, sourceSize int64) bool {
+	if a.MinHits <= 0 && a.MinProcessTime <= 0 && a.MinSourceSize <= 0 {
+		return true
+	}
+	admitted := (a.MinProcessTime > 0 && elapsed >= a.MinProcessTime) ||
+		(a.MinSourceSize > 0 && sourceSize >= a.MinSourceSize)
+	if a.MinHits > 0 {
+		if hits := a.count(key); hits >= uint64(a.MinHits) {
+			admitted = true
+		}
+	}
+	return admitted
+}
+
+// count counts request of key, returning the estimated number of requests within window
+func (a *ResultAdmission) count(key string) uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if now := a.now(); now.Sub(a.rotated) >= a.Window/2 {
+		// counts older than a full window are dropped
+		a.previous, a.current = a.current, a.previous
+		a.current.reset()
+		if now.Sub(a.rotated) >= a.Window {
+			a.previous.reset()
+		}
+		a.rotated = now
+	}
+	return uint64(a.current.add(key)) + uint64(a.previous.estimate(key))
+}
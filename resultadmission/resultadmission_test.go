@@ -0,0 +1,68 @@
+package resultadmission
+
+import (
+	"hash/maphash"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmitAll(t *testing.T) {
+	a := New()
+	assert.True(t, a.Admit("foo", 0, -1))
+}
+
+func TestMinHits(t *testing.T) {
+	now := time.Now()
+	a := New(WithMinHits(3), WithWindow(time.Hour))
+	a.now = func() time.Time { return now }
+
+	assert.False(t, a.Admit("foo", 0, -1))
+	assert.False(t, a.Admit("foo", 0, -1))
+	assert.False(t, a.Admit("bar", 0, -1))
+	assert.True(t, a.Admit("foo", 0, -1))
+	assert.True(t, a.Admit("foo", 0, -1))
+
+	// counts carried over half window
+	now = now.Add(time.Minute * 31)
+	assert.False(t, a.Admit("bar", 0, -1))
+	assert.True(t, a.Admit("bar", 0, -1))
+
+	// counts dropped after full window
+	now = now.Add(time.Minute * 31)
+	assert.False(t, a.Admit("foo", 0, -1))
+	now = now.Add(time.Hour)
+	assert.False(t, a.Admit("bar", 0, -1))
+	assert.False(t, a.Admit("bar", 0, -1))
+	assert.True(t, a.Admit("bar", 0, -1))
+}
+
+func TestMinProcessTimeAndSourceSize(t *testing.T) {
+	a := New(WithMinProcessTime(time.Second), WithMinSourceSize(1000))
+	assert.False(t, a.Admit("foo", time.Millisecond, 999))
+	assert.False(t, a.Admit("foo", time.Millisecond, -1))
+	assert.True(t, a.Admit("foo", time.Second, 999))
+	assert.True(t, a.Admit("foo", time.Millisecond, 1000))
+
+	// any of the criteria
+	a = New(WithMinHits(2), WithMinProcessTime(time.Second))
+	assert.True(t, a.Admit("foo", time.Second*2, -1))
+	assert.True(t, a.Admit("foo", 0, -1))
+	assert.False(t, a.Admit("bar", 0, -1))
+}
+
+func TestSketch(t *testing.T) {
+	s := newSketch(maphash.MakeSeed(), 1<<16)
+	for i := 0; i < 10000; i++ {
+		s.add(strconv.Itoa(i % 500))
+	}
+	for i := 0; i < 500; i++ {
+		// never underestimates
+		assert.GreaterOrEqual(t, s.estimate(strconv.Itoa(i)), uint32(20))
+	}
+	assert.Less(t, s.estimate("missing"), uint32(20))
+	s.reset()
+	assert.Equal(t, uint32(0), s.estimate("0"))
+}
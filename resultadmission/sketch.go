@@ -0,0 +1,67 @@
+package resultadmission
+
+import (
+	"hash/maphash"
+)
+
+const sketchDepth = 4
+
+// sketch count-min sketch with conservative update,
+// estimating key counts within fixed memory, never underestimating
+type sketch struct {
+	seed     maphash.Seed
+	width    uint64
+	counters [sketchDepth][]uint32
+}
+
+func newSketch(seed maphash.Seed, width int) *sketch {
+	s := &sketch{seed: seed, width: uint64(width)}
+	for i := range s.counters {
+		s.counters[i] = make([]uint32, width)
+	}
+	return s
+}
+
+// indexes of key in each row, derived from two halves of the hash
+func (s *sketch) indexes(key string) (idx [sketchDepth]uint64) {
+	h := maphash.String(s.seed, key)
+	h1, h2 := h&0xffffffff, h>>32|1
+	for i := range idx {
+		idx[i] = (h1 + uint64(i)*h2) % s.width
+	}
+	return
+}
+
+// add increments count of key, returning the estimated count
+func (s *sketch) add(key string) uint32 {
+	idx := s.indexes(key)
+	est := s.estimateAt(idx)
+	if est == ^uint32(0) {
+		return est
+	}
+	est++
+	for i, j := range idx {
+		if s.counters[i][j] < est {
+			s.counters[i][j] = est
+		}
+	}
+	return est
+}
+
+func (s *sketch) estimate(key string) uint32 {
+	return s.estimateAt(s.indexes(key))
+}
+
+func (s *sketch) estimateAt(idx [sketchDepth]uint64) uint32 {
+	est := ^uint32(0)
+	for i, j := range idx {
+		est = min(est, s.counters[i][j])
+	}
+	return est
+}
+
+func (s *sketch) reset() {
+	for i := range s.counters {
+		clear(s.counters[i])
+	}
+}
@@ -0,0 +1,33 @@
+package resultcache
+
+import "time"
+
+// Option ResultCache option
+type Option func(c *ResultCache)
+
+// WithMaxSize with cache byte budget option
+func WithMaxSize(size int64) Option {
+	return func(c *ResultCache) {
+		if size > 0 {
+			c.MaxSize = size
+		}
+	}
+}
+
+// WithMaxItemSize with maximum size in bytes of a cached result option
+func WithMaxItemSize(size int64) Option {
+	return func(c *ResultCache) {
+		if size > 0 {
+			c.MaxItemSize = size
+		}
+	}
+}
+
+// WithTTL with cache TTL option
+func WithTTL(ttl time.Duration) Option {
+	return func(c *ResultCache) {
+		if ttl > 0 {
+			c.TTL = ttl
+		}
+	}
+}
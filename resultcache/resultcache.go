@@ -0,0 +1,113 @@
+package resultcache
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/dgraph-io/ristretto/v2"
+)
+
+type entry struct {
+	buf         []byte
+	contentType string
+	header      http.Header
+	stat        imagor.Stat
+}
+
+// ResultCache in-process result cache implements imagor.ResultCache interface,
+// holding small result blobs in memory within a byte budget
+type ResultCache struct {
+	MaxSize     int64
+	MaxItemSize int64
+	TTL         time.Duration
+
+	cache *ristretto.Cache[string, *entry]
+}
+
+// New creates ResultCache
+func New(options ...Option) (*ResultCache, error) {
+	c := &ResultCache{
+		MaxSize:     64 << 20,
+		MaxItemSize: 1 << 20,
+		TTL:         time.Hour,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	var err error
+	c.cache, err = ristretto.NewCache[string, *entry](&ristretto.Config[string, *entry]{
+		NumCounters: 100000,
+		MaxCost:     c.MaxSize,
+		BufferItems: 64,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Get implements imagor.ResultCache interface
+func (c *ResultCache) Get(key string) (*imagor.Blob, bool) {
+	e, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return e.blob(), true
+}
+
+// Set implements imagor.ResultCache interface.
+// Blobs of unknown size or larger than MaxItemSize are not cached
+func (c *ResultCache) Set(key string, blob *imagor.Blob) (*imagor.Blob, bool) {
+	if key == "" || blob == nil {
+		return nil, false
+	}
+	size := blob.Size()
+	if size <= 0 || size > c.MaxItemSize {
+		return nil, false
+	}
+	buf, err := blob.ReadAll()
+	if err != nil || len(buf) == 0 {
+		return nil, false
+	}
+	e := &entry{
+		buf:         buf,
+		contentType: blob.ContentType(),
+		stat: imagor.Stat{
+			ModifiedTime: time.Now(),
+			Size:         int64(len(buf)),
+		},
+	}
+	if blob.Header != nil {
+		e.header = blob.Header.Clone()
+	}
+	if blob.Stat != nil {
+		e.stat.ETag = blob.Stat.ETag
+		if !blob.Stat.ModifiedTime.IsZero() {
+			e.stat.ModifiedTime = blob.Stat.ModifiedTime
+		}
+	}
+	c.cache.SetWithTTL(key, e, int64(len(buf)), c.TTL)
+	return e.blob(), true
+}
+
+// Delete implements imagor.ResultCache interface
+func (c *ResultCache) Delete(key string) {
+	c.cache.Del(key)
+}
+
+// Wait waits for pending cache writes to be applied
+func (c *ResultCache) Wait() {
+	c.cache.Wait()
+}
+
+func (e *entry) blob() *imagor.Blob {
+	blob := imagor.NewBlobFromBytes(e.buf)
+	blob.SetContentType(e.contentType)
+	if e.header != nil {
+		blob.Header = e.header.Clone()
+	}
+	stat := e.stat
+	blob.Stat = &stat
+	return blob
+}
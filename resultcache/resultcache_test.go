@@ -0,0 +1,71 @@
+package resultcache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultCache(t *testing.T) {
+	c, err := New(WithMaxSize(1024), WithMaxItemSize(4), WithTTL(time.Minute))
+	require.NoError(t, err)
+
+	_, ok := c.Get("foo")
+	assert.False(t, ok)
+
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	blob := imagor.NewBlobFromBytes([]byte("bar"))
+	blob.SetContentType("text/plain")
+	blob.Header = http.Header{"Vary": []string{"Accept"}}
+	blob.Stat = &imagor.Stat{ModifiedTime: mtime, ETag: "abc"}
+	cached, ok := c.Set("foo", blob)
+	require.True(t, ok)
+	buf, err := cached.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(buf))
+	c.Wait()
+
+	for i := 0; i < 2; i++ {
+		b, ok := c.Get("foo")
+		require.True(t, ok)
+		buf, err := b.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, "bar", string(buf))
+		assert.Equal(t, "text/plain", b.ContentType())
+		assert.Equal(t, "Accept", b.Header.Get("Vary"))
+		assert.Equal(t, mtime, b.Stat.ModifiedTime)
+		assert.Equal(t, "abc", b.Stat.ETag)
+		assert.Equal(t, int64(3), b.Stat.Size)
+	}
+
+	// larger than MaxItemSize
+	_, ok = c.Set("large", imagor.NewBlobFromBytes([]byte("barbar")))
+	assert.False(t, ok)
+	_, ok = c.Set("empty", imagor.NewEmptyBlob())
+	assert.False(t, ok)
+	c.Wait()
+	_, ok = c.Get("large")
+	assert.False(t, ok)
+
+	c.Delete("foo")
+	_, ok = c.Get("foo")
+	assert.False(t, ok)
+}
+
+func TestResultCacheTTL(t *testing.T) {
+	c, err := New(WithTTL(time.Millisecond * 50))
+	require.NoError(t, err)
+	_, ok := c.Set("foo", imagor.NewBlobFromBytes([]byte("bar")))
+	require.True(t, ok)
+	c.Wait()
+	b, ok := c.Get("foo")
+	require.True(t, ok)
+	assert.False(t, b.Stat.ModifiedTime.IsZero())
+	time.Sleep(time.Millisecond * 100)
+	_, ok = c.Get("foo")
+	assert.False(t, ok)
+}
@@ -122,3 +122,12 @@ func WithMetrics(metrics Metrics) Option {
 		s.Metrics = metrics
 	}
 }
+
+// WithReload with hot reload option, reloading on SIGHUP signal,
+// and every interval if positive, e.g. for polling configuration changes
+func WithReload(reload func() error, interval time.Duration) Option {
+	return func(s *Server) {
+		s.Reload = reload
+		s.ReloadInterval = interval
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"net/http"
+	"os"
 	"os/signal"
 	"reflect"
 	"strconv"
@@ -51,6 +52,9 @@ type Server struct {
 	Logger          *zap.Logger
 	Debug           bool
 	Metrics         Metrics
+	// Reload hot reloads configuration while running, on SIGHUP and every ReloadInterval
+	Reload         func() error
+	ReloadInterval time.Duration
 }
 
 // New create new Server
@@ -113,6 +117,10 @@ func (s *Server) RunContext(ctx context.Context) {
 	}()
 	s.Logger.Info("listen", zap.String("addr", s.Addr))
 
+	if s.Reload != nil {
+		go s.reloadLoop(ctx)
+	}
+
 	if !isNil(s.Metrics) {
 		if err := s.Metrics.Startup(ctx); err != nil {
 			s.Logger.Fatal("metrics-startup", zap.Error(err))
@@ -124,6 +132,30 @@ func (s *Server) RunContext(ctx context.Context) {
 	s.shutdown(context.Background())
 }
 
+// reloadLoop calls Reload on SIGHUP and every ReloadInterval until ctx done
+func (s *Server) reloadLoop(ctx context.Context) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	defer signal.Stop(sig)
+	var tick <-chan time.Time
+	if s.ReloadInterval > 0 {
+		ticker := time.NewTicker(s.ReloadInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sig:
+		case <-tick:
+		}
+		if err := s.Reload(); err != nil {
+			s.Logger.Error("reload", zap.Error(err))
+		}
+	}
+}
+
 func isNil(c any) bool {
 	return c == nil || (reflect.ValueOf(c).Kind() == reflect.Ptr && reflect.ValueOf(c).IsNil())
 }
@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
@@ -158,6 +159,43 @@ func TestServer_RunSignal(t *testing.T) {
 	assert.Equal(t, 1, processor.ShutdownCnt)
 }
 
+func TestServer_Reload(t *testing.T) {
+	ctx, done := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	var cnt int
+	s := New(imagor.New(),
+		WithAddr("127.0.0.1:0"),
+		WithReload(func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			cnt++
+			return errors.New("reload error")
+		}, time.Millisecond*10),
+		WithLogger(zap.NewExample()))
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return cnt
+	}
+	finished := make(chan struct{})
+	go func() {
+		s.RunContext(ctx)
+		close(finished)
+	}()
+	assert.Eventually(t, func() bool {
+		return count() >= 1
+	}, time.Second, time.Millisecond)
+
+	// reload on SIGHUP instead of terminating
+	n := count()
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	assert.Eventually(t, func() bool {
+		return count() > n
+	}, time.Second, time.Millisecond)
+	done()
+	<-finished
+}
+
 func TestServer(t *testing.T) {
 	s := New(
 		imagor.New(
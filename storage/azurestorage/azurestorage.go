@@ -0,0 +1,196 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+)
+
+// AzureStorage Azure Blob Storage implements imagor.Storage interface
+type AzureStorage struct {
+	Client    *azblob.Client
+	Container string
+
+	BaseDir    string
+	PathPrefix string
+	SafeChars  string
+	AccessTier string
+	Expiration time.Duration
+
+	safeChars imagorpath.SafeChars
+}
+
+// New creates AzureStorage
+func New(client *azblob.Client, container string, options ...Option) *AzureStorage {
+	baseDir := "/"
+	if idx := strings.Index(container, "/"); idx > -1 {
+		baseDir = container[idx:]
+		container = container[:idx]
+	}
+	s := &AzureStorage{
+		Client:    client,
+		Container: container,
+
+		BaseDir:    baseDir,
+		PathPrefix: "/",
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.SafeChars == "--" {
+		s.safeChars = imagorpath.NewNoopSafeChars()
+	} else {
+		s.safeChars = imagorpath.NewSafeChars(s.SafeChars)
+	}
+	return s
+}
+
+// Path transforms and validates image key for storage path
+func (s *AzureStorage) Path(image string) (string, bool) {
+	image = "/" + imagorpath.Normalize(image, s.safeChars)
+	if !strings.HasPrefix(image, s.PathPrefix) {
+		return "", false
+	}
+	result := filepath.Join(s.BaseDir, strings.TrimPrefix(image, s.PathPrefix))
+	// Azure blob names must not start with "/"
+	return strings.TrimPrefix(result, "/"), true
+}
+
+func (s *AzureStorage) blobClient(key string) *blockblob.Client {
+	return s.Client.ServiceClient().NewContainerClient(s.Container).NewBlockBlobClient(key)
+}
+
+// Get implements imagor.Storage interface
+func (s *AzureStorage) Get(r *http.Request, image string) (*imagor.Blob, error) {
+	ctx := r.Context()
+	key, ok := s.Path(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	var b *imagor.Blob
+	var once sync.Once
+	b = imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+		out, err := s.Client.DownloadStream(ctx, s.Container, key, nil)
+		if err != nil {
+			if isNotFoundError(err) {
+				return nil, 0, imagor.ErrNotFound
+			}
+			return nil, 0, err
+		}
+		once.Do(func() {
+			if out.ContentType != nil {
+				b.SetContentType(*out.ContentType)
+			}
+			if out.ContentLength != nil && out.ETag != nil && out.LastModified != nil {
+				b.Stat = &imagor.Stat{
+					Size:         *out.ContentLength,
+					ETag:         string(*out.ETag),
+					ModifiedTime: *out.LastModified,
+				}
+			}
+		})
+		if s.Expiration > 0 && out.LastModified != nil {
+			if time.Since(*out.LastModified) > s.Expiration {
+				_ = out.Body.Close()
+				return nil, 0, imagor.ErrExpired
+			}
+		}
+		var size int64
+		if out.ContentLength != nil {
+			size = *out.ContentLength
+		}
+		return out.Body, size, nil
+	})
+	return b, nil
+}
+
+// Put implements imagor.Storage interface
+func (s *AzureStorage) Put(ctx context.Context, image string, b *imagor.Blob) error {
+	key, ok := s.Path(image)
+	if !ok {
+		return imagor.ErrInvalid
+	}
+	reader, _, err := b.NewReadSeeker()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	options := &blockblob.UploadOptions{
+		HTTPHeaders: &blob.HTTPHeaders{
+			BlobContentType: toPtr(b.ContentType()),
+		},
+	}
+	if s.AccessTier != "" {
+		options.Tier = toPtr(blob.AccessTier(s.AccessTier))
+	}
+	_, err = s.blobClient(key).Upload(ctx, reader, options)
+	return err
+}
+
+// Delete implements imagor.Storage interface
+func (s *AzureStorage) Delete(ctx context.Context, image string) error {
+	key, ok := s.Path(image)
+	if !ok {
+		return imagor.ErrInvalid
+	}
+	_, err := s.Client.DeleteBlob(ctx, s.Container, key, nil)
+	if err != nil && isNotFoundError(err) {
+		return nil
+	}
+	return err
+}
+
+// Stat implements imagor.Storage interface
+func (s *AzureStorage) Stat(ctx context.Context, image string) (*imagor.Stat, error) {
+	key, ok := s.Path(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	props, err := s.blobClient(key).GetProperties(ctx, nil)
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil, imagor.ErrNotFound
+		}
+		return nil, err
+	}
+	stat := &imagor.Stat{}
+	if props.ContentLength != nil {
+		stat.Size = *props.ContentLength
+	}
+	if props.ETag != nil {
+		stat.ETag = string(*props.ETag)
+	}
+	if props.LastModified != nil {
+		stat.ModifiedTime = *props.LastModified
+	}
+	return stat, nil
+}
+
+// isNotFoundError checks blob or container not found errors.
+// HEAD responses carry no error body so the status code is checked as well
+func isNotFoundError(err error) bool {
+	if bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound) {
+		return true
+	}
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound
+}
+
+func toPtr[T any](v T) *T {
+	return &v
+}
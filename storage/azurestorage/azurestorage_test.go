@@ -0,0 +1,210 @@
+package azurestorage
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/cshum/imagor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// azuriteConnectionString well-known Azurite emulator development account
+const azuriteConnectionString = "DefaultEndpointsProtocol=http;" +
+	"AccountName=devstoreaccount1;" +
+	"AccountKey=Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw==;" +
+	"BlobEndpoint=http://127.0.0.1:10000/devstoreaccount1;"
+
+func TestAzureStorage_Path(t *testing.T) {
+	tests := []struct {
+		name              string
+		container         string
+		baseDir           string
+		baseURI           string
+		image             string
+		safeChars         string
+		expectedPath      string
+		expectedContainer string
+		expectedOk        bool
+	}{
+		{
+			name:              "defaults ok",
+			container:         "mycontainer",
+			image:             "/foo/bar",
+			expectedContainer: "mycontainer",
+			expectedPath:      "foo/bar",
+			expectedOk:        true,
+		},
+		{
+			name:              "escape unsafe chars",
+			container:         "mycontainer",
+			image:             "/foo/b{:}ar",
+			expectedContainer: "mycontainer",
+			expectedPath:      "foo/b%7B%3A%7Dar",
+			expectedOk:        true,
+		},
+		{
+			name:              "escape safe chars",
+			container:         "mycontainer",
+			image:             "/foo/b{:}ar",
+			expectedContainer: "mycontainer",
+			expectedPath:      "foo/b{%3A}ar",
+			safeChars:         "{}",
+			expectedOk:        true,
+		},
+		{
+			name:              "no-op safe chars",
+			container:         "mycontainer",
+			image:             "/foo/b{:}ar",
+			expectedContainer: "mycontainer",
+			expectedPath:      "foo/b{:}ar",
+			safeChars:         "--",
+			expectedOk:        true,
+		},
+		{
+			name:              "path under with base uri",
+			container:         "mycontainer",
+			baseDir:           "/home/imagor",
+			baseURI:           "/foo",
+			image:             "/foo/bar",
+			expectedContainer: "mycontainer",
+			expectedPath:      "home/imagor/bar",
+			expectedOk:        true,
+		},
+		{
+			name:              "path not under",
+			container:         "mycontainer",
+			baseDir:           "/home/imagor",
+			baseURI:           "/foo",
+			image:             "/fooo/bar",
+			expectedContainer: "mycontainer",
+			expectedOk:        false,
+		},
+		{
+			name:              "extract container path under",
+			container:         "mycontainer/home/imagor",
+			baseURI:           "/foo",
+			image:             "/foo/bar",
+			expectedContainer: "mycontainer",
+			expectedPath:      "home/imagor/bar",
+			expectedOk:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.baseURI != "" {
+				opts = append(opts, WithPathPrefix(tt.baseURI))
+			}
+			if tt.baseDir != "" {
+				opts = append(opts, WithBaseDir(tt.baseDir))
+			}
+			if tt.safeChars != "" {
+				opts = append(opts, WithSafeChars(tt.safeChars))
+			}
+			s := New(nil, tt.container, opts...)
+			res, ok := s.Path(tt.image)
+			assert.Equal(t, tt.expectedContainer, s.Container)
+			if res != tt.expectedPath || ok != tt.expectedOk {
+				t.Errorf(" = %s,%v want %s,%v", res, ok, tt.expectedPath, tt.expectedOk)
+			}
+		})
+	}
+}
+
+func TestWithAccessTier(t *testing.T) {
+	assert.Equal(t, "Cool", New(nil, "a", WithAccessTier("cool")).AccessTier)
+	assert.Equal(t, "Hot", New(nil, "a", WithAccessTier("Hot")).AccessTier)
+	assert.Empty(t, New(nil, "a", WithAccessTier("lukewarm")).AccessTier)
+}
+
+// azuriteClient returns client for the Azurite emulator,
+// skipping the test if the emulator is not reachable
+func azuriteClient(t *testing.T, container string) *azblob.Client {
+	connStr := os.Getenv("AZURITE_CONNECTION_STRING")
+	if connStr == "" {
+		connStr = azuriteConnectionString
+		conn, err := net.DialTimeout("tcp", "127.0.0.1:10000", time.Millisecond*200)
+		if err != nil {
+			t.Skip("Azurite emulator not available")
+		}
+		_ = conn.Close()
+	}
+	client, err := azblob.NewClientFromConnectionString(connStr, nil)
+	require.NoError(t, err)
+	_, _ = client.CreateContainer(context.Background(), container, nil)
+	return client
+}
+
+func TestCRUD(t *testing.T) {
+	client := azuriteClient(t, "test")
+
+	var err error
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+	s := New(client, "test", WithPathPrefix("/foo"), WithAccessTier("Hot"))
+
+	_, err = s.Get(r, "/bar/fooo/asdf")
+	assert.Equal(t, imagor.ErrInvalid, err)
+
+	_, err = s.Stat(ctx, "/bar/fooo/asdf")
+	assert.Equal(t, imagor.ErrInvalid, err)
+
+	assert.ErrorIs(t, s.Put(ctx, "/bar/fooo/asdf", imagor.NewBlobFromBytes([]byte("bar"))), imagor.ErrInvalid)
+
+	assert.Equal(t, imagor.ErrInvalid, s.Delete(ctx, "/bar/fooo/asdf"))
+
+	b, err := s.Get(r, "/foo/fooo/asdf")
+	_, err = b.ReadAll()
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	require.NoError(t, s.Put(ctx, "/foo/fooo/asdf", imagor.NewBlobFromBytes([]byte("bar"))))
+
+	stat, err := s.Stat(ctx, "/foo/fooo/asdf")
+	require.NoError(t, err)
+	assert.True(t, stat.ModifiedTime.Before(time.Now().Add(time.Second)))
+	assert.NotEmpty(t, stat.ETag)
+	assert.Equal(t, int64(3), stat.Size)
+
+	b, err = s.Get(r, "/foo/fooo/asdf")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(buf))
+	require.NotEmpty(t, b.Stat)
+	assert.Equal(t, stat.ETag, b.Stat.ETag)
+
+	require.NoError(t, s.Delete(ctx, "/foo/fooo/asdf"))
+
+	b, err = s.Get(r, "/foo/fooo/asdf")
+	_, err = b.ReadAll()
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	_, err = s.Stat(ctx, "/foo/fooo/asdf")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
+
+func TestExpiration(t *testing.T) {
+	client := azuriteClient(t, "test")
+
+	var err error
+	ctx := context.Background()
+	s := New(client, "test", WithExpiration(time.Second))
+
+	require.NoError(t, s.Put(ctx, "/foo/bar/expire", imagor.NewBlobFromBytes([]byte("bar"))))
+	b, err := s.Get(&http.Request{}, "/foo/bar/expire")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(buf))
+
+	time.Sleep(time.Second * 2)
+	b, _ = s.Get(&http.Request{}, "/foo/bar/expire")
+	_, err = b.ReadAll()
+	require.ErrorIs(t, err, imagor.ErrExpired)
+}
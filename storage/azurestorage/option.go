@@ -0,0 +1,68 @@
+package azurestorage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+)
+
+// Option AzureStorage option
+type Option func(h *AzureStorage)
+
+// WithBaseDir with base dir option
+func WithBaseDir(baseDir string) Option {
+	return func(s *AzureStorage) {
+		if baseDir != "" {
+			baseDir = "/" + strings.Trim(baseDir, "/")
+			if baseDir != "/" {
+				baseDir += "/"
+			}
+			s.BaseDir = baseDir
+		}
+	}
+}
+
+// WithPathPrefix with path prefix option
+func WithPathPrefix(prefix string) Option {
+	return func(s *AzureStorage) {
+		if prefix != "" {
+			prefix = "/" + strings.Trim(prefix, "/")
+			if prefix != "/" {
+				prefix += "/"
+			}
+			s.PathPrefix = prefix
+		}
+	}
+}
+
+// WithSafeChars with safe chars option
+func WithSafeChars(chars string) Option {
+	return func(h *AzureStorage) {
+		if chars != "" {
+			h.SafeChars = chars
+		}
+	}
+}
+
+// WithExpiration with modified time expiration option
+func WithExpiration(exp time.Duration) Option {
+	return func(h *AzureStorage) {
+		if exp > 0 {
+			h.Expiration = exp
+		}
+	}
+}
+
+// WithAccessTier with blob access tier option.
+// Available values: Hot, Cool, Cold, Archive. Default uses the account tier
+func WithAccessTier(tier string) Option {
+	return func(h *AzureStorage) {
+		for _, t := range blob.PossibleAccessTierValues() {
+			if strings.EqualFold(tier, string(t)) {
+				h.AccessTier = string(t)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package encryptedstorage
+
+import (
+	"context"
+	"crypto/aes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/cshum/imagor"
+)
+
+// ErrUnknownKey encrypted object key ID not found in key ring
+var ErrUnknownKey = imagor.NewError("encryption key not found", http.StatusInternalServerError)
+
+// EncryptedStorage Encrypted Storage implements imagor.Storage interface.
+// It wraps another storage, encrypting blobs with AES-GCM on Put and decrypting them on Get.
+// Blobs are encrypted in chunks with the key of KeyID, and decrypted with the key of the ID
+// recorded in the object, so keys can be rotated by adding new keys to the key ring
+type EncryptedStorage struct {
+	Storage imagor.Storage
+	// KeyID key ID of the key ring used for encrypting new blobs
+	KeyID uint32
+
+	keys map[uint32][]byte
+}
+
+// New creates EncryptedStorage wrapping storage,
+// with key ring of AES-128, AES-192 or AES-256 keys by key ID
+func New(storage imagor.Storage, keys map[uint32][]byte, keyID uint32) (*EncryptedStorage, error) {
+	s := &EncryptedStorage{
+		Storage: storage,
+		KeyID:   keyID,
+		keys:    make(map[uint32][]byte, len(keys)),
+	}
+	for id, key := range keys {
+		if _, err := aes.NewCipher(key); err != nil {
+			return nil, fmt.Errorf("encryptedstorage: key %d: %w", id, err)
+		}
+		s.keys[id] = append([]byte{}, key...)
+	}
+	if _, ok := s.keys[keyID]; !ok {
+		return nil, fmt.Errorf("encryptedstorage: key %d not found", keyID)
+	}
+	return s, nil
+}
+
+func (s *EncryptedStorage) key(keyID uint32) ([]byte, error) {
+	if key, ok := s.keys[keyID]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// Get implements imagor.Storage interface
+func (s *EncryptedStorage) Get(r *http.Request, key string) (*imagor.Blob, error) {
+	encrypted, err := s.Storage.Get(r, key)
+	if err != nil {
+		return nil, err
+	}
+	if encrypted == nil {
+		return nil, imagor.ErrNotFound
+	}
+	blob := imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+		reader, size, err := encrypted.NewReader()
+		if err != nil {
+			return nil, 0, err
+		}
+		decrypted := newDecryptReader(reader, s.key)
+		return decrypted, decrypted.decryptedSize(size), nil
+	})
+	if encrypted.Stat != nil {
+		blob.Stat = decryptedStat(encrypted.Stat)
+	}
+	return blob, blob.Err()
+}
+
+// Put implements imagor.Storage interface
+func (s *EncryptedStorage) Put(ctx context.Context, key string, blob *imagor.Blob) error {
+	header, err := newHeader(s.KeyID)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(s.keys[s.KeyID], header)
+	if err != nil {
+		return err
+	}
+	encrypted := imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+		reader, size, err := blob.NewReader()
+		if err != nil {
+			return nil, 0, err
+		}
+		if size > 0 {
+			size = encryptedSize(size)
+		}
+		// same header for every reader of the same blob yields the same ciphertext
+		return newEncryptReader(reader, aead, header), size, nil
+	})
+	encrypted.SetContentType("application/octet-stream")
+	return s.Storage.Put(ctx, key, encrypted)
+}
+
+// Delete implements imagor.Storage interface
+func (s *EncryptedStorage) Delete(ctx context.Context, key string) error {
+	return s.Storage.Delete(ctx, key)
+}
+
+// Stat implements imagor.Storage interface, with size of the decrypted blob
+func (s *EncryptedStorage) Stat(ctx context.Context, key string) (*imagor.Stat, error) {
+	stat, err := s.Storage.Stat(ctx, key)
+	if err != nil || stat == nil {
+		return stat, err
+	}
+	return decryptedStat(stat), nil
+}
+
+// List implements imagor.Lister interface if the wrapped storage implements it,
+// with sizes of the decrypted blobs
+func (s *EncryptedStorage) List(ctx context.Context, fn func(key string, stat *imagor.Stat) error) error {
+	lister, ok := s.Storage.(imagor.Lister)
+	if !ok {
+		return fmt.Errorf("encryptedstorage: %T does not support listing", s.Storage)
+	}
+	return lister.List(ctx, func(key string, stat *imagor.Stat) error {
+		return fn(key, decryptedStat(stat))
+	})
+}
+
+// Startup implements imagor.Lifecycle interface if the wrapped storage implements it
+func (s *EncryptedStorage) Startup(ctx context.Context) error {
+	if lc, ok := s.Storage.(imagor.Lifecycle); ok {
+		return lc.Startup(ctx)
+	}
+	return nil
+}
+
+// Shutdown implements imagor.Lifecycle interface if the wrapped storage implements it
+func (s *EncryptedStorage) Shutdown(ctx context.Context) error {
+	if lc, ok := s.Storage.(imagor.Lifecycle); ok {
+		return lc.Shutdown(ctx)
+	}
+	return nil
+}
+
+// decryptedStat returns stat with size of the decrypted blob.
+// Sizes of version 1 objects of shorter header are underestimated by the salt size
+func decryptedStat(stat *imagor.Stat) *imagor.Stat {
+	return &imagor.Stat{
+		Size:         decryptedSize(stat.Size, headerSize),
+		ETag:         stat.ETag,
+		ModifiedTime: stat.ModifiedTime,
+	}
+}
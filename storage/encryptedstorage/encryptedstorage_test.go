@@ -0,0 +1,218 @@
+package encryptedstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+func key(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func read(s imagor.Storage, key string) ([]byte, error) {
+	b, err := s.Get((&http.Request{}).WithContext(ctx), key)
+	if err != nil {
+		return nil, err
+	}
+	return b.ReadAll()
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(filestorage.New(t.TempDir()), map[uint32][]byte{1: key(1)}, 2)
+	assert.Error(t, err)
+	_, err = New(filestorage.New(t.TempDir()), map[uint32][]byte{1: []byte("foo")}, 1)
+	assert.Error(t, err)
+	_, err = New(filestorage.New(t.TempDir()), map[uint32][]byte{1: key(1)}, 1)
+	assert.NoError(t, err)
+}
+
+func TestPutGet(t *testing.T) {
+	fs := filestorage.New(t.TempDir())
+	s, err := New(fs, map[uint32][]byte{1: key(1)}, 1)
+	require.NoError(t, err)
+
+	for _, size := range []int{0, 1, 1000, ChunkSize - 1, ChunkSize, ChunkSize + 1, ChunkSize*3 + 7} {
+		buf := make([]byte, size)
+		_, _ = rand.Read(buf)
+		require.NoError(t, s.Put(ctx, "foo", imagor.NewBlobFromBytes(buf)))
+
+		raw, err := read(fs, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, encryptedSize(int64(size)), int64(len(raw)), size)
+		if size >= 16 {
+			// shorter plaintext may appear in ciphertext by chance
+			assert.False(t, bytes.Contains(raw, buf), size)
+		}
+
+		res, err := read(s, "foo")
+		require.NoError(t, err, size)
+		assert.True(t, bytes.Equal(buf, res), size)
+
+		stat, err := s.Stat(ctx, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, int64(size), stat.Size, size)
+	}
+
+	_, err = s.Get((&http.Request{}).WithContext(ctx), "bar")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	_, err = s.Stat(ctx, "bar")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	require.NoError(t, s.Delete(ctx, "foo"))
+	_, err = fs.Stat(ctx, "foo")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
+
+func TestKeyRotation(t *testing.T) {
+	fs := filestorage.New(t.TempDir())
+	s1, err := New(fs, map[uint32][]byte{1: key(1)}, 1)
+	require.NoError(t, err)
+	require.NoError(t, s1.Put(ctx, "old", imagor.NewBlobFromBytes([]byte("old"))))
+
+	s2, err := New(fs, map[uint32][]byte{1: key(1), 2: key(2)}, 2)
+	require.NoError(t, err)
+	require.NoError(t, s2.Put(ctx, "new", imagor.NewBlobFromBytes([]byte("new"))))
+
+	buf, err := read(s2, "old")
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(buf))
+	buf, err = read(s2, "new")
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(buf))
+
+	_, err = read(s1, "new")
+	assert.Equal(t, ErrUnknownKey, err)
+
+	// same key ID with wrong key
+	s3, err := New(fs, map[uint32][]byte{1: key(3)}, 1)
+	require.NoError(t, err)
+	_, err = read(s3, "old")
+	assert.Error(t, err)
+}
+
+func TestSubkeys(t *testing.T) {
+	fs := filestorage.New(t.TempDir())
+	s, err := New(fs, map[uint32][]byte{1: key(1)}, 1)
+	require.NoError(t, err)
+	buf := []byte("foo")
+	require.NoError(t, s.Put(ctx, "a", imagor.NewBlobFromBytes(buf)))
+	require.NoError(t, s.Put(ctx, "b", imagor.NewBlobFromBytes(buf)))
+	a, err := read(fs, "a")
+	require.NoError(t, err)
+	b, err := read(fs, "b")
+	require.NoError(t, err)
+	assert.Equal(t, byte(version), a[len(magic)])
+	assert.Equal(t, a[:infoSize], b[:infoSize])
+	assert.NotEqual(t, a[infoSize:infoSize+saltSize], b[infoSize:infoSize+saltSize])
+
+	// chunks are not sealed with the key itself
+	aead, err := newAEAD(key(1), newHeaderV1(a[len(a)-prefixSize:]))
+	require.NoError(t, err)
+	_, err = aead.Open(nil, chunkNonce(a[:headerSize], 0, true), a[headerSize:], a[:headerSize])
+	assert.Error(t, err)
+}
+
+// newHeaderV1 creates version 1 header of key ID 1
+func newHeaderV1(prefix []byte) []byte {
+	header := append([]byte(magic), versionV1, 0, 0, 0, 1)
+	return append(header, prefix...)
+}
+
+func TestVersion1(t *testing.T) {
+	fs := filestorage.New(t.TempDir())
+	s, err := New(fs, map[uint32][]byte{1: key(1)}, 1)
+	require.NoError(t, err)
+	for _, size := range []int{0, 1000, ChunkSize + 1} {
+		buf := make([]byte, size)
+		_, _ = rand.Read(buf)
+		header := newHeaderV1(bytes.Repeat([]byte{7}, prefixSize))
+		aead, err := newAEAD(key(1), header)
+		require.NoError(t, err)
+		require.NoError(t, fs.Put(ctx, "foo", imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+			return newEncryptReader(io.NopCloser(bytes.NewReader(buf)), aead, header), 0, nil
+		})))
+
+		b, err := s.Get((&http.Request{}).WithContext(ctx), "foo")
+		require.NoError(t, err)
+		_, n, err := b.NewReader()
+		require.NoError(t, err)
+		assert.Equal(t, int64(size), n, size)
+		res, err := b.ReadAll()
+		require.NoError(t, err, size)
+		assert.True(t, bytes.Equal(buf, res), size)
+	}
+}
+
+func TestTampering(t *testing.T) {
+	fs := filestorage.New(t.TempDir())
+	s, err := New(fs, map[uint32][]byte{1: key(1)}, 1)
+	require.NoError(t, err)
+	buf := make([]byte, ChunkSize*2)
+	_, _ = rand.Read(buf)
+	require.NoError(t, s.Put(ctx, "foo", imagor.NewBlobFromBytes(buf)))
+	p, _ := fs.Path("foo")
+	raw, err := os.ReadFile(p)
+	require.NoError(t, err)
+
+	write := func(b []byte) {
+		require.NoError(t, os.WriteFile(p, b, 0644))
+	}
+
+	// flipped byte
+	flipped := append([]byte{}, raw...)
+	flipped[headerSize+10] ^= 1
+	write(flipped)
+	_, err = read(s, "foo")
+	assert.Error(t, err)
+
+	// truncated at chunk boundary
+	write(raw[:headerSize+sealedSize])
+	_, err = read(s, "foo")
+	assert.Error(t, err)
+
+	// header only
+	write(raw[:headerSize])
+	_, err = read(s, "foo")
+	assert.Error(t, err)
+
+	// not encrypted
+	write([]byte("plain text"))
+	_, err = read(s, "foo")
+	assert.Error(t, err)
+
+	write(raw)
+	res, err := read(s, "foo")
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(buf, res))
+}
+
+func TestList(t *testing.T) {
+	fs := filestorage.New(t.TempDir())
+	s, err := New(fs, map[uint32][]byte{1: key(1)}, 1)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, "foo/bar", imagor.NewBlobFromBytes([]byte("bar"))))
+	listed := map[string]int64{}
+	require.NoError(t, s.List(ctx, func(key string, stat *imagor.Stat) error {
+		listed[key] = stat.Size
+		return nil
+	}))
+	assert.Equal(t, map[string]int64{"foo/bar": 3}, listed)
+
+	s, err = New(struct{ imagor.Storage }{fs}, map[uint32][]byte{1: key(1)}, 1)
+	require.NoError(t, err)
+	assert.Error(t, s.List(ctx, func(string, *imagor.Stat) error {
+		return nil
+	}))
+}
@@ -0,0 +1,303 @@
+package encryptedstorage
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Encrypted object layout, version 2:
+//
+//	header: magic "IENC" | version 2 | key ID uint32 | salt 32 bytes | nonce prefix 7 bytes
+//	chunks: AES-GCM sealed chunks of ChunkSize plaintext bytes, the last one possibly shorter or empty
+//
+// Chunks are sealed with a subkey of the object, derived from the key of key ID by HKDF-SHA256
+// with the random salt and the header up to key ID as info, so that nonces never repeat under the same key.
+// Chunk nonce is nonce prefix | chunk counter uint32 | last chunk flag,
+// and header is the additional data of every chunk,
+// so that chunks cannot be reordered, truncated or moved across objects.
+//
+// Version 1 objects, without salt and sealed with the key of key ID itself, are still decrypted
+const (
+	magic        = "IENC"
+	version      = 2
+	versionV1    = 1
+	infoSize     = len(magic) + 1 + 4
+	saltSize     = 32
+	prefixSize   = 7
+	headerSize   = infoSize + saltSize + prefixSize
+	headerSizeV1 = infoSize + prefixSize
+	overhead     = 16
+	sealedSize   = ChunkSize + overhead
+	maxCounters  = 1<<32 - 1
+
+	// ChunkSize plaintext size of each encrypted chunk
+	ChunkSize = 64 << 10
+)
+
+var (
+	errInvalidHeader = errors.New("encryptedstorage: invalid header")
+	errTruncated     = errors.New("encryptedstorage: truncated object")
+	errTooLarge      = errors.New("encryptedstorage: object too large")
+)
+
+// newHeader creates header of key ID with random salt and nonce prefix
+func newHeader(keyID uint32) ([]byte, error) {
+	header := make([]byte, 0, headerSize)
+	header = append(header, magic...)
+	header = append(header, version)
+	header = binary.BigEndian.AppendUint32(header, keyID)
+	header = header[:headerSize]
+	if _, err := rand.Read(header[infoSize:]); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// headerSizeOf returns header size of version, 0 if unknown
+func headerSizeOf(v byte) int {
+	switch v {
+	case version:
+		return headerSize
+	case versionV1:
+		return headerSizeV1
+	}
+	return 0
+}
+
+func parseHeader(header []byte) (keyID uint32, err error) {
+	if len(header) <= len(magic) || string(header[:len(magic)]) != magic ||
+		len(header) != headerSizeOf(header[len(magic)]) {
+		return 0, errInvalidHeader
+	}
+	return binary.BigEndian.Uint32(header[len(magic)+1:]), nil
+}
+
+// newAEAD creates AES-GCM of the object of header, sealed with the subkey derived from key
+func newAEAD(key, header []byte) (cipher.AEAD, error) {
+	if header[len(magic)] != versionV1 {
+		salt := header[infoSize : infoSize+saltSize]
+		var err error
+		if key, err = hkdf.Key(sha256.New, key, salt, string(header[:infoSize]), len(key)); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(header []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, header[len(header)-prefixSize:])
+	binary.BigEndian.PutUint32(nonce[prefixSize:], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptedSize returns encrypted size of plaintext size
+func encryptedSize(size int64) int64 {
+	chunks := size / ChunkSize
+	if size%ChunkSize != 0 || size == 0 {
+		chunks++
+	}
+	return int64(headerSize) + size + chunks*overhead
+}
+
+// decryptedSize returns plaintext size of encrypted size with header of size headerSize, 0 if unknown
+func decryptedSize(size int64, headerSize int) int64 {
+	n := size - int64(headerSize)
+	if n < overhead {
+		return 0
+	}
+	chunks := (n + sealedSize - 1) / sealedSize
+	return n - chunks*overhead
+}
+
+type encryptReader struct {
+	src     io.ReadCloser
+	buf     *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	chunk   []byte
+	sealed  []byte
+	out     []byte
+	counter uint32
+	done    bool
+}
+
+func newEncryptReader(src io.ReadCloser, aead cipher.AEAD, header []byte) *encryptReader {
+	return &encryptReader{
+		src:    src,
+		buf:    bufio.NewReaderSize(src, ChunkSize),
+		aead:   aead,
+		header: header,
+		chunk:  make([]byte, ChunkSize),
+		sealed: make([]byte, 0, sealedSize),
+		out:    header,
+	}
+}
+
+func (r *encryptReader) Read(p []byte) (n int, err error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err = r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n = copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *encryptReader) next() error {
+	n, err := io.ReadFull(r.buf, r.chunk)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	last := n < ChunkSize
+	if !last {
+		// look ahead, so that the last chunk is flagged even if it is full
+		if _, err = r.buf.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if r.counter == maxCounters && !last {
+		return errTooLarge
+	}
+	r.out = r.aead.Seal(r.sealed[:0], chunkNonce(r.header, r.counter, last), r.chunk[:n], r.header)
+	r.counter++
+	r.done = last
+	return nil
+}
+
+func (r *encryptReader) Close() error {
+	return r.src.Close()
+}
+
+type decryptReader struct {
+	src     io.ReadCloser
+	buf     *bufio.Reader
+	keys    func(keyID uint32) ([]byte, error)
+	aead    cipher.AEAD
+	header  []byte
+	chunk   []byte
+	out     []byte
+	counter uint32
+	done    bool
+}
+
+func newDecryptReader(src io.ReadCloser, keys func(keyID uint32) ([]byte, error)) *decryptReader {
+	return &decryptReader{
+		src:   src,
+		buf:   bufio.NewReaderSize(src, sealedSize),
+		keys:  keys,
+		chunk: make([]byte, sealedSize),
+	}
+}
+
+// decryptedSize returns plaintext size of encrypted size by the header version, 0 if unknown
+func (r *decryptReader) decryptedSize(size int64) int64 {
+	b, err := r.buf.Peek(len(magic) + 1)
+	if err != nil {
+		return 0
+	}
+	if n := headerSizeOf(b[len(magic)]); n > 0 {
+		return decryptedSize(size, n)
+	}
+	return 0
+}
+
+func (r *decryptReader) Read(p []byte) (n int, err error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err = r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n = copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *decryptReader) next() error {
+	if r.header == nil {
+		if err := r.readHeader(); err != nil {
+			return err
+		}
+	}
+	n, err := io.ReadFull(r.buf, r.chunk)
+	if errors.Is(err, io.EOF) {
+		return errTruncated
+	} else if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	last := n < sealedSize
+	if !last {
+		if _, err = r.buf.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if r.out, err = r.aead.Open(
+		r.chunk[:0], chunkNonce(r.header, r.counter, last), r.chunk[:n], r.header,
+	); err != nil {
+		return err
+	}
+	r.counter++
+	r.done = last
+	return nil
+}
+
+func (r *decryptReader) readHeader() error {
+	header := make([]byte, len(magic)+1, headerSize)
+	if _, err := io.ReadFull(r.buf, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return errInvalidHeader
+		}
+		return err
+	}
+	if n := headerSizeOf(header[len(magic)]); n > len(header) {
+		header = header[:n]
+		if _, err := io.ReadFull(r.buf, header[len(magic)+1:]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return errInvalidHeader
+			}
+			return err
+		}
+	}
+	keyID, err := parseHeader(header)
+	if err != nil {
+		return err
+	}
+	key, err := r.keys(keyID)
+	if err != nil {
+		return err
+	}
+	if r.aead, err = newAEAD(key, header); err != nil {
+		return err
+	}
+	r.header = header
+	return nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.src.Close()
+}
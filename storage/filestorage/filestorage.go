@@ -2,9 +2,12 @@ package filestorage
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -26,8 +29,16 @@ type FileStorage struct {
 	SaveErrIfExists bool
 	SafeChars       string
 	Expiration      time.Duration
+	MaxSize         int64
+	MaxFiles        int
+	JanitorInterval time.Duration
+	// ShardDepth and ShardWidth shard files into hashed subdirectories,
+	// e.g. depth 2 width 2 stores foo/bar.jpg as 3f/a2/foo/bar.jpg
+	ShardDepth int
+	ShardWidth int
 
 	safeChars imagorpath.SafeChars
+	janitor   *janitor
 }
 
 // New creates FileStorage
@@ -43,6 +54,12 @@ func New(baseDir string, options ...Option) *FileStorage {
 		option(s)
 	}
 	s.safeChars = imagorpath.NewSafeChars(s.SafeChars)
+	if s.MaxSize > 0 || s.MaxFiles > 0 || s.JanitorInterval > 0 {
+		if s.JanitorInterval <= 0 {
+			s.JanitorInterval = time.Minute
+		}
+		s.janitor = newJanitor()
+	}
 	return s
 }
 
@@ -57,7 +74,22 @@ func (s *FileStorage) Path(image string) (string, bool) {
 	if !strings.HasPrefix(image, s.PathPrefix) {
 		return "", false
 	}
-	return filepath.Join(s.BaseDir, strings.TrimPrefix(image, s.PathPrefix)), true
+	image = strings.TrimPrefix(image, s.PathPrefix)
+	return filepath.Join(s.BaseDir, filepath.FromSlash(s.shard(image))), true
+}
+
+// shard prepends hashed subdirectories to key if sharding enabled
+func (s *FileStorage) shard(key string) string {
+	if s.ShardDepth <= 0 || s.ShardWidth <= 0 {
+		return key
+	}
+	sum := sha1.Sum([]byte(key))
+	hash := hex.EncodeToString(sum[:])
+	dirs := make([]string, 0, s.ShardDepth+1)
+	for i := 0; i < s.ShardDepth; i++ {
+		dirs = append(dirs, hash[i*s.ShardWidth:(i+1)*s.ShardWidth])
+	}
+	return path.Join(append(dirs, key)...)
 }
 
 // Get implements imagor.Storage interface
@@ -72,6 +104,9 @@ func (s *FileStorage) Get(_ *http.Request, image string) (*imagor.Blob, error) {
 		}
 		return nil
 	})
+	if s.janitor != nil && blob.Err() == nil {
+		s.janitor.touch(image)
+	}
 	return blob, blob.Err()
 }
 
@@ -111,6 +146,9 @@ func (s *FileStorage) Put(_ context.Context, image string, blob *imagor.Blob) (e
 	if err = w.Sync(); err != nil {
 		return
 	}
+	if s.janitor != nil {
+		s.janitor.touch(image)
+	}
 	return
 }
 
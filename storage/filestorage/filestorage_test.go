@@ -2,9 +2,12 @@ package filestorage
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -21,9 +24,39 @@ func TestFileStore_Path(t *testing.T) {
 		image      string
 		blacklist  *regexp.Regexp
 		safeChars  string
+		shardDepth int
+		shardWidth int
 		expected   string
 		expectedOk bool
 	}{
+		{
+			name:       "sharded",
+			baseDir:    "/home/imagor",
+			image:      "/foo/bar.jpg",
+			shardDepth: 2,
+			shardWidth: 2,
+			expected:   "/home/imagor/f3/01/foo/bar.jpg",
+			expectedOk: true,
+		},
+		{
+			name:       "sharded with base uri",
+			baseDir:    "/home/imagor",
+			baseURI:    "/abc",
+			image:      "/abc/foo/bar.jpg",
+			shardDepth: 3,
+			shardWidth: 1,
+			expected:   "/home/imagor/f/3/0/foo/bar.jpg",
+			expectedOk: true,
+		},
+		{
+			name:       "shard exceeds hash length ignored",
+			baseDir:    "/home/imagor",
+			image:      "/foo/bar.jpg",
+			shardDepth: 11,
+			shardWidth: 4,
+			expected:   "/home/imagor/foo/bar.jpg",
+			expectedOk: true,
+		},
 		{
 			name:       "escape unsafe chars",
 			baseDir:    "/home/imagor",
@@ -121,6 +154,7 @@ func TestFileStore_Path(t *testing.T) {
 				WithPathPrefix(tt.baseURI),
 				WithBlacklist(tt.blacklist),
 				WithSafeChars(tt.safeChars),
+				WithShard(tt.shardDepth, tt.shardWidth),
 			).Path(tt.image)
 			if res != tt.expected || ok != tt.expectedOk {
 				t.Errorf(" = %s,%v want %s,%v", res, ok, tt.expected, tt.expectedOk)
@@ -217,6 +251,186 @@ func TestFileStorage_Load_Save(t *testing.T) {
 	})
 }
 
+func TestJanitor(t *testing.T) {
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+
+	put := func(t *testing.T, s *FileStorage, key string, size int, age time.Duration) {
+		require.NoError(t, s.Put(ctx, key, imagor.NewBlobFromBytes(make([]byte, size))))
+		p, _ := s.Path(key)
+		mtime := time.Now().Add(-age)
+		require.NoError(t, os.Chtimes(p, mtime, mtime))
+	}
+	exists := func(s *FileStorage, key string) bool {
+		_, err := s.Stat(ctx, key)
+		return err == nil
+	}
+
+	t.Run("max files evicts least recently accessed", func(t *testing.T) {
+		s := New(t.TempDir(), WithMaxFiles(2), WithJanitorInterval(time.Hour))
+		put(t, s, "/a/1", 10, time.Minute*3)
+		put(t, s, "/a/2", 10, time.Minute*2)
+		put(t, s, "/b/3", 10, time.Minute)
+		// access oldest file so it is kept
+		_, err := checkBlob(s.Get(r, "/a/1"))
+		require.NoError(t, err)
+
+		require.NoError(t, s.Clean(ctx))
+		assert.True(t, exists(s, "/a/1"))
+		assert.False(t, exists(s, "/a/2"))
+		assert.True(t, exists(s, "/b/3"))
+	})
+
+	t.Run("max size", func(t *testing.T) {
+		s := New(t.TempDir(), WithMaxSize(25), WithJanitorInterval(time.Hour))
+		put(t, s, "/a/1", 10, time.Minute*3)
+		put(t, s, "/b/2", 10, time.Minute*2)
+		put(t, s, "/b/3", 10, time.Minute)
+
+		require.NoError(t, s.Clean(ctx))
+		assert.False(t, exists(s, "/a/1"))
+		assert.True(t, exists(s, "/b/2"))
+		assert.True(t, exists(s, "/b/3"))
+		// empty directory removed
+		_, err := os.Stat(filepath.Join(s.BaseDir, "a"))
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(s.BaseDir)
+		assert.NoError(t, err)
+	})
+
+	t.Run("expired files deleted eagerly", func(t *testing.T) {
+		s := New(t.TempDir(), WithExpiration(time.Hour), WithJanitorInterval(time.Hour))
+		put(t, s, "/a/1", 10, time.Hour*2)
+		put(t, s, "/a/2", 10, time.Minute)
+
+		require.NoError(t, s.Clean(ctx))
+		assert.False(t, exists(s, "/a/1"))
+		assert.True(t, exists(s, "/a/2"))
+	})
+
+	t.Run("background", func(t *testing.T) {
+		s := New(t.TempDir(), WithMaxFiles(1), WithJanitorInterval(time.Millisecond*50))
+		put(t, s, "/a/1", 10, time.Minute)
+		put(t, s, "/a/2", 10, 0)
+		// not running before startup
+		time.Sleep(time.Millisecond * 150)
+		assert.True(t, exists(s, "/a/1"))
+
+		require.NoError(t, s.Startup(ctx))
+		require.NoError(t, s.Startup(ctx))
+		assert.Eventually(t, func() bool {
+			return !exists(s, "/a/1")
+		}, time.Second, time.Millisecond*50)
+		assert.True(t, exists(s, "/a/2"))
+
+		// not running after shutdown
+		require.NoError(t, s.Shutdown(ctx))
+		require.NoError(t, s.Shutdown(ctx))
+		put(t, s, "/a/3", 10, 0)
+		time.Sleep(time.Millisecond * 150)
+		assert.True(t, exists(s, "/a/2"))
+		assert.True(t, exists(s, "/a/3"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s := New(t.TempDir(), WithExpiration(time.Hour))
+		assert.Nil(t, s.janitor)
+		assert.NoError(t, s.Clean(ctx))
+		assert.NoError(t, s.Startup(ctx))
+		assert.NoError(t, s.Shutdown(ctx))
+	})
+}
+
+func TestReshard(t *testing.T) {
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+	dir := t.TempDir()
+
+	flat := New(dir)
+	keys := []string{"/foo/bar.jpg", "/foo/baz/qux.png", "/abc.gif"}
+	for _, key := range keys {
+		require.NoError(t, flat.Put(ctx, key, imagor.NewBlobFromBytes([]byte(key))))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".keep"), nil, 0666))
+
+	s := New(dir, WithShard(2, 2))
+	moved, err := s.Reshard(ctx, true, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, moved)
+	_, err = checkBlob(s.Get(r, "/foo/bar.jpg"))
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	var moves []string
+	moved, err = s.Reshard(ctx, false, func(from, to string) {
+		moves = append(moves, to)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, moved)
+	assert.Contains(t, moves, filepath.Join(dir, "f3", "01", "foo", "bar.jpg"))
+	for _, key := range keys {
+		b, err := checkBlob(s.Get(r, key))
+		require.NoError(t, err)
+		buf, err := b.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, key, string(buf))
+
+		_, err = checkBlob(flat.Get(r, key))
+		assert.Equal(t, imagor.ErrNotFound, err)
+	}
+	// old empty directories removed, dot files untouched
+	_, err = os.Stat(filepath.Join(dir, "foo", "baz"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, ".keep"))
+	assert.NoError(t, err)
+
+	// idempotent
+	moved, err = s.Reshard(ctx, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, moved)
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+	dir := t.TempDir()
+	keys := []string{"abc.gif", "foo/bar.jpg", "foo/baz/filters:fill(white)/qux .png"}
+
+	list := func(s *FileStorage) map[string]int64 {
+		res := map[string]int64{}
+		require.NoError(t, s.List(ctx, func(key string, stat *imagor.Stat) error {
+			res[key] = stat.Size
+			return nil
+		}))
+		return res
+	}
+	for _, s := range []*FileStorage{
+		New(filepath.Join(dir, "flat")),
+		New(filepath.Join(dir, "prefixed"), WithPathPrefix("/prefix")),
+		New(filepath.Join(dir, "sharded"), WithShard(2, 2)),
+	} {
+		for _, key := range keys {
+			key = strings.TrimPrefix(s.PathPrefix+key, "/")
+			require.NoError(t, s.Put(ctx, key, imagor.NewBlobFromBytes([]byte(key))))
+		}
+		listed := list(s)
+		assert.Len(t, listed, len(keys))
+		for _, key := range keys {
+			key = strings.TrimPrefix(s.PathPrefix+key, "/")
+			assert.Equal(t, int64(len(key)), listed[key], key)
+			_, err := checkBlob(s.Get(r, key))
+			assert.NoError(t, err, key)
+		}
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sharded", "unsharded.jpg"), nil, 0666))
+	assert.Len(t, list(New(filepath.Join(dir, "sharded"), WithShard(2, 2))), len(keys))
+	assert.Len(t, list(New(filepath.Join(dir, "missing"))), 0)
+
+	errStop := errors.New("stop")
+	assert.Equal(t, errStop, New(dir).List(ctx, func(string, *imagor.Stat) error {
+		return errStop
+	}))
+}
+
 func checkBlob(blob *imagor.Blob, err error) (*imagor.Blob, error) {
 	if blob != nil && err == nil {
 		err = blob.Err()
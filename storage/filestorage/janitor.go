@@ -0,0 +1,198 @@
+package filestorage
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// janitor tracks file access in memory, since atime is often disabled,
+// and periodically enforces expiration and size limits of FileStorage
+// between app Startup and Shutdown
+type janitor struct {
+	mu       sync.Mutex
+	accessed map[string]time.Time
+	cleanMu  sync.Mutex
+	runMu    sync.Mutex
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func newJanitor() *janitor {
+	return &janitor{
+		accessed: map[string]time.Time{},
+	}
+}
+
+func (j *janitor) touch(path string) {
+	j.mu.Lock()
+	j.accessed[path] = time.Now()
+	j.mu.Unlock()
+}
+
+// start runs the janitor in background if not running
+func (j *janitor) start(s *FileStorage) {
+	j.runMu.Lock()
+	defer j.runMu.Unlock()
+	if j.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+	j.done = make(chan struct{})
+	go j.run(ctx, s, j.done)
+}
+
+func (j *janitor) run(ctx context.Context, s *FileStorage, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(s.JanitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = s.Clean(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// stop stops the background janitor, waiting for the clean in progress to be cancelled
+func (j *janitor) stop(ctx context.Context) error {
+	j.runMu.Lock()
+	cancel, done := j.cancel, j.done
+	j.cancel, j.done = nil, nil
+	j.runMu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type janitorFile struct {
+	path       string
+	size       int64
+	modTime    time.Time
+	accessTime time.Time
+}
+
+// Clean deletes expired files, then evicts least recently accessed files
+// until total size and file count are within MaxSize and MaxFiles.
+// It is run periodically in background between Startup and Shutdown,
+// if any of the limits or JanitorInterval is set
+func (s *FileStorage) Clean(ctx context.Context) error {
+	j := s.janitor
+	if j == nil {
+		return nil
+	}
+	j.cleanMu.Lock()
+	defer j.cleanMu.Unlock()
+	var files []janitorFile
+	err := filepath.WalkDir(s.BaseDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, janitorFile{
+			path:       path,
+			size:       info.Size(),
+			modTime:    info.ModTime(),
+			accessTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// rebuild access map with existing files only
+	j.mu.Lock()
+	accessed := make(map[string]time.Time, len(files))
+	for i, f := range files {
+		if t, ok := j.accessed[f.path]; ok && t.After(f.accessTime) {
+			files[i].accessTime = t
+			accessed[f.path] = t
+		}
+	}
+	j.accessed = accessed
+	j.mu.Unlock()
+
+	sort.Slice(files, func(a, b int) bool {
+		return files[a].accessTime.Before(files[b].accessTime)
+	})
+	var totalSize int64
+	for _, f := range files {
+		totalSize += f.size
+	}
+	count := len(files)
+	now := time.Now()
+	for _, f := range files {
+		expired := s.Expiration > 0 && now.Sub(f.modTime) > s.Expiration
+		exceeded := (s.MaxSize > 0 && totalSize > s.MaxSize) ||
+			(s.MaxFiles > 0 && count > s.MaxFiles)
+		if !expired && !exceeded {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			continue
+		}
+		totalSize -= f.size
+		count--
+		j.mu.Lock()
+		delete(j.accessed, f.path)
+		j.mu.Unlock()
+		s.removeEmptyDirs(filepath.Dir(f.path))
+	}
+	return nil
+}
+
+// removeEmptyDirs removes empty parent directories up to base dir
+func (s *FileStorage) removeEmptyDirs(dir string) {
+	base := filepath.Clean(s.BaseDir)
+	for dir != base && len(dir) > len(base) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// Startup implements imagor.Lifecycle interface, starting the background janitor
+func (s *FileStorage) Startup(_ context.Context) error {
+	if s.janitor != nil {
+		s.janitor.start(s)
+	}
+	return nil
+}
+
+// Shutdown implements imagor.Lifecycle interface, stopping the background janitor
+func (s *FileStorage) Shutdown(ctx context.Context) error {
+	if s.janitor != nil {
+		return s.janitor.stop(ctx)
+	}
+	return nil
+}
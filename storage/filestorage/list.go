@@ -0,0 +1,62 @@
+package filestorage
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+)
+
+// List implements imagor.Lister interface.
+// Files outside of the sharded layout are skipped if sharding enabled
+func (s *FileStorage) List(ctx context.Context, fn func(key string, stat *imagor.Stat) error) error {
+	sharded := s.ShardDepth > 0 && s.ShardWidth > 0
+	return filepath.WalkDir(s.BaseDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p != s.BaseDir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.BaseDir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if sharded {
+			if !s.isSharded(key) {
+				return nil
+			}
+			key = strings.SplitN(key, "/", s.ShardDepth+1)[s.ShardDepth]
+		}
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		key = strings.TrimPrefix(path.Join(s.PathPrefix, key), "/")
+		return fn(imagorpath.Denormalize(key, s.safeChars), &imagor.Stat{
+			Size:         info.Size(),
+			ModifiedTime: info.ModTime(),
+		})
+	})
+}
@@ -1,6 +1,7 @@
 package filestorage
 
 import (
+	"crypto/sha1"
 	"os"
 	"regexp"
 	"strconv"
@@ -79,3 +80,44 @@ func WithExpiration(exp time.Duration) Option {
 		}
 	}
 }
+
+// WithMaxSize with maximum total size in bytes option.
+// Least recently accessed files are evicted by background janitor when exceeded
+func WithMaxSize(size int64) Option {
+	return func(h *FileStorage) {
+		if size > 0 {
+			h.MaxSize = size
+		}
+	}
+}
+
+// WithMaxFiles with maximum file count option.
+// Least recently accessed files are evicted by background janitor when exceeded
+func WithMaxFiles(count int) Option {
+	return func(h *FileStorage) {
+		if count > 0 {
+			h.MaxFiles = count
+		}
+	}
+}
+
+// WithJanitorInterval with background janitor interval option.
+// Janitor deletes expired files and enforces max size and max files
+func WithJanitorInterval(interval time.Duration) Option {
+	return func(h *FileStorage) {
+		if interval > 0 {
+			h.JanitorInterval = interval
+		}
+	}
+}
+
+// WithShard with sharded directory layout option.
+// Keys are stored under depth levels of hashed subdirectories, each of width hex characters
+func WithShard(depth, width int) Option {
+	return func(h *FileStorage) {
+		if depth > 0 && width > 0 && depth*width <= sha1.Size*2 {
+			h.ShardDepth = depth
+			h.ShardWidth = width
+		}
+	}
+}
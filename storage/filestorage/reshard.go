@@ -0,0 +1,85 @@
+package filestorage
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Reshard re-lays existing files under base dir into the sharded directory layout
+// of FileStorage. Files already at their sharded location are left as is,
+// and files whose sharded location is taken are skipped.
+// If dryRun, files are not moved. cb is called for each file to be moved
+func (s *FileStorage) Reshard(
+	ctx context.Context, dryRun bool, cb func(from, to string),
+) (moved int, err error) {
+	if s.ShardDepth <= 0 || s.ShardWidth <= 0 {
+		return 0, nil
+	}
+	var keys []string
+	// collect files before moving, so that walk does not visit moved files
+	if err = filepath.WalkDir(s.BaseDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p != s.BaseDir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.BaseDir, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); !s.isSharded(key) {
+			keys = append(keys, key)
+		}
+		return nil
+	}); err != nil {
+		return
+	}
+	for _, key := range keys {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		from := filepath.Join(s.BaseDir, filepath.FromSlash(key))
+		to := filepath.Join(s.BaseDir, filepath.FromSlash(s.shard(key)))
+		if _, e := os.Lstat(to); e == nil {
+			continue
+		}
+		if cb != nil {
+			cb(from, to)
+		}
+		if dryRun {
+			moved++
+			continue
+		}
+		if err = os.MkdirAll(filepath.Dir(to), s.MkdirPermission); err != nil {
+			return
+		}
+		if err = os.Rename(from, to); err != nil {
+			return
+		}
+		moved++
+		s.removeEmptyDirs(filepath.Dir(from))
+	}
+	return
+}
+
+// isSharded checks if key is already under its hashed subdirectories
+func (s *FileStorage) isSharded(key string) bool {
+	parts := strings.SplitN(key, "/", s.ShardDepth+1)
+	if len(parts) != s.ShardDepth+1 {
+		return false
+	}
+	return s.shard(parts[s.ShardDepth]) == key
+}
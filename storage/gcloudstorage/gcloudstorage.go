@@ -5,15 +5,23 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/url"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/cshum/imagor"
 	"github.com/cshum/imagor/imagorpath"
+	"google.golang.org/api/iterator"
 )
 
+var errEncryptionKeyPresign = errors.New("gcloudstorage: presign not supported with customer-supplied encryption key")
+
+var errListWildcardBucket = errors.New("gcloudstorage: list not supported with wildcard bucket")
+
 // GCloudStorage Google Cloud Storage implements imagor.Storage interface
 type GCloudStorage struct {
 	BaseDir    string
@@ -21,6 +29,15 @@ type GCloudStorage struct {
 	ACL        string
 	SafeChars  string
 	Expiration time.Duration
+	PublicURL  string
+	// KMSKeyName Cloud KMS key used to encrypt new objects (CMEK)
+	KMSKeyName string
+	// EncryptionKey customer-supplied AES-256 key (CSEK),
+	// required for both reads and writes of the objects
+	EncryptionKey []byte
+	// Versioning reads the object generation of image key version selector
+	// e.g. image.jpg?versionId=1700000000000000
+	Versioning bool
 	client     *storage.Client
 	Bucket     string
 
@@ -85,14 +102,32 @@ func (s *GCloudStorage) pathFromNormalized(image string) (string, bool) {
 	return strings.Trim(joinedPath, "/"), true
 }
 
+// splitVersion splits version selector from image key as object generation
+// if Versioning enabled, ok false if generation is invalid
+func (s *GCloudStorage) splitVersion(image string) (key string, generation int64, ok bool) {
+	if !s.Versioning {
+		return image, 0, true
+	}
+	key, versionID := imagorpath.SplitVersion(image)
+	if versionID == "" {
+		return key, 0, true
+	}
+	generation, err := strconv.ParseInt(versionID, 10, 64)
+	return key, generation, err == nil && generation > 0
+}
+
 // Get implements imagor.Storage interface
 func (s *GCloudStorage) Get(r *http.Request, image string) (imageData *imagor.Blob, err error) {
 	ctx := r.Context()
+	image, generation, ok := s.splitVersion(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
 	bucket, image, ok := s.resolveRequest(ctx, image)
 	if !ok {
 		return nil, imagor.ErrInvalid
 	}
-	object := s.client.Bucket(bucket).Object(image)
+	object := s.object(bucket, image, generation)
 	attrs, err := object.Attrs(ctx)
 	if err != nil {
 		if errors.Is(err, storage.ErrObjectNotExist) {
@@ -138,6 +173,10 @@ func (s *GCloudStorage) Get(r *http.Request, image string) (imageData *imagor.Bl
 
 // Put implements imagor.Storage interface
 func (s *GCloudStorage) Put(ctx context.Context, image string, blob *imagor.Blob) (err error) {
+	if _, generation, _ := s.splitVersion(image); generation != 0 {
+		// object generations are immutable
+		return imagor.ErrInvalid
+	}
 	bucket, image, ok := s.resolveRequest(ctx, image)
 	if !ok {
 		return imagor.ErrInvalid
@@ -146,8 +185,7 @@ func (s *GCloudStorage) Put(ctx context.Context, image string, blob *imagor.Blob
 	if err != nil {
 		return err
 	}
-	objectHandle := s.client.Bucket(bucket).Object(image)
-	writer := objectHandle.NewWriter(ctx)
+	writer := s.object(bucket, image, 0).NewWriter(ctx)
 	defer func() {
 		_ = reader.Close()
 		_ = writer.Close()
@@ -155,6 +193,9 @@ func (s *GCloudStorage) Put(ctx context.Context, image string, blob *imagor.Blob
 	if s.ACL != "" {
 		writer.PredefinedACL = s.ACL
 	}
+	if s.KMSKeyName != "" && len(s.EncryptionKey) == 0 {
+		writer.KMSKeyName = s.KMSKeyName
+	}
 	writer.ContentType = blob.ContentType()
 	if _, err = io.Copy(writer, reader); err != nil {
 		return err
@@ -162,8 +203,24 @@ func (s *GCloudStorage) Put(ctx context.Context, image string, blob *imagor.Blob
 	return
 }
 
+// object returns object handle of generation if non-zero,
+// with customer-supplied encryption key if set
+func (s *GCloudStorage) object(bucket, key string, generation int64) *storage.ObjectHandle {
+	object := s.client.Bucket(bucket).Object(key)
+	if generation > 0 {
+		object = object.Generation(generation)
+	}
+	if len(s.EncryptionKey) > 0 {
+		object = object.Key(s.EncryptionKey)
+	}
+	return object
+}
+
 // Delete implements imagor.Storage interface
 func (s *GCloudStorage) Delete(ctx context.Context, image string) error {
+	if _, generation, _ := s.splitVersion(image); generation != 0 {
+		return imagor.ErrInvalid
+	}
 	bucket, image, ok := s.resolveRequest(ctx, image)
 	if !ok {
 		return imagor.ErrInvalid
@@ -185,11 +242,15 @@ func (s *GCloudStorage) Path(image string) (string, bool) {
 
 // Stat implements imagor.Storage interface
 func (s *GCloudStorage) Stat(ctx context.Context, image string) (stat *imagor.Stat, err error) {
+	image, generation, ok := s.splitVersion(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
 	bucket, image, ok := s.resolveRequest(ctx, image)
 	if !ok {
 		return nil, imagor.ErrInvalid
 	}
-	object := s.client.Bucket(bucket).Object(image)
+	object := s.object(bucket, image, generation)
 	attrs, err := object.Attrs(ctx)
 	if err != nil {
 		if errors.Is(err, storage.ErrObjectNotExist) {
@@ -203,3 +264,61 @@ func (s *GCloudStorage) Stat(ctx context.Context, image string) (stat *imagor.St
 		ModifiedTime: attrs.Updated,
 	}, nil
 }
+
+// List implements imagor.Lister interface, listing objects under BaseDir of Bucket
+func (s *GCloudStorage) List(ctx context.Context, fn func(key string, stat *imagor.Stat) error) error {
+	if s.Bucket == "*" {
+		return errListWildcardBucket
+	}
+	prefix := strings.Trim(s.BaseDir, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	it := s.client.Bucket(s.Bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(attrs.Name, prefix)
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			// directory placeholder
+			continue
+		}
+		key := strings.TrimPrefix(path.Join(s.PathPrefix, rel), "/")
+		if err := fn(imagorpath.Denormalize(key, s.safeChars), &imagor.Stat{
+			Size:         attrs.Size,
+			ETag:         attrs.Etag,
+			ModifiedTime: attrs.Updated,
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+// Presign implements imagor.Presigner interface.
+// It returns URL under PublicURL if set, otherwise a V4 signed URL,
+// which requires credentials capable of signing
+func (s *GCloudStorage) Presign(ctx context.Context, image string, expires time.Duration) (string, error) {
+	bucket, image, ok := s.resolveRequest(ctx, image)
+	if !ok {
+		return "", imagor.ErrInvalid
+	}
+	if s.PublicURL == "" && len(s.EncryptionKey) > 0 {
+		return "", errEncryptionKeyPresign
+	}
+	if s.PublicURL != "" {
+		if s.Bucket == "*" {
+			image = bucket + "/" + image
+		}
+		return s.PublicURL + (&url.URL{Path: "/" + image}).EscapedPath(), nil
+	}
+	return s.client.Bucket(bucket).SignedURL(image, &storage.SignedURLOptions{
+		Method:  http.MethodGet,
+		Expires: time.Now().Add(expires),
+		Scheme:  storage.SigningSchemeV4,
+	})
+}
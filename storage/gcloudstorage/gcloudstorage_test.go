@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/base64"
+	"errors"
 	"net/http"
+	"strconv"
 	"testing"
 	"time"
 
@@ -523,3 +526,121 @@ func TestGCloudStorage_GzipContentEncoding(t *testing.T) {
 	// The key fix is that content is fully readable despite size mismatch
 	// (the fanout optimization is disabled internally for gzip content)
 }
+
+func TestPresign(t *testing.T) {
+	_, client := fakeGCSServer(t, "test")
+	ctx := context.Background()
+	var _ imagor.Presigner = New(client, "test")
+
+	s := New(client, "test", WithBaseDir("images"), WithPathPrefix("/foo"),
+		WithPublicURL("https://cdn.example.com/"))
+	_, err := s.Presign(ctx, "/bar", time.Minute)
+	assert.Equal(t, imagor.ErrInvalid, err)
+	u, err := s.Presign(ctx, "/foo/b{ar", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/images/b%257Bar", u)
+
+	s = New(client, "*", WithPublicURL("https://cdn.example.com"))
+	u, err = s.Presign(ctx, "/test/bar", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/test/bar", u)
+}
+
+func TestEncryption(t *testing.T) {
+	_, client := fakeGCSServer(t, "test")
+	ctx := context.Background()
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+	s := New(client, "test", WithKMSKeyName("projects/p/locations/global/keyRings/r/cryptoKeys/k"))
+	assert.Equal(t, "projects/p/locations/global/keyRings/r/cryptoKeys/k", s.KMSKeyName)
+
+	s = New(client, "test", WithEncryptionKey(key))
+	assert.Equal(t, []byte("0123456789abcdef0123456789abcdef"), s.EncryptionKey)
+	require.NoError(t, s.Put(ctx, "/foo", imagor.NewBlobFromBytes([]byte("bar"))))
+	b, err := s.Get((&http.Request{}).WithContext(ctx), "/foo")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(buf))
+	_, err = s.Presign(ctx, "/foo", time.Minute)
+	assert.Error(t, err)
+
+	s = New(client, "test", WithEncryptionKey(key), WithPublicURL("https://cdn.example.com"))
+	u, err := s.Presign(ctx, "/foo", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/foo", u)
+
+	// invalid key ignored
+	s = New(client, "test", WithEncryptionKey("Zm9v"))
+	assert.Empty(t, s.EncryptionKey)
+}
+
+func TestVersioning(t *testing.T) {
+	srv, client := fakeGCSServer(t)
+	srv.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: "test", VersioningEnabled: true})
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+
+	s := New(client, "test", WithVersioning(true))
+	require.NoError(t, s.Put(ctx, "/foo.jpg", imagor.NewBlobFromBytes([]byte("v1"))))
+	attrs, err := client.Bucket("test").Object("foo.jpg").Attrs(ctx)
+	require.NoError(t, err)
+	first := strconv.FormatInt(attrs.Generation, 10)
+	require.NoError(t, s.Put(ctx, "/foo.jpg", imagor.NewBlobFromBytes([]byte("v2"))))
+
+	get := func(s *GCloudStorage, image string) string {
+		b, err := s.Get(r, image)
+		require.NoError(t, err)
+		buf, err := b.ReadAll()
+		require.NoError(t, err)
+		return string(buf)
+	}
+	assert.Equal(t, "v2", get(s, "/foo.jpg"))
+	assert.Equal(t, "v1", get(s, "/foo.jpg?versionId="+first))
+	stat, err := s.Stat(ctx, "/foo.jpg?versionId="+first)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stat.Size)
+
+	_, err = s.Get(r, "/foo.jpg?versionId=abc")
+	assert.Equal(t, imagor.ErrInvalid, err)
+	_, err = s.Stat(ctx, "/foo.jpg?versionId=abc")
+	assert.Equal(t, imagor.ErrInvalid, err)
+	assert.Equal(t, imagor.ErrInvalid, s.Put(ctx, "/foo.jpg?versionId="+first, imagor.NewBlobFromBytes([]byte("v3"))))
+	assert.Equal(t, imagor.ErrInvalid, s.Delete(ctx, "/foo.jpg?versionId="+first))
+	assert.Equal(t, "v2", get(s, "/foo.jpg"))
+}
+
+func TestList(t *testing.T) {
+	_, client := fakeGCSServer(t, "test")
+	ctx := context.Background()
+	s := New(client, "test", WithBaseDir("base"), WithPathPrefix("/foo"))
+	other := New(client, "test", WithBaseDir("other"))
+	keys := []string{"foo/abc.gif", "foo/bar/baz.jpg", "foo/filters:fill(white)/qux .png"}
+	for _, key := range keys {
+		require.NoError(t, s.Put(ctx, key, imagor.NewBlobFromBytes([]byte(key))))
+	}
+	require.NoError(t, other.Put(ctx, "other.jpg", imagor.NewBlobFromBytes([]byte("other"))))
+
+	listed := map[string]*imagor.Stat{}
+	require.NoError(t, s.List(ctx, func(key string, stat *imagor.Stat) error {
+		listed[key] = stat
+		return nil
+	}))
+	assert.Len(t, listed, len(keys))
+	for _, key := range keys {
+		require.NotNil(t, listed[key], key)
+		assert.Equal(t, int64(len(key)), listed[key].Size)
+		assert.False(t, listed[key].ModifiedTime.IsZero())
+		stat, err := s.Stat(ctx, key)
+		require.NoError(t, err, key)
+		assert.Equal(t, stat.Size, listed[key].Size)
+	}
+
+	errStop := errors.New("stop")
+	assert.Equal(t, errStop, s.List(ctx, func(string, *imagor.Stat) error {
+		return errStop
+	}))
+	assert.Error(t, New(client, "*").List(ctx, func(string, *imagor.Stat) error {
+		return nil
+	}))
+}
@@ -1,6 +1,8 @@
 package gcloudstorage
 
 import (
+	"encoding/base64"
+	"errors"
 	"strings"
 	"time"
 )
@@ -56,3 +58,52 @@ func WithExpiration(exp time.Duration) Option {
 		}
 	}
 }
+
+// WithPublicURL with public base URL option, e.g. CDN in front of the bucket,
+// used by Presign in place of signed URL
+func WithPublicURL(publicURL string) Option {
+	return func(s *GCloudStorage) {
+		if publicURL != "" {
+			s.PublicURL = strings.TrimSuffix(publicURL, "/")
+		}
+	}
+}
+
+// WithKMSKeyName with Cloud KMS key name option for customer-managed encryption (CMEK),
+// e.g. projects/my-project/locations/global/keyRings/my-ring/cryptoKeys/my-key
+func WithKMSKeyName(name string) Option {
+	return func(s *GCloudStorage) {
+		if name != "" {
+			s.KMSKeyName = name
+		}
+	}
+}
+
+// WithEncryptionKey with base64 encoded AES-256 customer-supplied encryption key option (CSEK).
+// Invalid keys are ignored, see ValidateEncryptionKey
+func WithEncryptionKey(key string) Option {
+	return func(s *GCloudStorage) {
+		if buf, err := base64.StdEncoding.DecodeString(key); err == nil && len(buf) == 32 {
+			s.EncryptionKey = buf
+		}
+	}
+}
+
+// ValidateEncryptionKey returns error if key is not empty and not a base64 encoded AES-256 key
+func ValidateEncryptionKey(key string) error {
+	if key == "" {
+		return nil
+	}
+	if buf, err := base64.StdEncoding.DecodeString(key); err != nil || len(buf) != 32 {
+		return errors.New("gcloudstorage: encryption key is not base64 encoded 256-bit key")
+	}
+	return nil
+}
+
+// WithVersioning with versioning option, reading the object generation
+// of image key version selector e.g. image.jpg?versionId=1700000000000000
+func WithVersioning(versioning bool) Option {
+	return func(s *GCloudStorage) {
+		s.Versioning = versioning
+	}
+}
@@ -0,0 +1,435 @@
+package mirrorstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor"
+	"go.uber.org/zap"
+)
+
+// replica read health of a mirrored storage
+type replica struct {
+	mu             sync.Mutex
+	latency        time.Duration
+	unhealthyUntil time.Time
+}
+
+// MirrorStorage Mirror Storage implements imagor.Storage interface.
+// It writes blobs to all storages, and reads from the fastest healthy one.
+// A blob missing from some storages is copied from the storage that has it (read-repair),
+// and keys read are verified in background by comparing Stat of all storages,
+// from app startup until shutdown
+type MirrorStorage struct {
+	Storages []imagor.Storage
+	// Timeout of asynchronous repairs and verifications
+	Timeout time.Duration
+	// FailureCooldown skips reading from a storage for the duration after it fails
+	FailureCooldown time.Duration
+	// VerifyInterval minimum interval of background verification of the same key.
+	// Set 0 to disable background verification
+	VerifyInterval time.Duration
+	// CompareETag verifies ETags in addition to sizes.
+	// Only applicable for storages with content based ETags, e.g. S3 buckets without KMS encryption
+	CompareETag bool
+	Logger      *zap.Logger
+
+	replicas []*replica
+	inflight sync.Map
+	wg       sync.WaitGroup
+
+	verifyMu sync.Mutex
+	verified map[string]time.Time
+	verifyCh chan string
+
+	runMu  sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates MirrorStorage writing to all storages
+func New(storages []imagor.Storage, options ...Option) *MirrorStorage {
+	s := &MirrorStorage{
+		Storages:        storages,
+		Timeout:         time.Minute,
+		FailureCooldown: time.Second * 30,
+		Logger:          zap.NewNop(),
+		verified:        map[string]time.Time{},
+	}
+	for _, option := range options {
+		option(s)
+	}
+	s.replicas = make([]*replica, len(storages))
+	for i := range storages {
+		s.replicas[i] = &replica{}
+	}
+	if s.VerifyInterval > 0 {
+		s.verifyCh = make(chan string, 1000)
+	}
+	return s
+}
+
+// order returns storage indices with healthy storages first, fastest first
+func (s *MirrorStorage) order() []int {
+	now := time.Now()
+	type state struct {
+		i       int
+		healthy bool
+		latency time.Duration
+	}
+	states := make([]state, len(s.replicas))
+	for i, r := range s.replicas {
+		r.mu.Lock()
+		states[i] = state{i: i, healthy: !now.Before(r.unhealthyUntil), latency: r.latency}
+		r.mu.Unlock()
+	}
+	sort.SliceStable(states, func(a, b int) bool {
+		if states[a].healthy != states[b].healthy {
+			return states[a].healthy
+		}
+		return states[a].latency < states[b].latency
+	})
+	order := make([]int, len(states))
+	for i, st := range states {
+		order[i] = st.i
+	}
+	return order
+}
+
+// observe records read latency and failure of storage i
+func (s *MirrorStorage) observe(i int, latency time.Duration, err error) {
+	r := s.replicas[i]
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil && !isNotFound(err) {
+		r.unhealthyUntil = time.Now().Add(s.FailureCooldown)
+		return
+	}
+	r.unhealthyUntil = time.Time{}
+	if r.latency == 0 {
+		r.latency = latency
+	} else {
+		// exponentially weighted moving average
+		r.latency = (r.latency*4 + latency) / 5
+	}
+}
+
+// Get implements imagor.Storage interface
+func (s *MirrorStorage) Get(r *http.Request, key string) (*imagor.Blob, error) {
+	var err error = imagor.ErrNotFound
+	var missed []int
+	for _, i := range s.order() {
+		start := time.Now()
+		blob, e := s.Storages[i].Get(r, key)
+		if blob != nil && e == nil {
+			e = blob.Err()
+		} else if blob == nil && e == nil {
+			e = imagor.ErrNotFound
+		}
+		s.observe(i, time.Since(start), e)
+		if e == nil {
+			if len(missed) > 0 {
+				s.repair(key, blob, missed)
+			}
+			s.enqueueVerify(key)
+			return blob, nil
+		}
+		if isNotFound(e) {
+			missed = append(missed, i)
+		} else {
+			err = e
+		}
+	}
+	return nil, err
+}
+
+// repair copies blob into storages missing it asynchronously, deduplicated per key
+func (s *MirrorStorage) repair(key string, blob *imagor.Blob, targets []int) {
+	if _, loaded := s.inflight.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.inflight.Delete(key)
+		ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+		defer cancel()
+		for _, i := range targets {
+			if err := s.Storages[i].Put(ctx, key, blob); err != nil {
+				s.Logger.Warn("mirror-repair", zap.String("key", key), zap.Int("storage", i), zap.Error(err))
+			} else {
+				s.Logger.Debug("mirror-repaired", zap.String("key", key), zap.Int("storage", i))
+			}
+		}
+	}()
+}
+
+// Put implements imagor.Storage interface.
+// It writes to all storages concurrently, and fails only if all writes failed.
+// Storages failed to write are repaired on read
+func (s *MirrorStorage) Put(ctx context.Context, key string, blob *imagor.Blob) error {
+	if len(s.Storages) == 0 {
+		return nil
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for _, storage := range s.Storages {
+		wg.Add(1)
+		go func(storage imagor.Storage) {
+			defer wg.Done()
+			if err := storage.Put(ctx, key, blob); err != nil {
+				s.Logger.Warn("mirror-save", zap.String("key", key), zap.Error(err))
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(storage)
+	}
+	wg.Wait()
+	if len(errs) == len(s.Storages) {
+		return errs[0]
+	}
+	return nil
+}
+
+// Delete implements imagor.Storage interface
+func (s *MirrorStorage) Delete(ctx context.Context, key string) (err error) {
+	for _, storage := range s.Storages {
+		if e := storage.Delete(ctx, key); e != nil && err == nil &&
+			!errors.Is(e, os.ErrNotExist) && !isNotFound(e) {
+			err = e
+		}
+	}
+	return
+}
+
+// Stat implements imagor.Storage interface
+func (s *MirrorStorage) Stat(ctx context.Context, key string) (*imagor.Stat, error) {
+	var err error = imagor.ErrNotFound
+	for _, i := range s.order() {
+		stat, e := s.Storages[i].Stat(ctx, key)
+		if stat == nil && e == nil {
+			e = imagor.ErrNotFound
+		}
+		if e == nil {
+			return stat, nil
+		}
+		if !isNotFound(e) {
+			err = e
+		}
+	}
+	return nil, err
+}
+
+// Verify compares Stat of key in all storages, and copies the most recently modified blob
+// into storages that are missing it or differ in size, or ETag if CompareETag enabled
+func (s *MirrorStorage) Verify(ctx context.Context, key string) (repaired int, err error) {
+	stats := make([]*imagor.Stat, len(s.Storages))
+	errs := make([]error, len(s.Storages))
+	var wg sync.WaitGroup
+	for i, storage := range s.Storages {
+		wg.Add(1)
+		go func(i int, storage imagor.Storage) {
+			defer wg.Done()
+			stats[i], errs[i] = storage.Stat(ctx, key)
+			if stats[i] == nil && errs[i] == nil {
+				errs[i] = imagor.ErrNotFound
+			}
+		}(i, storage)
+	}
+	wg.Wait()
+	source := -1
+	for i, stat := range stats {
+		if errs[i] != nil {
+			if !isNotFound(errs[i]) {
+				// cannot tell if the storage is missing the blob
+				return 0, errs[i]
+			}
+			continue
+		}
+		if source == -1 || stat.ModifiedTime.After(stats[source].ModifiedTime) {
+			source = i
+		}
+	}
+	if source == -1 {
+		return 0, imagor.ErrNotFound
+	}
+	var targets []int
+	for i, stat := range stats {
+		if i != source && (errs[i] != nil || !s.equal(stats[source], stat)) {
+			targets = append(targets, i)
+		}
+	}
+	if len(targets) == 0 {
+		return 0, nil
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "", nil)
+	if err != nil {
+		return 0, err
+	}
+	blob, err := s.Storages[source].Get(r, key)
+	if err == nil && blob != nil {
+		err = blob.Err()
+	}
+	if err != nil {
+		return 0, err
+	}
+	for _, i := range targets {
+		if e := s.Storages[i].Put(ctx, key, blob); e != nil {
+			if err == nil {
+				err = fmt.Errorf("mirror storage %d: %w", i, e)
+			}
+			continue
+		}
+		repaired++
+	}
+	return repaired, err
+}
+
+func (s *MirrorStorage) equal(statA, statB *imagor.Stat) bool {
+	if statA.Size != statB.Size {
+		return false
+	}
+	if s.CompareETag && statA.ETag != "" && statB.ETag != "" {
+		return statA.ETag == statB.ETag
+	}
+	return true
+}
+
+// enqueueVerify queues key for background verification,
+// skipped if verifier not running, verified within VerifyInterval or queue is full
+func (s *MirrorStorage) enqueueVerify(key string) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.cancel == nil {
+		return
+	}
+	now := time.Now()
+	s.verifyMu.Lock()
+	if t, ok := s.verified[key]; ok && now.Sub(t) < s.VerifyInterval {
+		s.verifyMu.Unlock()
+		return
+	}
+	if len(s.verified) >= 10000 {
+		// bound memory, expired entries are verified again at most once more
+		s.verified = map[string]time.Time{}
+	}
+	s.verified[key] = now
+	s.verifyMu.Unlock()
+
+	// added before queued, so that Wait covers the verification
+	s.wg.Add(1)
+	select {
+	case s.verifyCh <- key:
+	default:
+		s.wg.Done()
+	}
+}
+
+// runVerifier verifies queued keys until ctx is cancelled,
+// then drops the keys remaining in queue
+func (s *MirrorStorage) runVerifier(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	for {
+		select {
+		case key := <-s.verifyCh:
+			s.verify(ctx, key)
+		case <-ctx.Done():
+			for {
+				select {
+				case <-s.verifyCh:
+					s.wg.Done()
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *MirrorStorage) verify(ctx context.Context, key string) {
+	defer s.wg.Done()
+	if ctx.Err() != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+	if repaired, err := s.Verify(ctx, key); err != nil {
+		s.Logger.Warn("mirror-verify", zap.String("key", key), zap.Error(err))
+	} else if repaired > 0 {
+		s.Logger.Debug("mirror-verified", zap.String("key", key), zap.Int("repaired", repaired))
+	}
+}
+
+// Wait waits for pending asynchronous repairs and verifications
+func (s *MirrorStorage) Wait() {
+	s.wg.Wait()
+}
+
+// Startup implements imagor.Lifecycle interface,
+// starting storages implementing it and the background verifier if VerifyInterval set
+func (s *MirrorStorage) Startup(ctx context.Context) error {
+	for _, storage := range s.Storages {
+		if lc, ok := storage.(imagor.Lifecycle); ok {
+			if err := lc.Startup(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.verifyCh == nil || s.cancel != nil {
+		return nil
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.runVerifier(runCtx, s.done)
+	return nil
+}
+
+// Shutdown implements imagor.Lifecycle interface, stopping the background verifier,
+// waiting for pending repairs, then stopping storages implementing it
+func (s *MirrorStorage) Shutdown(ctx context.Context) error {
+	s.runMu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.runMu.Unlock()
+	if cancel != nil {
+		cancel()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	waited := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for _, storage := range s.Storages {
+		if lc, ok := storage.(imagor.Lifecycle); ok {
+			if err := lc.Shutdown(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, imagor.ErrNotFound) || errors.Is(err, os.ErrNotExist)
+}
@@ -0,0 +1,265 @@
+package mirrorstorage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+type failingStorage struct {
+	imagor.Storage
+	fail atomic.Bool
+	gets atomic.Int32
+}
+
+var errUnavailable = errors.New("unavailable")
+
+func (s *failingStorage) Get(r *http.Request, key string) (*imagor.Blob, error) {
+	s.gets.Add(1)
+	if s.fail.Load() {
+		return nil, errUnavailable
+	}
+	return s.Storage.Get(r, key)
+}
+
+func (s *failingStorage) Put(ctx context.Context, key string, blob *imagor.Blob) error {
+	if s.fail.Load() {
+		return errUnavailable
+	}
+	return s.Storage.Put(ctx, key, blob)
+}
+
+func (s *failingStorage) Stat(ctx context.Context, key string) (*imagor.Stat, error) {
+	if s.fail.Load() {
+		return nil, errUnavailable
+	}
+	return s.Storage.Stat(ctx, key)
+}
+
+func TestPutGetAndReadRepair(t *testing.T) {
+	a := filestorage.New(t.TempDir())
+	b := filestorage.New(t.TempDir())
+	s := New([]imagor.Storage{a, b})
+	r := (&http.Request{}).WithContext(ctx)
+
+	_, err := s.Get(r, "foo/bar")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	_, err = s.Stat(ctx, "foo/bar")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	require.NoError(t, s.Put(ctx, "foo/bar", imagor.NewBlobFromBytes([]byte("bar"))))
+	_, err = a.Stat(ctx, "foo/bar")
+	assert.NoError(t, err)
+	_, err = b.Stat(ctx, "foo/bar")
+	assert.NoError(t, err)
+	stat, err := s.Stat(ctx, "foo/bar")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stat.Size)
+
+	// miss in the first replica read repaired from the other
+	for _, replicas := range [][]imagor.Storage{{a, b}, {b, a}} {
+		m := New(replicas)
+		require.NoError(t, replicas[0].Delete(ctx, "foo/bar"))
+		blob, err := m.Get(r, "foo/bar")
+		require.NoError(t, err)
+		buf, err := blob.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, "bar", string(buf))
+		m.Wait()
+		blob, err = replicas[0].Get(r, "foo/bar")
+		require.NoError(t, err)
+		buf, err = blob.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, "bar", string(buf))
+	}
+
+	require.NoError(t, s.Delete(ctx, "foo/bar"))
+	_, err = a.Stat(ctx, "foo/bar")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	_, err = b.Stat(ctx, "foo/bar")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	require.NoError(t, s.Delete(ctx, "foo/bar"))
+}
+
+func TestFailover(t *testing.T) {
+	a := &failingStorage{Storage: filestorage.New(t.TempDir())}
+	b := filestorage.New(t.TempDir())
+	s := New([]imagor.Storage{a, b}, WithFailureCooldown(time.Hour))
+	r := (&http.Request{}).WithContext(ctx)
+
+	// write succeeds with any replica available
+	a.fail.Store(true)
+	require.NoError(t, s.Put(ctx, "foo", imagor.NewBlobFromBytes([]byte("foo"))))
+	blob, err := s.Get(r, "foo")
+	require.NoError(t, err)
+	buf, err := blob.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(buf))
+	// failed replica skipped during cooldown
+	gets := a.gets.Load()
+	_, err = s.Get(r, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, gets, a.gets.Load())
+
+	// error of unavailable replica returned over not found
+	require.NoError(t, b.Delete(ctx, "foo"))
+	_, err = s.Get(r, "foo")
+	assert.ErrorIs(t, err, errUnavailable)
+
+	// write fails with all replicas unavailable
+	s2 := New([]imagor.Storage{a})
+	assert.ErrorIs(t, s2.Put(ctx, "foo", imagor.NewBlobFromBytes([]byte("foo"))), errUnavailable)
+}
+
+func TestFastestReplica(t *testing.T) {
+	a := filestorage.New(t.TempDir())
+	b := filestorage.New(t.TempDir())
+	s := New([]imagor.Storage{a, b})
+	s.observe(0, time.Second, nil)
+	s.observe(1, time.Millisecond, nil)
+	assert.Equal(t, []int{1, 0}, s.order())
+	s.observe(1, time.Millisecond, errUnavailable)
+	assert.Equal(t, []int{0, 1}, s.order())
+	s.observe(1, time.Millisecond, imagor.ErrNotFound)
+	assert.Equal(t, []int{1, 0}, s.order())
+}
+
+func TestVerify(t *testing.T) {
+	a := filestorage.New(t.TempDir())
+	b := filestorage.New(t.TempDir())
+	c := filestorage.New(t.TempDir())
+	s := New([]imagor.Storage{a, b, c})
+
+	_, err := s.Verify(ctx, "foo")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	require.NoError(t, s.Put(ctx, "foo", imagor.NewBlobFromBytes([]byte("foo"))))
+	repaired, err := s.Verify(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, 0, repaired)
+
+	// most recently modified copy replaces stale and missing copies
+	time.Sleep(time.Millisecond * 10)
+	require.NoError(t, b.Put(ctx, "foo", imagor.NewBlobFromBytes([]byte("foobar"))))
+	require.NoError(t, c.Delete(ctx, "foo"))
+	repaired, err = s.Verify(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, 2, repaired)
+	for _, replica := range []imagor.Storage{a, c} {
+		blob, err := replica.Get((&http.Request{}).WithContext(ctx), "foo")
+		require.NoError(t, err)
+		buf, err := blob.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, "foobar", string(buf))
+	}
+
+	// unavailable replica fails verification without repair
+	f := &failingStorage{Storage: filestorage.New(t.TempDir())}
+	f.fail.Store(true)
+	s2 := New([]imagor.Storage{a, f})
+	_, err = s2.Verify(ctx, "foo")
+	assert.ErrorIs(t, err, errUnavailable)
+}
+
+func TestBackgroundVerify(t *testing.T) {
+	a := filestorage.New(t.TempDir())
+	b := filestorage.New(t.TempDir())
+	s := New([]imagor.Storage{a, b}, WithVerifyInterval(time.Hour))
+	require.NoError(t, s.Startup(ctx))
+	defer func() {
+		assert.NoError(t, s.Shutdown(ctx))
+	}()
+
+	require.NoError(t, a.Put(ctx, "foo", imagor.NewBlobFromBytes([]byte("foo"))))
+	time.Sleep(time.Millisecond * 10)
+	require.NoError(t, b.Put(ctx, "foo", imagor.NewBlobFromBytes([]byte("foobar"))))
+	s.observe(0, time.Millisecond, nil)
+	s.observe(1, time.Second, nil)
+	blob, err := s.Get((&http.Request{}).WithContext(ctx), "foo")
+	require.NoError(t, err)
+	buf, err := blob.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(buf))
+	assert.Eventually(t, func() bool {
+		buf, err := a.Get((&http.Request{}).WithContext(ctx), "foo")
+		if err != nil {
+			return false
+		}
+		res, _ := buf.ReadAll()
+		return string(res) == "foobar"
+	}, time.Second, time.Millisecond*10)
+}
+
+type lifecycleStorage struct {
+	imagor.Storage
+	events []string
+}
+
+func (s *lifecycleStorage) Startup(context.Context) error {
+	s.events = append(s.events, "startup")
+	return nil
+}
+
+func (s *lifecycleStorage) Shutdown(context.Context) error {
+	s.events = append(s.events, "shutdown")
+	return nil
+}
+
+func TestLifecycle(t *testing.T) {
+	a := &lifecycleStorage{Storage: filestorage.New(t.TempDir())}
+	b := &lifecycleStorage{Storage: filestorage.New(t.TempDir())}
+	s := New([]imagor.Storage{a, b}, WithVerifyInterval(time.Nanosecond))
+	require.NoError(t, a.Put(ctx, "foo", imagor.NewBlobFromBytes([]byte("foo"))))
+	time.Sleep(time.Millisecond * 10)
+	require.NoError(t, b.Put(ctx, "foo", imagor.NewBlobFromBytes([]byte("foobar"))))
+	s.observe(0, time.Millisecond, nil)
+	s.observe(1, time.Second, nil)
+	r := (&http.Request{}).WithContext(ctx)
+
+	// not verified before startup
+	_, err := s.Get(r, "foo")
+	require.NoError(t, err)
+	s.Wait()
+	blob, err := a.Get(r, "foo")
+	require.NoError(t, err)
+	buf, err := blob.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(buf))
+
+	require.NoError(t, s.Startup(ctx))
+	require.NoError(t, s.Startup(ctx))
+	_, err = s.Get(r, "foo")
+	require.NoError(t, err)
+	s.Wait()
+	blob, err = a.Get(r, "foo")
+	require.NoError(t, err)
+	buf, err = blob.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "foobar", string(buf))
+
+	require.NoError(t, s.Shutdown(ctx))
+	require.NoError(t, s.Shutdown(ctx))
+	assert.Equal(t, []string{"startup", "startup", "shutdown", "shutdown"}, a.events)
+	assert.Equal(t, a.events, b.events)
+
+	// not verified after shutdown
+	require.NoError(t, a.Put(ctx, "foo", imagor.NewBlobFromBytes([]byte("foo"))))
+	_, err = s.Get(r, "foo")
+	require.NoError(t, err)
+	s.Wait()
+	blob, err = b.Get(r, "foo")
+	require.NoError(t, err)
+	buf, err = blob.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "foobar", string(buf))
+}
@@ -0,0 +1,54 @@
+package mirrorstorage
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Option MirrorStorage option
+type Option func(s *MirrorStorage)
+
+// WithTimeout with timeout of asynchronous repairs and verifications option
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *MirrorStorage) {
+		if timeout > 0 {
+			s.Timeout = timeout
+		}
+	}
+}
+
+// WithFailureCooldown with duration a failed storage is skipped for reading option
+func WithFailureCooldown(cooldown time.Duration) Option {
+	return func(s *MirrorStorage) {
+		if cooldown >= 0 {
+			s.FailureCooldown = cooldown
+		}
+	}
+}
+
+// WithVerifyInterval with minimum interval of background verification of the same key option.
+// Set 0 to disable background verification
+func WithVerifyInterval(interval time.Duration) Option {
+	return func(s *MirrorStorage) {
+		if interval >= 0 {
+			s.VerifyInterval = interval
+		}
+	}
+}
+
+// WithCompareETag with ETag verification in addition to sizes option
+func WithCompareETag(compareETag bool) Option {
+	return func(s *MirrorStorage) {
+		s.CompareETag = compareETag
+	}
+}
+
+// WithLogger with logger option
+func WithLogger(logger *zap.Logger) Option {
+	return func(s *MirrorStorage) {
+		if logger != nil {
+			s.Logger = logger
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package redisstorage
+
+import (
+	"time"
+)
+
+// Option RedisStorage option
+type Option func(h *RedisStorage)
+
+// WithKeyPrefix with Redis key prefix option
+func WithKeyPrefix(prefix string) Option {
+	return func(s *RedisStorage) {
+		if prefix != "" {
+			s.KeyPrefix = prefix
+		}
+	}
+}
+
+// WithSafeChars with safe chars option
+func WithSafeChars(chars string) Option {
+	return func(s *RedisStorage) {
+		if chars != "" {
+			s.SafeChars = chars
+		}
+	}
+}
+
+// WithExpiration with TTL expiration option
+func WithExpiration(exp time.Duration) Option {
+	return func(s *RedisStorage) {
+		if exp > 0 {
+			s.Expiration = exp
+		}
+	}
+}
+
+// WithMaxSize with maximum blob size option in bytes.
+// Blobs larger than the limit are not stored
+func WithMaxSize(size int64) Option {
+	return func(s *RedisStorage) {
+		if size > 0 {
+			s.MaxSize = size
+		}
+	}
+}
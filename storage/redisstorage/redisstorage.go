@@ -0,0 +1,173 @@
+package redisstorage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	fieldData         = "data"
+	fieldContentType  = "content_type"
+	fieldETag         = "etag"
+	fieldModifiedTime = "modified_time"
+)
+
+// RedisStorage Redis Storage implements imagor.Storage interface.
+// Blobs are stored as Redis hashes alongside their Stat,
+// intended for small blobs such as thumbnails and avatars
+type RedisStorage struct {
+	Client     redis.UniversalClient
+	KeyPrefix  string
+	SafeChars  string
+	Expiration time.Duration
+	MaxSize    int64
+
+	safeChars imagorpath.SafeChars
+}
+
+// New creates RedisStorage
+func New(client redis.UniversalClient, options ...Option) *RedisStorage {
+	s := &RedisStorage{
+		Client:    client,
+		KeyPrefix: "imagor:",
+		MaxSize:   1 << 20,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.SafeChars == "--" {
+		s.safeChars = imagorpath.NewNoopSafeChars()
+	} else {
+		s.safeChars = imagorpath.NewSafeChars(s.SafeChars)
+	}
+	return s
+}
+
+// Path transforms and validates image key for Redis key
+func (s *RedisStorage) Path(image string) (string, bool) {
+	image = imagorpath.Normalize(image, s.safeChars)
+	if image == "" || image == "." {
+		return "", false
+	}
+	return s.KeyPrefix + image, true
+}
+
+// Get implements imagor.Storage interface
+func (s *RedisStorage) Get(r *http.Request, image string) (*imagor.Blob, error) {
+	key, ok := s.Path(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	res, err := s.Client.HGetAll(r.Context(), key).Result()
+	if err != nil {
+		return nil, err
+	}
+	data, ok := res[fieldData]
+	if !ok {
+		return nil, imagor.ErrNotFound
+	}
+	blob := imagor.NewBlobFromBytes([]byte(data))
+	if contentType := res[fieldContentType]; contentType != "" {
+		blob.SetContentType(contentType)
+	}
+	blob.Stat = newStat(res[fieldETag], res[fieldModifiedTime], int64(len(data)))
+	return blob, nil
+}
+
+// Put implements imagor.Storage interface.
+// Blobs exceeding MaxSize are skipped without error,
+// leaving them to the other storages
+func (s *RedisStorage) Put(ctx context.Context, image string, blob *imagor.Blob) error {
+	key, ok := s.Path(image)
+	if !ok {
+		return imagor.ErrInvalid
+	}
+	if s.MaxSize > 0 && blob.Size() > s.MaxSize {
+		return nil
+	}
+	reader, _, err := blob.NewReader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	var r io.Reader = reader
+	if s.MaxSize > 0 {
+		r = io.LimitReader(reader, s.MaxSize+1)
+	}
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if s.MaxSize > 0 && int64(len(buf)) > s.MaxSize {
+		// size unknown upfront and turns out too large
+		return nil
+	}
+	sum := md5.Sum(buf)
+	_, err = s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key,
+			fieldData, buf,
+			fieldContentType, blob.ContentType(),
+			fieldETag, `"`+hex.EncodeToString(sum[:])+`"`,
+			fieldModifiedTime, strconv.FormatInt(time.Now().UnixMilli(), 10),
+		)
+		if s.Expiration > 0 {
+			pipe.PExpire(ctx, key, s.Expiration)
+		}
+		return nil
+	})
+	return err
+}
+
+// Delete implements imagor.Storage interface
+func (s *RedisStorage) Delete(ctx context.Context, image string) error {
+	key, ok := s.Path(image)
+	if !ok {
+		return imagor.ErrInvalid
+	}
+	return s.Client.Del(ctx, key).Err()
+}
+
+// Stat implements imagor.Storage interface
+func (s *RedisStorage) Stat(ctx context.Context, image string) (*imagor.Stat, error) {
+	key, ok := s.Path(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	pipe := s.Client.Pipeline()
+	fields := pipe.HMGet(ctx, key, fieldETag, fieldModifiedTime)
+	size := pipe.HStrLen(ctx, key, fieldData)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	vals := fields.Val()
+	if len(vals) != 2 || vals[1] == nil {
+		return nil, imagor.ErrNotFound
+	}
+	etag, _ := vals[0].(string)
+	modTime, _ := vals[1].(string)
+	return newStat(etag, modTime, size.Val()), nil
+}
+
+func newStat(etag, modTime string, size int64) *imagor.Stat {
+	stat := &imagor.Stat{
+		ETag: etag,
+		Size: size,
+	}
+	if ms, err := strconv.ParseInt(modTime, 10, 64); err == nil {
+		stat.ModifiedTime = time.UnixMilli(ms)
+	}
+	return stat
+}
@@ -0,0 +1,119 @@
+package redisstorage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cshum/imagor"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeRedis(t *testing.T) (*miniredis.Miniredis, redis.UniversalClient) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return mr, client
+}
+
+func TestRedisStorage_Path(t *testing.T) {
+	s := New(nil)
+	key, ok := s.Path("/foo/b{:}ar")
+	assert.True(t, ok)
+	assert.Equal(t, "imagor:foo/b%7B%3A%7Dar", key)
+
+	s = New(nil, WithKeyPrefix("res:"), WithSafeChars("{}"))
+	key, ok = s.Path("foo/b{:}ar")
+	assert.True(t, ok)
+	assert.Equal(t, "res:foo/b{%3A}ar", key)
+
+	_, ok = s.Path("/")
+	assert.False(t, ok)
+}
+
+func TestCRUD(t *testing.T) {
+	_, client := fakeRedis(t)
+
+	var err error
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+	s := New(client)
+
+	_, err = s.Get(r, "/")
+	assert.Equal(t, imagor.ErrInvalid, err)
+	assert.Equal(t, imagor.ErrInvalid, s.Put(ctx, "", imagor.NewBlobFromBytes([]byte("bar"))))
+
+	_, err = s.Get(r, "/foo/fooo/asdf")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	_, err = s.Stat(ctx, "/foo/fooo/asdf")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	blob := imagor.NewBlobFromBytes([]byte("bar"))
+	blob.SetContentType("image/png")
+	require.NoError(t, s.Put(ctx, "/foo/fooo/asdf", blob))
+
+	stat, err := s.Stat(ctx, "/foo/fooo/asdf")
+	require.NoError(t, err)
+	assert.False(t, stat.ModifiedTime.After(time.Now()))
+	assert.NotEmpty(t, stat.ETag)
+	assert.Equal(t, int64(3), stat.Size)
+
+	b, err := s.Get(r, "/foo/fooo/asdf")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(buf))
+	assert.Equal(t, "image/png", b.ContentType())
+	assert.Equal(t, stat, b.Stat)
+
+	require.NoError(t, s.Delete(ctx, "/foo/fooo/asdf"))
+
+	_, err = s.Get(r, "/foo/fooo/asdf")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
+
+func TestExpiration(t *testing.T) {
+	mr, client := fakeRedis(t)
+
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+	s := New(client, WithExpiration(time.Minute))
+
+	require.NoError(t, s.Put(ctx, "/foo/bar", imagor.NewBlobFromBytes([]byte("bar"))))
+	assert.Equal(t, time.Minute, mr.TTL("imagor:foo/bar"))
+
+	_, err := s.Get(r, "/foo/bar")
+	require.NoError(t, err)
+
+	mr.FastForward(time.Minute + time.Second)
+	_, err = s.Get(r, "/foo/bar")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
+
+func TestMaxSize(t *testing.T) {
+	mr, client := fakeRedis(t)
+
+	ctx := context.Background()
+	s := New(client, WithMaxSize(4))
+
+	require.NoError(t, s.Put(ctx, "/small", imagor.NewBlobFromBytes([]byte("abcd"))))
+	assert.True(t, mr.Exists("imagor:small"))
+
+	require.NoError(t, s.Put(ctx, "/large", imagor.NewBlobFromBytes([]byte("abcde"))))
+	assert.False(t, mr.Exists("imagor:large"))
+
+	// unknown size blob is capped while reading
+	require.NoError(t, s.Put(ctx, "/stream", imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+		return io.NopCloser(bytes.NewReader([]byte("abcdefgh"))), 0, nil
+	})))
+	assert.False(t, mr.Exists("imagor:stream"))
+}
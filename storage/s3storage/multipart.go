@@ -0,0 +1,182 @@
+package s3storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	minPartSize = 5 << 20
+	maxParts    = 10000
+)
+
+var errTooManyParts = errors.New("s3storage: multipart upload exceeds 10000 parts")
+
+// putMultipart streams reader in multipart upload, with parts uploaded concurrently.
+// Blob that fits in a single part is uploaded with PutObject instead.
+// Incomplete upload is aborted on error or context cancel
+func (s *S3Storage) putMultipart(
+	ctx context.Context, bucket, key, contentType string, reader io.Reader, size int64,
+) (err error) {
+	partSize := max(s.MultipartPartSize, minPartSize)
+	if size > partSize*maxParts {
+		partSize = (size + maxParts - 1) / maxParts
+	}
+	buf, err := readPart(reader, partSize)
+	n := len(buf)
+	if errors.Is(err, io.EOF) {
+		return s.putObject(ctx, bucket, key, contentType, bytes.NewReader(buf), int64(n))
+	} else if err != nil {
+		return err
+	}
+
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:            aws.String(bucket),
+		Key:               aws.String(key),
+		ContentType:       aws.String(contentType),
+		StorageClass:      types.StorageClass(s.StorageClass),
+		ChecksumAlgorithm: types.ChecksumAlgorithmCrc32,
+	}
+	if s.ACL != "" {
+		input.ACL = types.ObjectCannedACL(s.ACL)
+	}
+	if s.Tagging != "" {
+		input.Tagging = aws.String(s.Tagging)
+	}
+	if s.SSECustomerKey != "" {
+		input.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		input.SSECustomerKey = aws.String(s.SSECustomerKey)
+		input.SSECustomerKeyMD5 = aws.String(s.sseCustomerKeyMD5)
+	} else if s.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(s.ServerSideEncryption)
+		if s.SSEKMSKeyID != "" {
+			input.SSEKMSKeyId = aws.String(s.SSEKMSKeyID)
+		}
+		if s.SSEKMSEncryptionContext != "" {
+			input.SSEKMSEncryptionContext = aws.String(s.SSEKMSEncryptionContext)
+		}
+	}
+	upload, err := s.Client.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			// abort regardless of request cancellation, so that no parts are left behind
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+			defer cancel()
+			_, _ = s.Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(bucket),
+				Key:      aws.String(key),
+				UploadId: upload.UploadId,
+			})
+		}
+	}()
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(s.MultipartConcurrency, 1))
+	var mu sync.Mutex
+	var parts []types.CompletedPart
+	var readErr error
+	for num := int32(1); n > 0; num++ {
+		if num > maxParts {
+			readErr = errTooManyParts
+			break
+		}
+		body, partNumber := buf[:n], num
+		g.Go(func() error {
+			input := &s3.UploadPartInput{
+				Bucket:            aws.String(bucket),
+				Key:               aws.String(key),
+				UploadId:          upload.UploadId,
+				PartNumber:        aws.Int32(partNumber),
+				Body:              bytes.NewReader(body),
+				ContentLength:     aws.Int64(int64(len(body))),
+				ChecksumAlgorithm: types.ChecksumAlgorithmCrc32,
+			}
+			if s.SSECustomerKey != "" {
+				input.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+				input.SSECustomerKey = aws.String(s.SSECustomerKey)
+				input.SSECustomerKeyMD5 = aws.String(s.sseCustomerKeyMD5)
+			}
+			out, err := s.Client.UploadPart(gctx, input)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			parts = append(parts, types.CompletedPart{
+				ETag:          out.ETag,
+				ChecksumCRC32: out.ChecksumCRC32,
+				PartNumber:    aws.Int32(partNumber),
+			})
+			mu.Unlock()
+			return nil
+		})
+		// buffer of each in-flight part is owned by its upload
+		buf = make([]byte, partSize)
+		if n, readErr = io.ReadFull(reader, buf); errors.Is(readErr, io.EOF) ||
+			errors.Is(readErr, io.ErrUnexpectedEOF) {
+			readErr = nil
+		} else if readErr != nil {
+			break
+		}
+		if gctx.Err() != nil {
+			break
+		}
+	}
+	if err = g.Wait(); err != nil {
+		return err
+	}
+	if readErr != nil {
+		return readErr
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return *parts[i].PartNumber < *parts[j].PartNumber
+	})
+	complete := &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	}
+	if s.SSECustomerKey != "" {
+		complete.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		complete.SSECustomerKey = aws.String(s.SSECustomerKey)
+		complete.SSECustomerKeyMD5 = aws.String(s.sseCustomerKeyMD5)
+	}
+	_, err = s.Client.CompleteMultipartUpload(ctx, complete)
+	return err
+}
+
+// readPart reads up to partSize bytes of reader into a buffer grown on demand,
+// so that blob of unknown size smaller than a part does not allocate a full part.
+// It returns io.EOF if reader ends before partSize
+func readPart(reader io.Reader, partSize int64) ([]byte, error) {
+	buf := make([]byte, 0, min(partSize, 64<<10))
+	for int64(len(buf)) < partSize {
+		if len(buf) == cap(buf) {
+			grown := make([]byte, len(buf), min(int64(cap(buf))*2, partSize))
+			copy(grown, buf)
+			buf = grown
+		}
+		n, err := reader.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if err != nil {
+			return buf, err
+		}
+	}
+	return buf, nil
+}
@@ -1,6 +1,10 @@
 package s3storage
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/url"
 	"strings"
 	"time"
@@ -116,3 +120,127 @@ func WithForcePathStyle(forcePathStyle bool) Option {
 		s.ForcePathStyle = forcePathStyle
 	}
 }
+
+// WithPublicURL with public base URL option, e.g. CDN in front of the bucket,
+// used by Presign in place of presigned URL
+func WithPublicURL(publicURL string) Option {
+	return func(s *S3Storage) {
+		if publicURL != "" {
+			s.PublicURL = strings.TrimSuffix(publicURL, "/")
+		}
+	}
+}
+
+var sseValuesMap = (func() map[string]bool {
+	m := map[string]bool{}
+	for _, sse := range types.ServerSideEncryption("").Values() {
+		m[string(sse)] = true
+	}
+	return m
+})()
+
+// WithServerSideEncryption with server-side encryption option of stored objects,
+// e.g. AES256 for SSE-S3, aws:kms for SSE-KMS. Invalid values are ignored, see ValidateServerSideEncryption
+func WithServerSideEncryption(sse string) Option {
+	return func(h *S3Storage) {
+		if sse != "" && ValidateServerSideEncryption(sse) == nil {
+			h.ServerSideEncryption = sse
+		}
+	}
+}
+
+// WithSSEKMSKeyID with SSE-KMS key ID option
+func WithSSEKMSKeyID(keyID string) Option {
+	return func(h *S3Storage) {
+		h.SSEKMSKeyID = keyID
+	}
+}
+
+// WithSSEKMSEncryptionContext with SSE-KMS encryption context option, as JSON object
+// e.g. {"tenant":"foo"}. Invalid values are ignored, see ValidateSSEKMSEncryptionContext
+func WithSSEKMSEncryptionContext(encryptionContext string) Option {
+	return func(h *S3Storage) {
+		if encryptionContext != "" && ValidateSSEKMSEncryptionContext(encryptionContext) == nil {
+			h.SSEKMSEncryptionContext = base64.StdEncoding.EncodeToString([]byte(encryptionContext))
+		}
+	}
+}
+
+// WithSSECustomerKey with SSE-C customer-provided 256-bit key option, base64 encoded.
+// The key is required for reads as well as writes. Invalid keys are ignored, see ValidateSSECustomerKey
+func WithSSECustomerKey(key string) Option {
+	return func(h *S3Storage) {
+		if key != "" && ValidateSSECustomerKey(key) == nil {
+			h.SSECustomerKey = key
+		}
+	}
+}
+
+// ValidateServerSideEncryption returns error if sse is not empty and not a server-side encryption of S3
+func ValidateServerSideEncryption(sse string) error {
+	if sse != "" && !sseValuesMap[sse] {
+		return fmt.Errorf("s3storage: unknown server-side encryption %q", sse)
+	}
+	return nil
+}
+
+// ValidateSSEKMSEncryptionContext returns error if encryption context is not empty
+// and not a JSON object of string values
+func ValidateSSEKMSEncryptionContext(encryptionContext string) error {
+	if encryptionContext == "" {
+		return nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal([]byte(encryptionContext), &m); err != nil {
+		return fmt.Errorf("s3storage: encryption context is not JSON object of string values: %w", err)
+	}
+	return nil
+}
+
+// ValidateSSECustomerKey returns error if key is not empty and not a base64 encoded 256-bit key
+func ValidateSSECustomerKey(key string) error {
+	if key == "" {
+		return nil
+	}
+	if buf, err := base64.StdEncoding.DecodeString(key); err != nil || len(buf) != 32 {
+		return errors.New("s3storage: customer key is not base64 encoded 256-bit key")
+	}
+	return nil
+}
+
+// WithVersioning with versioning option, reading the object version
+// of image key version selector e.g. image.jpg?versionId=abc
+func WithVersioning(versioning bool) Option {
+	return func(h *S3Storage) {
+		h.Versioning = versioning
+	}
+}
+
+// WithMultipartThreshold with multipart upload threshold option in bytes,
+// uploading blobs larger than threshold or of unknown size in multipart upload.
+// Set 0 to disable multipart upload
+func WithMultipartThreshold(threshold int64) Option {
+	return func(h *S3Storage) {
+		if threshold >= 0 {
+			h.MultipartThreshold = threshold
+		}
+	}
+}
+
+// WithMultipartPartSize with multipart upload part size option in bytes, minimum 5MiB
+func WithMultipartPartSize(size int64) Option {
+	return func(h *S3Storage) {
+		if size > 0 {
+			h.MultipartPartSize = size
+		}
+	}
+}
+
+// WithMultipartConcurrency with number of parts uploaded concurrently option
+func WithMultipartConcurrency(concurrency int) Option {
+	return func(h *S3Storage) {
+		if concurrency > 0 {
+			h.MultipartConcurrency = concurrency
+		}
+	}
+}
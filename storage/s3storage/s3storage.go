@@ -2,9 +2,13 @@ package s3storage
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/base64"
 	"errors"
 	"io"
 	"net/http"
+	"net/url"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -18,6 +22,12 @@ import (
 	"github.com/cshum/imagor/imagorpath"
 )
 
+const sseCustomerAlgorithm = "AES256"
+
+var errSSECustomerKeyPresign = errors.New("s3storage: presign not supported with SSE-C")
+
+var errListWildcardBucket = errors.New("s3storage: list not supported with wildcard bucket")
+
 // S3Storage AWS S3 Storage implements imagor.Storage interface
 type S3Storage struct {
 	Client *s3.Client
@@ -32,8 +42,29 @@ type S3Storage struct {
 	Expiration     time.Duration
 	Endpoint       string
 	ForcePathStyle bool
+	PublicURL      string
+
+	// ServerSideEncryption SSE-S3 or SSE-KMS algorithm e.g. AES256, aws:kms
+	ServerSideEncryption    string
+	SSEKMSKeyID             string
+	SSEKMSEncryptionContext string
+	// SSECustomerKey SSE-C customer-provided key, base64 encoded
+	SSECustomerKey string
 
-	safeChars imagorpath.SafeChars
+	// MultipartThreshold uploads blobs larger than threshold, or of unknown size,
+	// in multipart upload. Set 0 to disable multipart upload
+	MultipartThreshold int64
+	// MultipartPartSize part size of multipart upload, minimum 5MiB
+	MultipartPartSize int64
+	// MultipartConcurrency number of parts uploaded concurrently
+	MultipartConcurrency int
+
+	// Versioning reads the object version of image key version selector
+	// e.g. image.jpg?versionId=abc
+	Versioning bool
+
+	safeChars         imagorpath.SafeChars
+	sseCustomerKeyMD5 string
 }
 
 // New creates S3Storage
@@ -51,6 +82,10 @@ func New(cfg aws.Config, bucket string, options ...Option) *S3Storage {
 
 		BaseDir:    baseDir,
 		PathPrefix: "/",
+
+		MultipartThreshold:   64 << 20,
+		MultipartPartSize:    16 << 20,
+		MultipartConcurrency: 4,
 	}
 	for _, option := range options {
 		option(s)
@@ -78,6 +113,13 @@ func New(cfg aws.Config, bucket string, options ...Option) *S3Storage {
 		// https://docs.aws.amazon.com/AmazonS3/latest/userguide/object-keys.html#object-key-guidelines-safe-characters
 	}
 
+	if s.SSECustomerKey != "" {
+		if key, err := base64.StdEncoding.DecodeString(s.SSECustomerKey); err == nil {
+			sum := md5.Sum(key)
+			s.sseCustomerKeyMD5 = base64.StdEncoding.EncodeToString(sum[:])
+		}
+	}
+
 	return s
 }
 
@@ -148,9 +190,18 @@ func (s *S3Storage) Path(image string) (string, bool) {
 	return result, true
 }
 
+// splitVersion splits version selector from image key if Versioning enabled
+func (s *S3Storage) splitVersion(image string) (string, string) {
+	if !s.Versioning {
+		return image, ""
+	}
+	return imagorpath.SplitVersion(image)
+}
+
 // Get implements imagor.Storage interface
 func (s *S3Storage) Get(r *http.Request, image string) (*imagor.Blob, error) {
 	ctx := r.Context()
+	image, versionID := s.splitVersion(image)
 	bucket, image, ok := s.resolveRequest(ctx, image)
 	if !ok {
 		return nil, imagor.ErrInvalid
@@ -162,6 +213,14 @@ func (s *S3Storage) Get(r *http.Request, image string) (*imagor.Blob, error) {
 			Bucket: aws.String(bucket),
 			Key:    aws.String(image),
 		}
+		if versionID != "" {
+			input.VersionId = aws.String(versionID)
+		}
+		if s.SSECustomerKey != "" {
+			input.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+			input.SSECustomerKey = aws.String(s.SSECustomerKey)
+			input.SSECustomerKeyMD5 = aws.String(s.sseCustomerKeyMD5)
+		}
 		out, err := s.Client.GetObject(ctx, input)
 		if err != nil {
 			if isNotFoundError(err) {
@@ -197,6 +256,10 @@ func (s *S3Storage) Get(r *http.Request, image string) (*imagor.Blob, error) {
 
 // Put implements imagor.Storage interface
 func (s *S3Storage) Put(ctx context.Context, image string, blob *imagor.Blob) error {
+	if _, versionID := s.splitVersion(image); versionID != "" {
+		// object versions are immutable
+		return imagor.ErrInvalid
+	}
 	bucket, image, ok := s.resolveRequest(ctx, image)
 	if !ok {
 		return imagor.ErrInvalid
@@ -208,13 +271,22 @@ func (s *S3Storage) Put(ctx context.Context, image string, blob *imagor.Blob) er
 	defer func() {
 		_ = reader.Close()
 	}()
+	// size 0 of non-empty blob is unknown size
+	if s.MultipartThreshold > 0 && (size > s.MultipartThreshold || size <= 0 && !blob.IsEmpty()) {
+		return s.putMultipart(ctx, bucket, image, blob.ContentType(), reader, size)
+	}
+	return s.putObject(ctx, bucket, image, blob.ContentType(), reader, size)
+}
 
+func (s *S3Storage) putObject(
+	ctx context.Context, bucket, key, contentType string, body io.Reader, size int64,
+) error {
 	input := &s3.PutObjectInput{
-		Body:          reader,
+		Body:          body,
 		Bucket:        aws.String(bucket),
-		ContentType:   aws.String(blob.ContentType()),
+		ContentType:   aws.String(contentType),
 		ContentLength: aws.Int64(size),
-		Key:           aws.String(image),
+		Key:           aws.String(key),
 		StorageClass:  types.StorageClass(s.StorageClass),
 	}
 	if s.ACL != "" {
@@ -223,12 +295,28 @@ func (s *S3Storage) Put(ctx context.Context, image string, blob *imagor.Blob) er
 	if s.Tagging != "" {
 		input.Tagging = aws.String(s.Tagging)
 	}
-	_, err = s.Client.PutObject(ctx, input)
+	if s.SSECustomerKey != "" {
+		input.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		input.SSECustomerKey = aws.String(s.SSECustomerKey)
+		input.SSECustomerKeyMD5 = aws.String(s.sseCustomerKeyMD5)
+	} else if s.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(s.ServerSideEncryption)
+		if s.SSEKMSKeyID != "" {
+			input.SSEKMSKeyId = aws.String(s.SSEKMSKeyID)
+		}
+		if s.SSEKMSEncryptionContext != "" {
+			input.SSEKMSEncryptionContext = aws.String(s.SSEKMSEncryptionContext)
+		}
+	}
+	_, err := s.Client.PutObject(ctx, input)
 	return err
 }
 
 // Delete implements imagor.Storage interface
 func (s *S3Storage) Delete(ctx context.Context, image string) error {
+	if _, versionID := s.splitVersion(image); versionID != "" {
+		return imagor.ErrInvalid
+	}
 	bucket, image, ok := s.resolveRequest(ctx, image)
 	if !ok {
 		return imagor.ErrInvalid
@@ -242,6 +330,7 @@ func (s *S3Storage) Delete(ctx context.Context, image string) error {
 
 // Stat implements imagor.Storage interface
 func (s *S3Storage) Stat(ctx context.Context, image string) (stat *imagor.Stat, err error) {
+	image, versionID := s.splitVersion(image)
 	bucket, image, ok := s.resolveRequest(ctx, image)
 	if !ok {
 		return nil, imagor.ErrInvalid
@@ -250,6 +339,14 @@ func (s *S3Storage) Stat(ctx context.Context, image string) (stat *imagor.Stat,
 		Bucket: aws.String(bucket),
 		Key:    aws.String(image),
 	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	if s.SSECustomerKey != "" {
+		input.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		input.SSECustomerKey = aws.String(s.SSECustomerKey)
+		input.SSECustomerKeyMD5 = aws.String(s.sseCustomerKeyMD5)
+	}
 	head, err := s.Client.HeadObject(ctx, input)
 	if err != nil {
 		if isNotFoundError(err) {
@@ -264,6 +361,70 @@ func (s *S3Storage) Stat(ctx context.Context, image string) (stat *imagor.Stat,
 	}, nil
 }
 
+// List implements imagor.Lister interface, listing objects under BaseDir of Bucket
+func (s *S3Storage) List(ctx context.Context, fn func(key string, stat *imagor.Stat) error) error {
+	if s.Bucket == "*" {
+		return errListWildcardBucket
+	}
+	prefix := strings.Trim(s.BaseDir, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	paginator := s3.NewListObjectsV2Paginator(s.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, obj := range page.Contents {
+			rel := strings.TrimPrefix(aws.ToString(obj.Key), prefix)
+			if rel == "" || strings.HasSuffix(rel, "/") {
+				// directory placeholder
+				continue
+			}
+			key := strings.TrimPrefix(path.Join(s.PathPrefix, rel), "/")
+			if err := fn(imagorpath.Denormalize(key, s.safeChars), &imagor.Stat{
+				Size:         aws.ToInt64(obj.Size),
+				ETag:         aws.ToString(obj.ETag),
+				ModifiedTime: aws.ToTime(obj.LastModified),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Presign implements imagor.Presigner interface.
+// It returns URL under PublicURL if set, otherwise a presigned GetObject URL
+func (s *S3Storage) Presign(ctx context.Context, image string, expires time.Duration) (string, error) {
+	bucket, image, ok := s.resolveRequest(ctx, image)
+	if !ok {
+		return "", imagor.ErrInvalid
+	}
+	if s.PublicURL != "" {
+		if s.Bucket == "*" {
+			image = bucket + "/" + image
+		}
+		return s.PublicURL + (&url.URL{Path: "/" + image}).EscapedPath(), nil
+	}
+	if s.SSECustomerKey != "" {
+		// SSE-C objects require the customer key in request headers
+		return "", errSSECustomerKeyPresign
+	}
+	req, err := s3.NewPresignClient(s.Client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(image),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
 // Helper function for not found errors
 func isNotFoundError(err error) bool {
 	var nsk *types.NoSuchKey
@@ -274,7 +435,7 @@ func isNotFoundError(err error) bool {
 	var ae smithy.APIError
 	if errors.As(err, &ae) {
 		switch ae.ErrorCode() {
-		case "NoSuchKey", "NotFound":
+		case "NoSuchKey", "NoSuchVersion", "NotFound":
 			return true
 		}
 	}
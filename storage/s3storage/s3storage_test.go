@@ -1,17 +1,24 @@
 package s3storage
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
 	"github.com/johannesboyne/gofakes3"
 	"github.com/johannesboyne/gofakes3/backend/s3mem"
 	"github.com/stretchr/testify/assert"
@@ -776,3 +783,370 @@ func TestLocalstackCompatibility(t *testing.T) {
 		})
 	}
 }
+
+func TestPresign(t *testing.T) {
+	ts := fakeS3Server()
+	defer ts.Close()
+
+	ctx := context.Background()
+	s := New(fakeS3Config(ts, "test"), "test", WithEndpoint(ts.URL), WithForcePathStyle(true))
+	var _ imagor.Presigner = s
+
+	_, err := New(fakeS3Config(ts, "test2"), "test2", WithPathPrefix("/foo")).Presign(ctx, "/bar", time.Minute)
+	assert.Equal(t, imagor.ErrInvalid, err)
+
+	require.NoError(t, s.Put(ctx, "/foo/b{ar", imagor.NewBlobFromBytes([]byte("bar"))))
+	u, err := s.Presign(ctx, "/foo/b{ar", time.Minute)
+	require.NoError(t, err)
+	assert.Contains(t, u, ts.URL+"/test/foo/b%257Bar?")
+	assert.Contains(t, u, "X-Amz-Expires=60")
+	resp, err := http.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	buf, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(buf))
+
+	s = New(fakeS3Config(ts, "test3"), "*", WithPublicURL("https://cdn.example.com/"))
+	u, err = s.Presign(ctx, "/test3/foo/b{ar", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/test3/foo/b%257Bar", u)
+}
+
+func TestServerSideEncryption(t *testing.T) {
+	var mu sync.Mutex
+	headers := map[string]http.Header{}
+	faker := gofakes3.New(s3mem.New()).Server()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers[r.Method] = r.Header.Clone()
+		mu.Unlock()
+		faker.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+
+	s := New(fakeS3Config(ts, "test"), "test", WithEndpoint(ts.URL), WithForcePathStyle(true),
+		WithServerSideEncryption("aws:kms"),
+		WithSSEKMSKeyID("my-key"),
+		WithSSEKMSEncryptionContext(`{"tenant":"foo"}`))
+	assert.Equal(t, "aws:kms", s.ServerSideEncryption)
+	require.NoError(t, s.Put(ctx, "/foo", imagor.NewBlobFromBytes([]byte("bar"))))
+	h := headers[http.MethodPut]
+	assert.Equal(t, "aws:kms", h.Get("X-Amz-Server-Side-Encryption"))
+	assert.Equal(t, "my-key", h.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+	assert.Equal(t, "eyJ0ZW5hbnQiOiJmb28ifQ==", h.Get("X-Amz-Server-Side-Encryption-Context"))
+
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	s = New(fakeS3Config(ts, "test2"), "test2", WithEndpoint(ts.URL), WithForcePathStyle(true),
+		WithServerSideEncryption("AES256"), WithSSECustomerKey(key))
+	require.NoError(t, s.Put(ctx, "/foo", imagor.NewBlobFromBytes([]byte("bar"))))
+	_, err := s.Stat(ctx, "/foo")
+	require.NoError(t, err)
+	b, err := s.Get(r, "/foo")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(buf))
+	for _, method := range []string{http.MethodPut, http.MethodHead, http.MethodGet} {
+		h := headers[method]
+		assert.Equal(t, "AES256", h.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm"), method)
+		assert.Equal(t, key, h.Get("X-Amz-Server-Side-Encryption-Customer-Key"), method)
+		assert.Equal(t, "hRasmdxgYDKV3nvbahU1MA==", h.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5"), method)
+		assert.Empty(t, h.Get("X-Amz-Server-Side-Encryption"), method)
+	}
+	_, err = s.Presign(ctx, "/foo", time.Minute)
+	assert.Error(t, err)
+
+	// invalid options ignored
+	s = New(fakeS3Config(ts, "test3"), "test3",
+		WithServerSideEncryption("foo"),
+		WithSSEKMSEncryptionContext("foo"),
+		WithSSECustomerKey("Zm9v"))
+	assert.Empty(t, s.ServerSideEncryption)
+	assert.Empty(t, s.SSEKMSEncryptionContext)
+	assert.Empty(t, s.SSECustomerKey)
+}
+
+func TestVersioning(t *testing.T) {
+	ts := fakeS3Server()
+	defer ts.Close()
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+
+	s := New(fakeS3Config(ts, "test"), "test", WithEndpoint(ts.URL), WithForcePathStyle(true), WithVersioning(true))
+	_, err := s.Client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String("test"),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusEnabled,
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Put(ctx, "/foo.jpg", imagor.NewBlobFromBytes([]byte("v1"))))
+	require.NoError(t, s.Put(ctx, "/foo.jpg", imagor.NewBlobFromBytes([]byte("v2"))))
+	versions, err := s.Client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{
+		Bucket: aws.String("test"),
+	})
+	require.NoError(t, err)
+	require.Len(t, versions.Versions, 2)
+	var first string
+	for _, v := range versions.Versions {
+		if !*v.IsLatest {
+			first = *v.VersionId
+		}
+	}
+	require.NotEmpty(t, first)
+
+	get := func(s *S3Storage, image string) string {
+		b, err := s.Get(r, image)
+		require.NoError(t, err)
+		buf, err := b.ReadAll()
+		require.NoError(t, err)
+		return string(buf)
+	}
+	assert.Equal(t, "v2", get(s, "/foo.jpg"))
+	assert.Equal(t, "v1", get(s, "/foo.jpg?versionId="+first))
+	stat, err := s.Stat(ctx, "/foo.jpg?versionId="+first)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stat.Size)
+
+	assert.Equal(t, imagor.ErrInvalid, s.Put(ctx, "/foo.jpg?versionId="+first, imagor.NewBlobFromBytes([]byte("v3"))))
+	assert.Equal(t, imagor.ErrInvalid, s.Delete(ctx, "/foo.jpg?versionId="+first))
+	assert.Equal(t, "v2", get(s, "/foo.jpg"))
+
+	// version selector is part of the key without versioning
+	s = New(fakeS3Config(ts, "test2"), "test2", WithEndpoint(ts.URL), WithForcePathStyle(true))
+	require.NoError(t, s.Put(ctx, "/foo.jpg?versionId="+first, imagor.NewBlobFromBytes([]byte("bar"))))
+	assert.Equal(t, "bar", get(s, "/foo.jpg?versionId="+first))
+	_, err = s.Stat(ctx, "/foo.jpg")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
+
+func TestVersioningLoaderWithStorage(t *testing.T) {
+	// TLS, so that the loader stream is uploaded with unsigned payload
+	ts := httptest.NewTLSServer(gofakes3.New(s3mem.New()).Server())
+	defer ts.Close()
+	ctx := context.Background()
+
+	cfg := aws.Config{
+		Region:      "eu-central-1",
+		Credentials: credentials.NewStaticCredentialsProvider("YOUR-ACCESSKEYID", "YOUR-SECRETACCESSKEY", ""),
+		HTTPClient:  ts.Client(),
+	}
+	loader := New(cfg, "test", WithEndpoint(ts.URL), WithForcePathStyle(true), WithVersioning(true))
+	storage := New(cfg, "test2", WithEndpoint(ts.URL), WithForcePathStyle(true))
+	_, err := storage.Client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("test2")})
+	require.NoError(t, err)
+	_, err = loader.Client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("test")})
+	require.NoError(t, err)
+	_, err = loader.Client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String("test"),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusEnabled,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, loader.Put(ctx, "/foo.jpg", imagor.NewBlobFromBytes([]byte("v1"))))
+	require.NoError(t, loader.Put(ctx, "/foo.jpg", imagor.NewBlobFromBytes([]byte("v2"))))
+	versions, err := loader.Client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{
+		Bucket: aws.String("test"),
+	})
+	require.NoError(t, err)
+	var first string
+	for _, v := range versions.Versions {
+		if !*v.IsLatest {
+			first = *v.VersionId
+		}
+	}
+	require.NotEmpty(t, first)
+
+	app := imagor.New(
+		imagor.WithLoaders(loader),
+		imagor.WithStorages(storage),
+		imagor.WithUnsafe(true),
+	)
+	image := "foo.jpg?versionId=" + first
+	r := (&http.Request{}).WithContext(ctx)
+	blob, err := app.Do(r, imagorpath.Params{Unsafe: true, Image: image})
+	require.NoError(t, err)
+	buf, err := blob.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "v1", string(buf))
+
+	// saved by storage with version selector as part of the key
+	assert.Eventually(t, func() bool {
+		b, err := storage.Get(r, image)
+		if err != nil {
+			return false
+		}
+		buf, err := b.ReadAll()
+		return err == nil && string(buf) == "v1"
+	}, time.Second, 10*time.Millisecond)
+	_, err = storage.Stat(ctx, "foo.jpg")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
+
+type failingReader struct {
+	reader io.Reader
+	n      int
+	fail   func() error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.n <= 0 {
+		return 0, r.fail()
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	n, err := r.reader.Read(p)
+	r.n -= n
+	return n, err
+}
+
+func TestMultipartUpload(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	faker := gofakes3.New(s3mem.New()).Server()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		mu.Lock()
+		switch {
+		case r.Method == http.MethodPost && q.Has("uploads"):
+			requests = append(requests, "create")
+		case r.Method == http.MethodPut && q.Has("partNumber"):
+			requests = append(requests, "part")
+		case r.Method == http.MethodPost && q.Has("uploadId"):
+			requests = append(requests, "complete")
+		case r.Method == http.MethodDelete && q.Has("uploadId"):
+			requests = append(requests, "abort")
+		case r.Method == http.MethodPut:
+			requests = append(requests, "put")
+		}
+		mu.Unlock()
+		faker.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	reset := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		res := requests
+		requests = nil
+		return res
+	}
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+	s := New(fakeS3Config(ts, "test"), "test", WithEndpoint(ts.URL), WithForcePathStyle(true),
+		WithMultipartThreshold(1<<20), WithMultipartPartSize(1), WithMultipartConcurrency(2))
+	assert.Equal(t, int64(1<<20), s.MultipartThreshold)
+	assert.Equal(t, int64(1), s.MultipartPartSize)
+	assert.Equal(t, 2, s.MultipartConcurrency)
+	reset()
+
+	buf := make([]byte, minPartSize*2+100)
+	_, _ = rand.Read(buf)
+	require.NoError(t, s.Put(ctx, "/large", imagor.NewBlobFromBytes(buf)))
+	assert.Equal(t, []string{"create", "part", "part", "part", "complete"}, reset())
+	b, err := s.Get(r, "/large")
+	require.NoError(t, err)
+	res, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(buf, res))
+
+	// below threshold
+	require.NoError(t, s.Put(ctx, "/small", imagor.NewBlobFromBytes([]byte("foo"))))
+	assert.Equal(t, []string{"put"}, reset())
+
+	// unknown size within a single part
+	require.NoError(t, s.Put(ctx, "/unknown", imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+		return io.NopCloser(bytes.NewReader([]byte("bar"))), 0, nil
+	})))
+	assert.Equal(t, []string{"put"}, reset())
+	b, err = s.Get(r, "/unknown")
+	require.NoError(t, err)
+	res, err = b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(res))
+	part, err := readPart(bytes.NewReader([]byte("bar")), 16<<20)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "bar", string(part))
+	assert.Less(t, cap(part), 1<<20, "buffer not allocated for a full part")
+	part, err = readPart(bytes.NewReader(buf), minPartSize)
+	assert.NoError(t, err)
+	assert.Equal(t, buf[:minPartSize], part)
+
+	// empty blob
+	require.NoError(t, s.Put(ctx, "/empty", imagor.NewBlobFromBytes(nil)))
+	assert.Equal(t, []string{"put"}, reset())
+
+	// incomplete upload aborted on read error
+	assert.Error(t, s.Put(ctx, "/failed", imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+		return io.NopCloser(&failingReader{reader: bytes.NewReader(buf), n: minPartSize + 10, fail: func() error {
+			return errors.New("read error")
+		}}), 0, nil
+	})))
+	assert.Equal(t, "abort", reset()[2])
+
+	// incomplete upload aborted on context cancel
+	cancelCtx, cancel := context.WithCancel(ctx)
+	assert.Error(t, s.Put(cancelCtx, "/cancelled", imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+		return io.NopCloser(&failingReader{reader: bytes.NewReader(buf), n: minPartSize + 10, fail: func() error {
+			cancel()
+			return context.Canceled
+		}}), 0, nil
+	})))
+	assert.Contains(t, reset(), "abort")
+	_, err = s.Stat(ctx, "/cancelled")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	uploads, err := s.Client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{Bucket: aws.String("test")})
+	require.NoError(t, err)
+	assert.Empty(t, uploads.Uploads)
+
+	// multipart disabled
+	s = New(fakeS3Config(ts, "test2"), "test2", WithEndpoint(ts.URL), WithForcePathStyle(true),
+		WithMultipartThreshold(0))
+	reset()
+	require.NoError(t, s.Put(ctx, "/large", imagor.NewBlobFromBytes(buf)))
+	assert.Equal(t, []string{"put"}, reset())
+}
+
+func TestList(t *testing.T) {
+	ts := fakeS3Server()
+	defer ts.Close()
+
+	ctx := context.Background()
+	cfg := fakeS3Config(ts, "test")
+	s := New(cfg, "test/base", WithPathPrefix("/foo"), WithEndpoint(ts.URL), WithForcePathStyle(true))
+	other := New(cfg, "test/other", WithEndpoint(ts.URL), WithForcePathStyle(true))
+	keys := []string{"foo/abc.gif", "foo/bar/baz.jpg", "foo/filters:fill(white)/qux .png"}
+	for _, key := range keys {
+		require.NoError(t, s.Put(ctx, key, imagor.NewBlobFromBytes([]byte(key))))
+	}
+	require.NoError(t, other.Put(ctx, "other.jpg", imagor.NewBlobFromBytes([]byte("other"))))
+
+	listed := map[string]*imagor.Stat{}
+	require.NoError(t, s.List(ctx, func(key string, stat *imagor.Stat) error {
+		listed[key] = stat
+		return nil
+	}))
+	assert.Len(t, listed, len(keys))
+	for _, key := range keys {
+		require.NotNil(t, listed[key], key)
+		assert.Equal(t, int64(len(key)), listed[key].Size)
+		assert.NotEmpty(t, listed[key].ETag)
+		assert.False(t, listed[key].ModifiedTime.IsZero())
+		stat, err := s.Stat(ctx, key)
+		require.NoError(t, err, key)
+		assert.Equal(t, stat.Size, listed[key].Size)
+	}
+
+	errStop := errors.New("stop")
+	assert.Equal(t, errStop, s.List(ctx, func(string, *imagor.Stat) error {
+		return errStop
+	}))
+	assert.Error(t, New(cfg, "*").List(ctx, func(string, *imagor.Stat) error {
+		return nil
+	}))
+}
@@ -0,0 +1,81 @@
+package sftpstorage
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Option SFTPStorage option
+type Option func(s *SFTPStorage)
+
+// WithBaseDir with base directory option
+func WithBaseDir(baseDir string) Option {
+	return func(s *SFTPStorage) {
+		if baseDir != "" {
+			s.BaseDir = baseDir
+		}
+	}
+}
+
+// WithPathPrefix with path prefix option
+func WithPathPrefix(prefix string) Option {
+	return func(s *SFTPStorage) {
+		if prefix != "" {
+			prefix = "/" + strings.Trim(prefix, "/")
+			if prefix != "/" {
+				prefix += "/"
+			}
+			s.PathPrefix = prefix
+		}
+	}
+}
+
+// WithBlacklist with regexp path blacklist option
+func WithBlacklist(blacklist *regexp.Regexp) Option {
+	return func(s *SFTPStorage) {
+		if blacklist != nil {
+			s.Blacklists = append(s.Blacklists, blacklist)
+		}
+	}
+}
+
+// WithWritePermission with write permission option
+func WithWritePermission(perm string) Option {
+	return func(s *SFTPStorage) {
+		if perm != "" {
+			if fm, err := strconv.ParseUint(perm, 0, 32); err == nil {
+				s.WritePermission = os.FileMode(fm)
+			}
+		}
+	}
+}
+
+// WithSafeChars with safe chars option
+func WithSafeChars(chars string) Option {
+	return func(s *SFTPStorage) {
+		if chars != "" {
+			s.SafeChars = chars
+		}
+	}
+}
+
+// WithExpiration with modified time expiration option
+func WithExpiration(exp time.Duration) Option {
+	return func(s *SFTPStorage) {
+		if exp > 0 {
+			s.Expiration = exp
+		}
+	}
+}
+
+// WithPoolSize with maximum number of concurrent SFTP connections option
+func WithPoolSize(size int) Option {
+	return func(s *SFTPStorage) {
+		if size > 0 {
+			s.PoolSize = size
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package sftpstorage
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/cshum/imagor"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+type conn struct {
+	ssh  *ssh.Client
+	sftp *sftp.Client
+}
+
+func (c *conn) close() {
+	_ = c.sftp.Close()
+	_ = c.ssh.Close()
+}
+
+// pool keeps idle SFTP connections for reuse,
+// and caps the number of connections in use with a semaphore
+type pool struct {
+	addr   string
+	config *ssh.ClientConfig
+	sema   chan struct{}
+
+	mu   sync.Mutex
+	idle []*conn
+}
+
+func newPool(addr string, config *ssh.ClientConfig, size int) *pool {
+	if size <= 0 {
+		size = 1
+	}
+	return &pool{
+		addr:   addr,
+		config: config,
+		sema:   make(chan struct{}, size),
+	}
+}
+
+func (p *pool) dial(ctx context.Context) (*conn, error) {
+	d := net.Dialer{Timeout: p.config.Timeout}
+	netConn, err := d.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(netConn, p.addr, p.config)
+	if err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	sshClient := ssh.NewClient(c, chans, reqs)
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		_ = sshClient.Close()
+		return nil, err
+	}
+	return &conn{ssh: sshClient, sftp: sftpClient}, nil
+}
+
+// acquire takes an idle connection or dials a new one,
+// blocks when pool size reached until a connection is released
+func (p *pool) acquire(ctx context.Context) (*conn, error) {
+	select {
+	case p.sema <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		c := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return c, nil
+	}
+	p.mu.Unlock()
+	c, err := p.dial(ctx)
+	if err != nil {
+		<-p.sema
+		return nil, err
+	}
+	return c, nil
+}
+
+// release returns connection to the pool,
+// or discards it if err indicates the connection is broken
+func (p *pool) release(c *conn, err error) {
+	if isBrokenConn(err) {
+		c.close()
+	} else {
+		p.mu.Lock()
+		p.idle = append(p.idle, c)
+		p.mu.Unlock()
+	}
+	<-p.sema
+}
+
+// close closes all idle connections
+func (p *pool) close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+	for _, c := range idle {
+		c.close()
+	}
+}
+
+func isBrokenConn(err error) bool {
+	if err == nil ||
+		errors.Is(err, os.ErrNotExist) ||
+		errors.Is(err, os.ErrPermission) ||
+		errors.Is(err, os.ErrExist) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusErr *sftp.StatusError
+	if errors.As(err, &statusErr) {
+		return false
+	}
+	if _, ok := err.(imagor.Error); ok {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,221 @@
+package sftpstorage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"golang.org/x/crypto/ssh"
+)
+
+var dotFileRegex = regexp.MustCompile("/\\.")
+
+// SFTPStorage SFTP Storage implements imagor.Storage interface
+type SFTPStorage struct {
+	Addr            string
+	BaseDir         string
+	PathPrefix      string
+	Blacklists      []*regexp.Regexp
+	WritePermission os.FileMode
+	SafeChars       string
+	Expiration      time.Duration
+	PoolSize        int
+
+	safeChars imagorpath.SafeChars
+	pool      *pool
+}
+
+// New creates SFTPStorage with SSH address and client config.
+// Connections are dialed lazily and reused across requests.
+// Without base dir, paths are relative to the login directory
+func New(addr string, config *ssh.ClientConfig, options ...Option) *SFTPStorage {
+	s := &SFTPStorage{
+		Addr:            addr,
+		PathPrefix:      "/",
+		Blacklists:      []*regexp.Regexp{dotFileRegex},
+		WritePermission: 0644,
+		PoolSize:        4,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.SafeChars == "--" {
+		s.safeChars = imagorpath.NewNoopSafeChars()
+	} else {
+		s.safeChars = imagorpath.NewSafeChars(s.SafeChars)
+	}
+	s.pool = newPool(addr, config, s.PoolSize)
+	return s
+}
+
+// Path transforms and validates image key for storage path
+func (s *SFTPStorage) Path(image string) (string, bool) {
+	image = "/" + imagorpath.Normalize(image, s.safeChars)
+	for _, blacklist := range s.Blacklists {
+		if blacklist.MatchString(image) {
+			return "", false
+		}
+	}
+	if !strings.HasPrefix(image, s.PathPrefix) {
+		return "", false
+	}
+	return path.Join(s.BaseDir, strings.TrimPrefix(image, s.PathPrefix)), true
+}
+
+// Get implements imagor.Storage interface
+func (s *SFTPStorage) Get(r *http.Request, image string) (*imagor.Blob, error) {
+	ctx := r.Context()
+	image, ok := s.Path(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	stat, err := s.stat(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	if s.Expiration > 0 && time.Since(stat.ModifiedTime) > s.Expiration {
+		return nil, imagor.ErrExpired
+	}
+	blob := imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+		c, err := s.pool.acquire(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		f, err := c.sftp.Open(image)
+		if err != nil {
+			s.pool.release(c, err)
+			if os.IsNotExist(err) {
+				return nil, 0, imagor.ErrNotFound
+			}
+			return nil, 0, err
+		}
+		return &fileReader{ReadCloser: f, release: func(err error) {
+			s.pool.release(c, err)
+		}}, stat.Size, nil
+	})
+	blob.Stat = stat
+	return blob, nil
+}
+
+// Put implements imagor.Storage interface
+func (s *SFTPStorage) Put(ctx context.Context, image string, blob *imagor.Blob) (err error) {
+	image, ok := s.Path(image)
+	if !ok {
+		return imagor.ErrInvalid
+	}
+	reader, _, err := blob.NewReader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	c, err := s.pool.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		s.pool.release(c, err)
+	}()
+	if err = c.sftp.MkdirAll(path.Dir(image)); err != nil {
+		return
+	}
+	w, err := c.sftp.OpenFile(image, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = w.Close()
+		if err != nil {
+			_ = c.sftp.Remove(image)
+		}
+	}()
+	if err = w.Chmod(s.WritePermission); err != nil {
+		return
+	}
+	_, err = io.Copy(w, reader)
+	return
+}
+
+// Delete implements imagor.Storage interface
+func (s *SFTPStorage) Delete(ctx context.Context, image string) (err error) {
+	image, ok := s.Path(image)
+	if !ok {
+		return imagor.ErrInvalid
+	}
+	c, err := s.pool.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		s.pool.release(c, err)
+	}()
+	return c.sftp.Remove(image)
+}
+
+// Stat implements imagor.Storage interface
+func (s *SFTPStorage) Stat(ctx context.Context, image string) (*imagor.Stat, error) {
+	image, ok := s.Path(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	return s.stat(ctx, image)
+}
+
+func (s *SFTPStorage) stat(ctx context.Context, image string) (stat *imagor.Stat, err error) {
+	c, err := s.pool.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		s.pool.release(c, err)
+	}()
+	info, err := c.sftp.Stat(image)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, imagor.ErrNotFound
+		}
+		return nil, err
+	}
+	return &imagor.Stat{
+		Size:         info.Size(),
+		ModifiedTime: info.ModTime(),
+	}, nil
+}
+
+// Close closes idle SFTP connections
+func (s *SFTPStorage) Close() error {
+	s.pool.close()
+	return nil
+}
+
+// fileReader releases the connection back to pool on close
+type fileReader struct {
+	io.ReadCloser
+	release func(err error)
+	err     error
+}
+
+func (r *fileReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return
+}
+
+func (r *fileReader) Close() error {
+	err := r.ReadCloser.Close()
+	if r.err == nil {
+		r.err = err
+	}
+	r.release(r.err)
+	return err
+}
@@ -0,0 +1,294 @@
+package sftpstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func newSigner(t *testing.T) ssh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	return signer
+}
+
+// fakeSFTPServer serves an in-memory SFTP file system,
+// accepting public key auth of the client signer only
+func fakeSFTPServer(t *testing.T, clientSigner ssh.Signer) (string, *ssh.ClientConfig) {
+	hostSigner := newSigner(t)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), clientSigner.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	config.AddHostKey(hostSigner)
+	handlers := sftp.InMemHandler()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		_ = ln.Close()
+		wg.Wait()
+	})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			nConn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(nConn, config, handlers)
+		}
+	}()
+	return ln.Addr().String(), &ssh.ClientConfig{
+		User:            "user",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(clientSigner)},
+		HostKeyCallback: ssh.FixedHostKey(hostSigner.PublicKey()),
+		Timeout:         time.Second * 5,
+	}
+}
+
+func serveSFTP(nConn net.Conn, config *ssh.ServerConfig, handlers sftp.Handlers) {
+	_, chans, reqs, err := ssh.NewServerConn(nConn, config)
+	if err != nil {
+		_ = nConn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func(in <-chan *ssh.Request) {
+			for req := range in {
+				_ = req.Reply(req.Type == "subsystem" && string(req.Payload[4:]) == "sftp", nil)
+			}
+		}(requests)
+		go func() {
+			server := sftp.NewRequestServer(channel, handlers)
+			_ = server.Serve()
+			_ = server.Close()
+		}()
+	}
+}
+
+func TestSFTPStorage_Path(t *testing.T) {
+	tests := []struct {
+		name       string
+		baseDir    string
+		baseURI    string
+		image      string
+		safeChars  string
+		expected   string
+		expectedOk bool
+	}{
+		{
+			name:       "defaults ok",
+			image:      "/foo/bar",
+			expected:   "foo/bar",
+			expectedOk: true,
+		},
+		{
+			name:       "base dir",
+			baseDir:    "/home/imagor",
+			image:      "/foo/bar",
+			expected:   "/home/imagor/foo/bar",
+			expectedOk: true,
+		},
+		{
+			name:       "escape unsafe chars",
+			baseDir:    "/home/imagor",
+			image:      "/foo/b{:}ar",
+			expected:   "/home/imagor/foo/b%7B%3A%7Dar",
+			expectedOk: true,
+		},
+		{
+			name:       "escape safe chars",
+			baseDir:    "/home/imagor",
+			image:      "/foo/b{:}ar",
+			safeChars:  "{}",
+			expected:   "/home/imagor/foo/b{%3A}ar",
+			expectedOk: true,
+		},
+		{
+			name:       "path under with base uri",
+			baseDir:    "/home/imagor",
+			baseURI:    "/foo",
+			image:      "/foo/bar",
+			expected:   "/home/imagor/bar",
+			expectedOk: true,
+		},
+		{
+			name:       "path not under",
+			baseDir:    "/home/imagor",
+			baseURI:    "/foo",
+			image:      "/fooo/bar",
+			expectedOk: false,
+		},
+		{
+			name:       "path under must not escalate",
+			baseDir:    "/home/imagor",
+			image:      "/../../etc/passwd",
+			expected:   "/home/imagor/etc/passwd",
+			expectedOk: true,
+		},
+		{
+			name:       "dot file",
+			baseDir:    "/home/imagor",
+			image:      "/foo/.ssh/authorized_keys",
+			expectedOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New("localhost:22", &ssh.ClientConfig{},
+				WithBaseDir(tt.baseDir), WithPathPrefix(tt.baseURI), WithSafeChars(tt.safeChars))
+			res, ok := s.Path(tt.image)
+			if res != tt.expected || ok != tt.expectedOk {
+				t.Errorf(" = %s,%v want %s,%v", res, ok, tt.expected, tt.expectedOk)
+			}
+		})
+	}
+}
+
+func TestCRUD(t *testing.T) {
+	addr, config := fakeSFTPServer(t, newSigner(t))
+
+	var err error
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+	s := New(addr, config, WithBaseDir("/data"), WithPathPrefix("/foo"))
+	defer s.Close()
+
+	_, err = s.Get(r, "/bar/fooo/asdf")
+	assert.Equal(t, imagor.ErrInvalid, err)
+
+	_, err = s.Stat(ctx, "/bar/fooo/asdf")
+	assert.Equal(t, imagor.ErrInvalid, err)
+
+	assert.Equal(t, imagor.ErrInvalid, s.Put(ctx, "/bar/fooo/asdf", imagor.NewBlobFromBytes([]byte("bar"))))
+
+	assert.Equal(t, imagor.ErrInvalid, s.Delete(ctx, "/bar/fooo/asdf"))
+
+	_, err = s.Get(r, "/foo/fooo/asdf")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	_, err = s.Stat(ctx, "/foo/fooo/asdf")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	require.NoError(t, s.Put(ctx, "/foo/fooo/asdf", imagor.NewBlobFromBytes([]byte("bar"))))
+
+	stat, err := s.Stat(ctx, "/foo/fooo/asdf")
+	require.NoError(t, err)
+	assert.False(t, stat.ModifiedTime.IsZero())
+	assert.Equal(t, int64(3), stat.Size)
+
+	b, err := s.Get(r, "/foo/fooo/asdf")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(buf))
+	assert.Equal(t, stat, b.Stat)
+
+	require.NoError(t, s.Delete(ctx, "/foo/fooo/asdf"))
+
+	_, err = s.Get(r, "/foo/fooo/asdf")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
+
+func TestPool(t *testing.T) {
+	addr, config := fakeSFTPServer(t, newSigner(t))
+
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+	s := New(addr, config, WithPoolSize(2))
+	defer s.Close()
+
+	require.NoError(t, s.Put(ctx, "/foo/bar", imagor.NewBlobFromBytes([]byte("bar"))))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, err := s.Get(r, "/foo/bar")
+			require.NoError(t, err)
+			buf, err := b.ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, "bar", string(buf))
+		}()
+	}
+	wg.Wait()
+
+	// broken connections are discarded and redialed
+	s.pool.mu.Lock()
+	assert.LessOrEqual(t, len(s.pool.idle), 2)
+	for _, c := range s.pool.idle {
+		_ = c.ssh.Close()
+	}
+	s.pool.mu.Unlock()
+	_, err := s.Stat(ctx, "/foo/bar")
+	assert.Error(t, err)
+	stat, err := s.Stat(ctx, "/foo/bar")
+	if err != nil {
+		// second idle connection also broken
+		stat, err = s.Stat(ctx, "/foo/bar")
+	}
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stat.Size)
+}
+
+func TestUnauthorized(t *testing.T) {
+	addr, config := fakeSFTPServer(t, newSigner(t))
+	config.Auth = []ssh.AuthMethod{ssh.PublicKeys(newSigner(t))}
+
+	s := New(addr, config)
+	defer s.Close()
+	_, err := s.Stat(context.Background(), "/foo/bar")
+	assert.Error(t, err)
+	assert.NotEqual(t, imagor.ErrNotFound, err)
+}
+
+func TestExpiration(t *testing.T) {
+	addr, config := fakeSFTPServer(t, newSigner(t))
+
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+	s := New(addr, config, WithExpiration(time.Second))
+	defer s.Close()
+
+	require.NoError(t, s.Put(ctx, "/foo/bar", imagor.NewBlobFromBytes([]byte("bar"))))
+	b, err := s.Get(r, "/foo/bar")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(buf))
+
+	time.Sleep(time.Second * 2)
+	_, err = s.Get(r, "/foo/bar")
+	assert.ErrorIs(t, err, imagor.ErrExpired)
+}
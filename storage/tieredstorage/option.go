@@ -0,0 +1,36 @@
+package tieredstorage
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Option TieredStorage option
+type Option func(s *TieredStorage)
+
+// WithWriteBack with write-back option, writing to the first eligible tier
+// synchronously and the rest asynchronously
+func WithWriteBack(writeBack bool) Option {
+	return func(s *TieredStorage) {
+		s.WriteBack = writeBack
+	}
+}
+
+// WithTimeout with timeout of asynchronous promotions and write-back writes option
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *TieredStorage) {
+		if timeout > 0 {
+			s.Timeout = timeout
+		}
+	}
+}
+
+// WithLogger with logger option
+func WithLogger(logger *zap.Logger) Option {
+	return func(s *TieredStorage) {
+		if logger != nil {
+			s.Logger = logger
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package tieredstorage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor"
+	"go.uber.org/zap"
+)
+
+// Tier storage tier of TieredStorage
+type Tier struct {
+	Storage imagor.Storage
+	// TTL treats blobs older than TTL as missing in this tier. Default no TTL
+	TTL time.Duration
+	// MaxSize skips writing blobs larger than MaxSize bytes to this tier. Default no limit
+	MaxSize int64
+}
+
+// TieredStorage Tiered Storage implements imagor.Storage interface.
+// Tiers are ordered from fastest to slowest. Get returns the first hit,
+// and a hit in a lower tier is copied into faster tiers asynchronously
+type TieredStorage struct {
+	Tiers []Tier
+	// WriteBack writes to the first eligible tier synchronously and the rest
+	// asynchronously, instead of writing through all tiers synchronously
+	WriteBack bool
+	// Timeout of asynchronous promotions and write-back writes
+	Timeout time.Duration
+	Logger  *zap.Logger
+
+	inflight sync.Map
+	wg       sync.WaitGroup
+}
+
+// New creates TieredStorage with tiers ordered from fastest to slowest
+func New(tiers []Tier, options ...Option) *TieredStorage {
+	s := &TieredStorage{
+		Tiers:   tiers,
+		Timeout: time.Minute,
+		Logger:  zap.NewNop(),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// Get implements imagor.Storage interface
+func (s *TieredStorage) Get(r *http.Request, key string) (*imagor.Blob, error) {
+	var err error = imagor.ErrNotFound
+	for i, tier := range s.Tiers {
+		blob, e := tier.Storage.Get(r, key)
+		if blob != nil && e == nil {
+			e = blob.Err()
+		} else if blob == nil && e == nil {
+			e = imagor.ErrNotFound
+		}
+		if e == nil {
+			if e = s.checkTTL(r.Context(), tier, key, blob.Stat); e == nil {
+				if i > 0 {
+					s.promote(key, blob, i)
+				}
+				return blob, nil
+			}
+		}
+		if !errors.Is(e, imagor.ErrNotFound) {
+			err = e
+		}
+	}
+	return nil, err
+}
+
+func (s *TieredStorage) checkTTL(ctx context.Context, tier Tier, key string, stat *imagor.Stat) (err error) {
+	if tier.TTL <= 0 {
+		return nil
+	}
+	if stat == nil || stat.ModifiedTime.IsZero() {
+		if stat, err = tier.Storage.Stat(ctx, key); err != nil {
+			return err
+		}
+	}
+	if !stat.ModifiedTime.IsZero() && time.Since(stat.ModifiedTime) > tier.TTL {
+		return imagor.ErrExpired
+	}
+	return nil
+}
+
+// promote copies blob into tiers faster than tier i asynchronously,
+// deduplicated per key
+func (s *TieredStorage) promote(key string, blob *imagor.Blob, i int) {
+	if _, loaded := s.inflight.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.inflight.Delete(key)
+		ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+		defer cancel()
+		for _, tier := range s.Tiers[:i] {
+			if err := s.put(ctx, tier, key, blob); err != nil {
+				s.Logger.Warn("tiered-promote", zap.String("key", key), zap.Error(err))
+			}
+		}
+	}()
+}
+
+func (s *TieredStorage) put(ctx context.Context, tier Tier, key string, blob *imagor.Blob) error {
+	if tier.MaxSize > 0 && blob.Size() > tier.MaxSize {
+		return nil
+	}
+	if err := tier.Storage.Put(ctx, key, blob); err != nil {
+		_ = tier.Storage.Delete(ctx, key)
+		return err
+	}
+	return nil
+}
+
+// Put implements imagor.Storage interface.
+// It fails only if the blob could not be written to any tier
+func (s *TieredStorage) Put(ctx context.Context, key string, blob *imagor.Blob) error {
+	if len(s.Tiers) == 0 {
+		return nil
+	}
+	if s.WriteBack {
+		for i, tier := range s.Tiers {
+			if tier.MaxSize > 0 && blob.Size() > tier.MaxSize {
+				continue
+			}
+			if err := s.put(ctx, tier, key, blob); err != nil {
+				return err
+			}
+			s.writeBack(key, blob, s.Tiers[i+1:])
+			return nil
+		}
+		return nil
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for _, tier := range s.Tiers {
+		wg.Add(1)
+		go func(tier Tier) {
+			defer wg.Done()
+			if err := s.put(ctx, tier, key, blob); err != nil {
+				s.Logger.Warn("tiered-save", zap.String("key", key), zap.Error(err))
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(tier)
+	}
+	wg.Wait()
+	if len(errs) == len(s.Tiers) {
+		return errs[0]
+	}
+	return nil
+}
+
+func (s *TieredStorage) writeBack(key string, blob *imagor.Blob, tiers []Tier) {
+	if len(tiers) == 0 {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+		defer cancel()
+		for _, tier := range tiers {
+			if err := s.put(ctx, tier, key, blob); err != nil {
+				s.Logger.Warn("tiered-write-back", zap.String("key", key), zap.Error(err))
+			}
+		}
+	}()
+}
+
+// Delete implements imagor.Storage interface
+func (s *TieredStorage) Delete(ctx context.Context, key string) (err error) {
+	for _, tier := range s.Tiers {
+		if e := tier.Storage.Delete(ctx, key); e != nil && err == nil &&
+			!errors.Is(e, os.ErrNotExist) && !errors.Is(e, imagor.ErrNotFound) {
+			err = e
+		}
+	}
+	return
+}
+
+// Stat implements imagor.Storage interface
+func (s *TieredStorage) Stat(ctx context.Context, key string) (*imagor.Stat, error) {
+	var err error = imagor.ErrNotFound
+	for _, tier := range s.Tiers {
+		stat, e := tier.Storage.Stat(ctx, key)
+		if stat == nil && e == nil {
+			e = imagor.ErrNotFound
+		}
+		if e == nil {
+			if e = s.checkTTL(ctx, tier, key, stat); e == nil {
+				return stat, nil
+			}
+		}
+		if !errors.Is(e, imagor.ErrNotFound) {
+			err = e
+		}
+	}
+	return nil, err
+}
+
+// Wait waits for pending asynchronous promotions and write-back writes
+func (s *TieredStorage) Wait() {
+	s.wg.Wait()
+}
+
+// Startup implements imagor.Lifecycle interface, starting tiers implementing it
+func (s *TieredStorage) Startup(ctx context.Context) error {
+	for _, tier := range s.Tiers {
+		if lc, ok := tier.Storage.(imagor.Lifecycle); ok {
+			if err := lc.Startup(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Shutdown implements imagor.Lifecycle interface, stopping tiers implementing it
+func (s *TieredStorage) Shutdown(ctx context.Context) error {
+	for _, tier := range s.Tiers {
+		if lc, ok := tier.Storage.(imagor.Lifecycle); ok {
+			if err := lc.Shutdown(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
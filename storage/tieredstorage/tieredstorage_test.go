@@ -0,0 +1,165 @@
+package tieredstorage
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+func TestWriteThroughAndPromote(t *testing.T) {
+	fast := filestorage.New(t.TempDir())
+	slow := filestorage.New(t.TempDir())
+	s := New([]Tier{{Storage: fast}, {Storage: slow}})
+	r := (&http.Request{}).WithContext(ctx)
+
+	_, err := s.Get(r, "foo/bar")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	_, err = s.Stat(ctx, "foo/bar")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	require.NoError(t, s.Put(ctx, "foo/bar", imagor.NewBlobFromBytes([]byte("bar"))))
+	_, err = fast.Stat(ctx, "foo/bar")
+	assert.NoError(t, err)
+	_, err = slow.Stat(ctx, "foo/bar")
+	assert.NoError(t, err)
+
+	// hit in lower tier promoted to faster tier
+	require.NoError(t, slow.Put(ctx, "foo/baz", imagor.NewBlobFromBytes([]byte("baz"))))
+	_, err = fast.Stat(ctx, "foo/baz")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	b, err := s.Get(r, "foo/baz")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "baz", string(buf))
+	s.Wait()
+	b, err = fast.Get(r, "foo/baz")
+	require.NoError(t, err)
+	buf, err = b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "baz", string(buf))
+
+	require.NoError(t, s.Delete(ctx, "foo/bar"))
+	_, err = fast.Stat(ctx, "foo/bar")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	_, err = slow.Stat(ctx, "foo/bar")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	require.NoError(t, s.Delete(ctx, "foo/bar"))
+}
+
+func TestMaxSize(t *testing.T) {
+	fast := filestorage.New(t.TempDir())
+	slow := filestorage.New(t.TempDir())
+	s := New([]Tier{{Storage: fast, MaxSize: 3}, {Storage: slow}})
+
+	require.NoError(t, s.Put(ctx, "small", imagor.NewBlobFromBytes([]byte("bar"))))
+	require.NoError(t, s.Put(ctx, "large", imagor.NewBlobFromBytes([]byte("barbar"))))
+	_, err := fast.Stat(ctx, "small")
+	assert.NoError(t, err)
+	_, err = fast.Stat(ctx, "large")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	_, err = slow.Stat(ctx, "large")
+	assert.NoError(t, err)
+
+	// large blob not promoted
+	b, err := s.Get((&http.Request{}).WithContext(ctx), "large")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "barbar", string(buf))
+	s.Wait()
+	_, err = fast.Stat(ctx, "large")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
+
+func TestTTL(t *testing.T) {
+	fastDir := t.TempDir()
+	fast := filestorage.New(fastDir)
+	slow := filestorage.New(t.TempDir())
+	s := New([]Tier{{Storage: fast, TTL: time.Hour}, {Storage: slow}})
+	r := (&http.Request{}).WithContext(ctx)
+
+	require.NoError(t, fast.Put(ctx, "foo", imagor.NewBlobFromBytes([]byte("old"))))
+	require.NoError(t, slow.Put(ctx, "foo", imagor.NewBlobFromBytes([]byte("new"))))
+	p, _ := fast.Path("foo")
+	mtime := time.Now().Add(-time.Hour * 2)
+	require.NoError(t, os.Chtimes(p, mtime, mtime))
+
+	b, err := s.Get(r, "foo")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(buf))
+	stat, err := s.Stat(ctx, "foo")
+	require.NoError(t, err)
+	assert.True(t, stat.ModifiedTime.After(mtime))
+
+	// expired entry replaced by promotion
+	s.Wait()
+	b, err = fast.Get(r, "foo")
+	require.NoError(t, err)
+	buf, err = b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(buf))
+
+	s = New([]Tier{{Storage: fast, TTL: time.Hour}})
+	require.NoError(t, os.Chtimes(p, mtime, mtime))
+	_, err = s.Get(r, "foo")
+	assert.ErrorIs(t, err, imagor.ErrExpired)
+}
+
+func TestWriteBack(t *testing.T) {
+	fast := filestorage.New(t.TempDir())
+	slow := filestorage.New(t.TempDir())
+	s := New([]Tier{{Storage: fast, MaxSize: 3}, {Storage: slow}},
+		WithWriteBack(true), WithTimeout(time.Second))
+
+	require.NoError(t, s.Put(ctx, "foo", imagor.NewBlobFromBytes([]byte("bar"))))
+	_, err := fast.Stat(ctx, "foo")
+	assert.NoError(t, err)
+	s.Wait()
+	_, err = slow.Stat(ctx, "foo")
+	assert.NoError(t, err)
+
+	// first eligible tier written synchronously
+	require.NoError(t, s.Put(ctx, "large", imagor.NewBlobFromBytes([]byte("barbar"))))
+	_, err = fast.Stat(ctx, "large")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	_, err = slow.Stat(ctx, "large")
+	assert.NoError(t, err)
+}
+
+func TestLifecycle(t *testing.T) {
+	fast := filestorage.New(t.TempDir(),
+		filestorage.WithMaxFiles(1), filestorage.WithJanitorInterval(time.Millisecond*20))
+	s := New([]Tier{{Storage: fast}, {Storage: filestorage.New(t.TempDir())}})
+	require.NoError(t, s.Put(ctx, "foo", imagor.NewBlobFromBytes([]byte("foo"))))
+	mtime := time.Now().Add(-time.Minute)
+	p, _ := fast.Path("foo")
+	require.NoError(t, os.Chtimes(p, mtime, mtime))
+	require.NoError(t, s.Put(ctx, "bar", imagor.NewBlobFromBytes([]byte("bar"))))
+
+	require.NoError(t, s.Startup(ctx))
+	assert.Eventually(t, func() bool {
+		_, err := fast.Stat(ctx, "foo")
+		return err == imagor.ErrNotFound
+	}, time.Second, time.Millisecond*20)
+	_, err := fast.Stat(ctx, "bar")
+	assert.NoError(t, err)
+	b, err := s.Get((&http.Request{}).WithContext(ctx), "foo")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(buf))
+	s.Wait()
+	require.NoError(t, s.Shutdown(ctx))
+}
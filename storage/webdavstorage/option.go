@@ -0,0 +1,93 @@
+package webdavstorage
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Option WebDAVStorage option
+type Option func(s *WebDAVStorage)
+
+// WithHTTPClient with custom HTTP client option
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *WebDAVStorage) {
+		if client != nil {
+			s.Client = client
+		}
+	}
+}
+
+// WithPathPrefix with path prefix option
+func WithPathPrefix(prefix string) Option {
+	return func(s *WebDAVStorage) {
+		if prefix != "" {
+			prefix = "/" + strings.Trim(prefix, "/")
+			if prefix != "/" {
+				prefix += "/"
+			}
+			s.PathPrefix = prefix
+		}
+	}
+}
+
+// WithSafeChars with safe chars option
+func WithSafeChars(chars string) Option {
+	return func(s *WebDAVStorage) {
+		if chars != "" {
+			s.SafeChars = chars
+		}
+	}
+}
+
+// WithExpiration with modified time expiration option
+func WithExpiration(exp time.Duration) Option {
+	return func(s *WebDAVStorage) {
+		if exp > 0 {
+			s.Expiration = exp
+		}
+	}
+}
+
+// WithBasicAuth with HTTP basic auth option
+func WithBasicAuth(username, password string) Option {
+	return func(s *WebDAVStorage) {
+		if username != "" || password != "" {
+			s.Username = username
+			s.Password = password
+		}
+	}
+}
+
+// WithBearerToken with HTTP bearer token auth option, takes precedence over basic auth
+func WithBearerToken(token string) Option {
+	return func(s *WebDAVStorage) {
+		if token != "" {
+			s.BearerToken = token
+		}
+	}
+}
+
+// WithHeader with additional request header option
+func WithHeader(key, value string) Option {
+	return func(s *WebDAVStorage) {
+		if key != "" {
+			s.Header.Set(key, value)
+		}
+	}
+}
+
+// WithDisablePropfind with disable PROPFIND option, using HEAD for Stat instead
+func WithDisablePropfind(disabled bool) Option {
+	return func(s *WebDAVStorage) {
+		s.DisablePropfind = disabled
+	}
+}
+
+// WithDisableMkcol with disable MKCOL option for servers
+// that create parent directories on PUT automatically
+func WithDisableMkcol(disabled bool) Option {
+	return func(s *WebDAVStorage) {
+		s.DisableMkcol = disabled
+	}
+}
@@ -0,0 +1,357 @@
+package webdavstorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+)
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop>
+<D:getcontentlength/><D:getlastmodified/><D:getetag/><D:getcontenttype/>
+</D:prop></D:propfind>`
+
+// WebDAVStorage WebDAV Storage implements imagor.Storage interface.
+// It speaks plain HTTP GET/PUT/DELETE, with PROPFIND for Stat,
+// so it works with WebDAV servers, nginx-dav or any PUT-capable gateway
+type WebDAVStorage struct {
+	Client      *http.Client
+	BaseURL     *url.URL
+	PathPrefix  string
+	SafeChars   string
+	Expiration  time.Duration
+	Username    string
+	Password    string
+	BearerToken string
+	Header      http.Header
+	// DisablePropfind uses HEAD instead of PROPFIND for Stat,
+	// for gateways that are not WebDAV compliant
+	DisablePropfind bool
+	// DisableMkcol disables MKCOL of missing parent collections on Put,
+	// for servers that create intermediate directories automatically
+	DisableMkcol bool
+
+	safeChars imagorpath.SafeChars
+}
+
+// New creates WebDAVStorage
+func New(baseURL string, options ...Option) (*WebDAVStorage, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("webdavstorage: invalid base url %s", baseURL)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	s := &WebDAVStorage{
+		Client:     http.DefaultClient,
+		BaseURL:    u,
+		PathPrefix: "/",
+		Header:     http.Header{},
+	}
+	if u.User != nil {
+		// credentials in base URL
+		s.Username = u.User.Username()
+		s.Password, _ = u.User.Password()
+		u.User = nil
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.SafeChars == "--" {
+		s.safeChars = imagorpath.NewNoopSafeChars()
+	} else {
+		s.safeChars = imagorpath.NewSafeChars(s.SafeChars)
+	}
+	return s, nil
+}
+
+// Path transforms and validates image key for storage URL path relative to base URL
+func (s *WebDAVStorage) Path(image string) (string, bool) {
+	image = "/" + imagorpath.Normalize(image, s.safeChars)
+	if !strings.HasPrefix(image, s.PathPrefix) {
+		return "", false
+	}
+	image = strings.TrimPrefix(image, s.PathPrefix)
+	if image == "" || image == "." {
+		return "", false
+	}
+	return image, true
+}
+
+func (s *WebDAVStorage) url(key string) string {
+	u := *s.BaseURL
+	u.RawPath = ""
+	// key is already escaped by Normalize
+	return u.String() + key
+}
+
+func (s *WebDAVStorage) newRequest(
+	ctx context.Context, method, u string, body io.Reader,
+) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for key := range s.Header {
+		req.Header.Set(key, s.Header.Get(key))
+	}
+	if s.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.BearerToken)
+	} else if s.Username != "" || s.Password != "" {
+		req.SetBasicAuth(s.Username, s.Password)
+	}
+	return req, nil
+}
+
+func (s *WebDAVStorage) do(req *http.Request) (*http.Response, error) {
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return resp, imagor.ErrNotFound
+		}
+		return resp, imagor.NewErrorFromStatusCode(resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// Get implements imagor.Storage interface
+func (s *WebDAVStorage) Get(r *http.Request, image string) (*imagor.Blob, error) {
+	ctx := r.Context()
+	key, ok := s.Path(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	var blob *imagor.Blob
+	var once sync.Once
+	blob = imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+		req, err := s.newRequest(ctx, http.MethodGet, s.url(key), nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, 0, err
+		}
+		stat := statFromHeader(resp.Header, resp.ContentLength)
+		if s.Expiration > 0 && !stat.ModifiedTime.IsZero() &&
+			time.Since(stat.ModifiedTime) > s.Expiration {
+			_ = resp.Body.Close()
+			return nil, 0, imagor.ErrExpired
+		}
+		once.Do(func() {
+			blob.Stat = stat
+			if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+				blob.SetContentType(contentType)
+			}
+		})
+		size := resp.ContentLength
+		if size < 0 {
+			size = 0
+		}
+		return resp.Body, size, nil
+	})
+	return blob, nil
+}
+
+// Put implements imagor.Storage interface
+func (s *WebDAVStorage) Put(ctx context.Context, image string, blob *imagor.Blob) error {
+	key, ok := s.Path(image)
+	if !ok {
+		return imagor.ErrInvalid
+	}
+	err := s.put(ctx, key, blob)
+	if !s.DisableMkcol && isConflict(err) {
+		// parent collection missing
+		if err = s.mkcol(ctx, key); err != nil {
+			return err
+		}
+		err = s.put(ctx, key, blob)
+	}
+	return err
+}
+
+func (s *WebDAVStorage) put(ctx context.Context, key string, blob *imagor.Blob) error {
+	reader, size, err := blob.NewReader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	req, err := s.newRequest(ctx, http.MethodPut, s.url(key), reader)
+	if err != nil {
+		return err
+	}
+	if size > 0 {
+		req.ContentLength = size
+	}
+	req.Header.Set("Content-Type", blob.ContentType())
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// mkcol creates missing parent collections of key, top down
+func (s *WebDAVStorage) mkcol(ctx context.Context, key string) error {
+	dir := path.Dir(key)
+	if dir == "." || dir == "/" {
+		return nil
+	}
+	var p string
+	for _, seg := range strings.Split(dir, "/") {
+		p += seg + "/"
+		req, err := s.newRequest(ctx, "MKCOL", s.url(p), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := s.do(req)
+		if err == nil {
+			_ = resp.Body.Close()
+		} else if resp == nil || resp.StatusCode != http.StatusMethodNotAllowed {
+			// 405 Method Not Allowed means collection already exists
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete implements imagor.Storage interface
+func (s *WebDAVStorage) Delete(ctx context.Context, image string) error {
+	key, ok := s.Path(image)
+	if !ok {
+		return imagor.ErrInvalid
+	}
+	req, err := s.newRequest(ctx, http.MethodDelete, s.url(key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		if err == imagor.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Stat implements imagor.Storage interface
+func (s *WebDAVStorage) Stat(ctx context.Context, image string) (*imagor.Stat, error) {
+	key, ok := s.Path(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	if !s.DisablePropfind {
+		stat, resp, err := s.propfind(ctx, key)
+		if err == nil || resp == nil || !isMethodUnsupported(resp.StatusCode) {
+			return stat, err
+		}
+	}
+	req, err := s.newRequest(ctx, http.MethodHead, s.url(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	return statFromHeader(resp.Header, resp.ContentLength), nil
+}
+
+type multistatus struct {
+	Responses []struct {
+		Propstats []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ContentLength string `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+				ETag          string `xml:"getetag"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+func (s *WebDAVStorage) propfind(ctx context.Context, key string) (*imagor.Stat, *http.Response, error) {
+	req, err := s.newRequest(ctx, "PROPFIND", s.url(key), bytes.NewBufferString(propfindBody))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Depth", "0")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, resp, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	var ms multistatus
+	if err = xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, resp, err
+	}
+	stat := &imagor.Stat{}
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstats {
+			if ps.Status != "" && !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			if size, e := strconv.ParseInt(ps.Prop.ContentLength, 10, 64); e == nil {
+				stat.Size = size
+			}
+			if t, e := http.ParseTime(ps.Prop.LastModified); e == nil {
+				stat.ModifiedTime = t
+			}
+			if ps.Prop.ETag != "" {
+				stat.ETag = ps.Prop.ETag
+			}
+		}
+	}
+	return stat, resp, nil
+}
+
+func statFromHeader(h http.Header, size int64) *imagor.Stat {
+	stat := &imagor.Stat{
+		ETag: h.Get("ETag"),
+	}
+	if size > 0 {
+		stat.Size = size
+	}
+	if t, err := http.ParseTime(h.Get("Last-Modified")); err == nil {
+		stat.ModifiedTime = t
+	}
+	return stat
+}
+
+func isConflict(err error) bool {
+	e, ok := err.(imagor.Error)
+	return ok && e.Code == http.StatusConflict
+}
+
+func isMethodUnsupported(code int) bool {
+	return code == http.StatusMethodNotAllowed || code == http.StatusNotImplemented
+}
@@ -0,0 +1,45 @@
+package warm
+
+// Option Warmer option
+type Option func(w *Warmer)
+
+// WithConcurrency with number of paths processed concurrently option
+func WithConcurrency(concurrency int) Option {
+	return func(w *Warmer) {
+		if concurrency > 0 {
+			w.Concurrency = concurrency
+		}
+	}
+}
+
+// WithPresets with presets option, applied to source keys of lines without presets
+func WithPresets(presets ...string) Option {
+	return func(w *Warmer) {
+		for _, preset := range presets {
+			if preset != "" {
+				w.Presets = append(w.Presets, preset)
+			}
+		}
+	}
+}
+
+// WithAccept with Accept header option, deciding the result format if auto WebP, AVIF or JPEG enabled
+func WithAccept(accept string) Option {
+	return func(w *Warmer) {
+		w.Accept = accept
+	}
+}
+
+// WithToken with bearer token option required by the HTTP handler
+func WithToken(token string) Option {
+	return func(w *Warmer) {
+		w.Token = token
+	}
+}
+
+// WithProgress with progress callback option, called with the result of each path
+func WithProgress(progress func(item Item)) Option {
+	return func(w *Warmer) {
+		w.Progress = progress
+	}
+}
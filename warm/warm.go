@@ -0,0 +1,221 @@
+package warm
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"golang.org/x/sync/errgroup"
+)
+
+// Item warm-up result of an imagor path
+type Item struct {
+	Path string
+	Size int64
+	Err  error
+}
+
+// Report warm-up report
+type Report struct {
+	Requested int
+	Succeeded int
+	Failed    int
+	// Bytes total size of results
+	Bytes int64
+}
+
+// Warmer runs imagor paths through Imagor.Do, so that results are saved to result storages
+// and source images are loaded into processor image cache ahead of requests.
+// Results are saved regardless of Imagor.ResultAdmission
+type Warmer struct {
+	App         *imagor.Imagor
+	Concurrency int
+	// Presets applied to source keys of lines without presets. Lines are read as imagor paths if empty
+	Presets []string
+	// Accept header of requests, deciding the result format if auto WebP, AVIF or JPEG enabled
+	Accept string
+	// Token bearer token required by ServeHTTP. Requests are not authorized if empty
+	Token string
+	// Progress is called with the result of each path, one at a time
+	Progress func(item Item)
+}
+
+// New creates Warmer of imagor app
+func New(app *imagor.Imagor, options ...Option) *Warmer {
+	w := &Warmer{
+		App:         app,
+		Concurrency: 8,
+	}
+	for _, option := range options {
+		option(w)
+	}
+	return w
+}
+
+// Read reads warm-up targets, one per line. A line is either an imagor path as requested,
+// i.e. starting with the URL signature or unsafe/, or a source key followed by
+// comma separated preset names e.g. "foo/bar.jpg thumb,hero".
+// Blank lines and lines starting with # are skipped
+func (w *Warmer) Read(r io.Reader) (targets []imagorpath.Params, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var presets = w.Presets
+		key, names, ok := strings.Cut(line, " ")
+		if ok {
+			presets = strings.Split(strings.TrimSpace(names), ",")
+		}
+		if len(presets) == 0 {
+			targets = append(targets, imagorpath.Parse(strings.TrimPrefix(key, "/")))
+			continue
+		}
+		for _, name := range presets {
+			name = strings.TrimSpace(name)
+			p, err := w.App.Preset(name, strings.TrimPrefix(key, "/"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: preset %s: %w", n, name, err)
+			}
+			targets = append(targets, p)
+		}
+	}
+	return targets, scanner.Err()
+}
+
+// Run runs all targets through Imagor.Do concurrently.
+// Targets failed are reported without stopping the warm-up,
+// error is returned only if ctx is done
+func (w *Warmer) Run(ctx context.Context, targets []imagorpath.Params) (report Report, err error) {
+	var mu sync.Mutex
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(w.Concurrency, 1))
+	for _, p := range targets {
+		if gctx.Err() != nil {
+			break
+		}
+		g.Go(func() error {
+			item := w.do(gctx, p)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Requested++
+			if item.Err != nil {
+				report.Failed++
+			} else {
+				report.Succeeded++
+				report.Bytes += item.Size
+			}
+			if w.Progress != nil {
+				w.Progress(item)
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return report, ctx.Err()
+}
+
+// do runs imagor path through Imagor.Do, signed on behalf of the requester
+func (w *Warmer) do(ctx context.Context, p imagorpath.Params) (item Item) {
+	item.Path = p.Path
+	r, err := http.NewRequestWithContext(imagor.ContextWithSaveResult(ctx), http.MethodGet, "/", nil)
+	if err != nil {
+		item.Err = err
+		return
+	}
+	if w.Accept != "" {
+		r.Header.Set("Accept", w.Accept)
+	}
+	if !(w.App.Unsafe && p.Unsafe) {
+		signer := w.App.RequestSigner(r)
+		if signer == nil {
+			item.Err = imagor.ErrSignatureMismatch
+			return
+		}
+		p.Unsafe = false
+		p.Hash = signer.Sign(p.Path)
+	}
+	blob, err := w.App.Do(r, p)
+	if err == nil && blob != nil {
+		err = blob.Err()
+	}
+	if err != nil {
+		item.Err = err
+		return
+	}
+	if blob != nil {
+		item.Size = blob.Size()
+		_ = blob.Release()
+	}
+	return
+}
+
+// failure JSON failure of warm-up response
+type failure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// response JSON warm-up response
+type response struct {
+	Requested int       `json:"requested"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+	Bytes     int64     `json:"bytes"`
+	Failures  []failure `json:"failures"`
+}
+
+// ServeHTTP implements http.Handler, warming up targets of POST request body in the format of Read,
+// responding with JSON report after completion. Presets may be overridden by the presets query.
+// Requests require Authorization header of the bearer Token
+func (w *Warmer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if w.Token == "" || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(w.Token)) != 1 {
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	warmer := *w
+	if presets := r.URL.Query().Get("presets"); presets != "" {
+		warmer.Presets = strings.Split(presets, ",")
+	}
+	targets, err := warmer.Read(r.Body)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var failures = []failure{}
+	warmer.Progress = func(item Item) {
+		if item.Err != nil {
+			failures = append(failures, failure{Path: item.Path, Error: item.Err.Error()})
+		}
+		if w.Progress != nil {
+			w.Progress(item)
+		}
+	}
+	report, err := warmer.Run(r.Context(), targets)
+	if err != nil {
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(response{
+		Requested: report.Requested,
+		Succeeded: report.Succeeded,
+		Failed:    report.Failed,
+		Bytes:     report.Bytes,
+		Failures:  failures,
+	})
+}
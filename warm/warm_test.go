@@ -0,0 +1,199 @@
+package warm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/cshum/imagor/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+type processor struct {
+	mu     sync.Mutex
+	params []string
+}
+
+func (p *processor) Startup(context.Context) error  { return nil }
+func (p *processor) Shutdown(context.Context) error { return nil }
+func (p *processor) Process(
+	_ context.Context, blob *imagor.Blob, params imagorpath.Params, _ imagor.LoadFunc,
+) (*imagor.Blob, error) {
+	p.mu.Lock()
+	p.params = append(p.params, params.Path)
+	p.mu.Unlock()
+	buf, err := blob.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return imagor.NewBlobFromBytes(append(buf, "!"...)), nil
+}
+
+type rejectAll struct{}
+
+func (rejectAll) Admit(string, time.Duration, int64) bool { return false }
+
+func newApp(t *testing.T, options ...imagor.Option) (*imagor.Imagor, *filestorage.FileStorage, *processor) {
+	source := filestorage.New(t.TempDir())
+	for _, key := range []string{"foo.jpg", "bar/baz.png"} {
+		require.NoError(t, source.Put(ctx, key, imagor.NewBlobFromBytes([]byte(key))))
+	}
+	result := filestorage.New(t.TempDir())
+	proc := &processor{}
+	app := imagor.New(append([]imagor.Option{
+		imagor.WithSigner(imagorpath.NewDefaultSigner("1234")),
+		imagor.WithLoaders(source),
+		imagor.WithResultStorages(result),
+		imagor.WithProcessors(proc),
+		imagor.WithResultAdmission(rejectAll{}),
+		imagor.WithPresets(map[string]string{
+			"thumb": "fit-in/20x20",
+			"hero":  "/100x0/filters:quality(80)/",
+		}),
+	}, options...)...)
+	return app, result, proc
+}
+
+func TestRead(t *testing.T) {
+	app, _, _ := newApp(t)
+	targets, err := New(app).Read(strings.NewReader(`
+# comment
+unsafe/fit-in/10x10/foo.jpg
+/bar/baz.png thumb,hero
+`))
+	require.NoError(t, err)
+	var paths []string
+	for _, p := range targets {
+		paths = append(paths, p.Path)
+	}
+	assert.Equal(t, []string{
+		"fit-in/10x10/foo.jpg",
+		"fit-in/20x20/bar/baz.png",
+		"100x0/filters:quality(80)/bar/baz.png",
+	}, paths)
+	assert.True(t, targets[0].Unsafe)
+	assert.Equal(t, "bar/baz.png", targets[2].Image)
+
+	targets, err = New(app, WithPresets("thumb")).Read(strings.NewReader("foo.jpg\nbar/baz.png hero"))
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+	assert.Equal(t, "fit-in/20x20/foo.jpg", targets[0].Path)
+	assert.Equal(t, "100x0/filters:quality(80)/bar/baz.png", targets[1].Path)
+
+	_, err = New(app).Read(strings.NewReader("foo.jpg thumb\nfoo.jpg nope"))
+	assert.ErrorIs(t, err, imagor.ErrPresetNotFound)
+	assert.Contains(t, err.Error(), "line 2")
+}
+
+func TestRun(t *testing.T) {
+	app, result, proc := newApp(t)
+	w := New(app, WithConcurrency(2))
+	targets, err := w.Read(strings.NewReader("foo.jpg thumb\nbar/baz.png thumb\nmissing.jpg thumb"))
+	require.NoError(t, err)
+
+	var items []Item
+	w.Progress = func(item Item) {
+		items = append(items, item)
+	}
+	report, err := w.Run(ctx, targets)
+	require.NoError(t, err)
+	assert.Equal(t, Report{Requested: 3, Succeeded: 2, Failed: 1, Bytes: 20}, report)
+	sort.Slice(items, func(i, j int) bool { return items[i].Path < items[j].Path })
+	assert.Equal(t, Item{Path: "fit-in/20x20/bar/baz.png", Size: 12}, items[0])
+	assert.Equal(t, Item{Path: "fit-in/20x20/foo.jpg", Size: 8}, items[1])
+	assert.Equal(t, "fit-in/20x20/missing.jpg", items[2].Path)
+	assert.ErrorIs(t, items[2].Err, imagor.ErrNotFound)
+
+	// results saved regardless of result admission
+	for _, key := range []string{"fit-in/20x20/foo.jpg", "fit-in/20x20/bar/baz.png"} {
+		stat, err := result.Stat(ctx, key)
+		require.NoError(t, err, key)
+		assert.NotNil(t, stat)
+	}
+
+	// warmed results are served from result storage
+	n := len(proc.params)
+	report, err = w.Run(ctx, targets[:2])
+	require.NoError(t, err)
+	assert.Equal(t, Report{Requested: 2, Succeeded: 2, Bytes: 20}, report)
+	assert.Len(t, proc.params, n)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = w.Run(cancelled, targets)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestRunUnsafe(t *testing.T) {
+	app, result, _ := newApp(t, imagor.WithUnsafe(true))
+	targets, err := New(app).Read(strings.NewReader("unsafe/fit-in/5x5/foo.jpg"))
+	require.NoError(t, err)
+	report, err := New(app).Run(ctx, targets)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Succeeded)
+	_, err = result.Stat(ctx, "fit-in/5x5/foo.jpg")
+	assert.NoError(t, err)
+
+	app, _, _ = newApp(t, imagor.WithGetSigner(func(*http.Request) imagorpath.Signer {
+		return nil
+	}))
+	report, err = New(app).Run(ctx, targets)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Failed)
+}
+
+func TestServeHTTP(t *testing.T) {
+	app, result, _ := newApp(t)
+	w := New(app, WithToken("secret"))
+
+	rec := httptest.NewRecorder()
+	w.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = httptest.NewRecorder()
+	w.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("foo.jpg")))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("foo.jpg"))
+	r.Header.Set("Authorization", "Bearer wrong")
+	w.ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/?presets=nope", strings.NewReader("foo.jpg"))
+	r.Header.Set("Authorization", "Bearer secret")
+	w.ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/?presets=thumb", strings.NewReader("foo.jpg\nmissing.jpg"))
+	r.Header.Set("Authorization", "Bearer secret")
+	w.ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"requested": 2, "succeeded": 1, "failed": 1, "bytes": 8,
+		"failures": [{"path": "fit-in/20x20/missing.jpg", "error": "imagor: 404 not found"}]
+	}`, rec.Body.String())
+	_, err := result.Stat(ctx, "fit-in/20x20/foo.jpg")
+	assert.NoError(t, err)
+
+	// token is required
+	rec = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("foo.jpg"))
+	r.Header.Set("Authorization", "Bearer ")
+	New(app).ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}